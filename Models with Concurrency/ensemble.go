@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// averagingEnsemble predicts a weighted average of the predictions of its base models.
+// The base models are trained concurrently, one runTasks task each.
+type averagingEnsemble struct {
+	newModels []func() regressor
+	weights   []float64 // one per base model, nil for a simple average
+
+	models []regressor
+}
+
+// stackingEnsemble feeds the predictions of its base models to a meta-regressor. The
+// meta-regressor learns from out-of-fold predictions, so it sees how each base model does
+// on rows it was not trained on rather than rewarding the one that overfits most; the
+// base models are then refit on all rows for prediction. Base models are trained
+// concurrently, and each one's folds are too.
+type stackingEnsemble struct {
+	newModels []func() regressor
+	newMeta   func() regressor
+	numFolds  int
+	seed      int64
+
+	models []regressor
+	meta   regressor
+	// baseCVMSE is the cross-validated MSE of every base model on its own
+	baseCVMSE []float64
+}
+
+// blendingEnsemble is stacking with a single holdout split instead of cross-validation.
+// The base models are fit on the training rows only, and the meta-regressor is fit on
+// their predictions for the held-out rows. That is cheaper than stacking, but both stages
+// learn from fewer rows.
+type blendingEnsemble struct {
+	newModels       []func() regressor
+	newMeta         func() regressor
+	holdoutFraction float64
+	seed            int64
+
+	models []regressor
+	meta   regressor
+	// holdoutMSE is the MSE of every base model on the held-out rows
+	holdoutMSE []float64
+}
+
+// newAveragingEnsemble returns an averagingEnsemble over the given base models, weighted
+// by weights or equally when weights is nil.
+func newAveragingEnsemble(weights []float64, newModels ...func() regressor) *averagingEnsemble {
+	return &averagingEnsemble{newModels: newModels, weights: weights}
+}
+
+// newStackingEnsemble returns a stackingEnsemble with 5-fold out-of-fold predictions.
+func newStackingEnsemble(newMeta func() regressor, newModels ...func() regressor) *stackingEnsemble {
+	return &stackingEnsemble{newModels: newModels, newMeta: newMeta, numFolds: 5, seed: 1}
+}
+
+// newBlendingEnsemble returns a blendingEnsemble that holds out a random 30% of the rows
+// for the meta-regressor.
+func newBlendingEnsemble(newMeta func() regressor, newModels ...func() regressor) *blendingEnsemble {
+	return &blendingEnsemble{newModels: newModels, newMeta: newMeta, holdoutFraction: 0.3, seed: 1}
+}
+
+func (e *averagingEnsemble) Fit(features [][]float64, target []float64) error {
+	return e.FitWeighted(features, target, nil)
+}
+
+// FitWeighted fits every base model with the sample weights, so each must be a
+// weightedRegressor when weights is non-nil. They are not the per-model weights of the
+// average.
+func (e *averagingEnsemble) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(e.newModels) == 0 {
+		return fmt.Errorf("ensemble has no base models")
+	}
+	if e.weights != nil {
+		if len(e.weights) != len(e.newModels) {
+			return fmt.Errorf("weights and base models length mismatch: %d vs %d", len(e.weights), len(e.newModels))
+		}
+		if _, err := checkWeights(e.weights, len(e.newModels)); err != nil {
+			return err
+		}
+	}
+
+	models := make([]regressor, len(e.newModels))
+	errs := make([]error, len(e.newModels))
+	runTasks(len(e.newModels), func(k int) {
+		models[k] = e.newModels[k]()
+		errs[k] = fitWithWeights(models[k], features, target, weights)
+	})
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	e.models = models
+	return nil
+}
+
+func (e *averagingEnsemble) Predict(featureRow []float64) float64 {
+	predictions := basePredictions(e.models, featureRow)
+	if e.weights == nil {
+		return floats.Sum(predictions) / float64(len(predictions))
+	}
+	return floats.Dot(predictions, e.weights) / floats.Sum(e.weights)
+}
+
+func (e *stackingEnsemble) Fit(features [][]float64, target []float64) error {
+	return e.FitWeighted(features, target, nil)
+}
+
+// FitWeighted passes the sample weights to the base models, their folds and the
+// meta-regressor, which must all be weightedRegressors when weights is non-nil.
+func (e *stackingEnsemble) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(e.newModels) == 0 {
+		return fmt.Errorf("ensemble has no base models")
+	}
+
+	// Column k of the meta features holds base model k's out-of-fold predictions
+	numModels := len(e.newModels)
+	outOfFold := make([][]float64, numModels)
+	models := make([]regressor, numModels)
+	errs := make([]error, numModels)
+	runTasks(numModels, func(k int) {
+		if outOfFold[k], errs[k] = crossValidatedPredictions(e.newModels[k], features, target, weights, e.numFolds, e.seed); errs[k] != nil {
+			return
+		}
+		models[k] = e.newModels[k]()
+		errs[k] = fitWithWeights(models[k], features, target, weights)
+	})
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	metaFeatures := make([][]float64, len(target))
+	for i := range metaFeatures {
+		metaFeatures[i] = make([]float64, numModels)
+		for k := range outOfFold {
+			metaFeatures[i][k] = outOfFold[k][i]
+		}
+	}
+	e.baseCVMSE = make([]float64, numModels)
+	for k, predictions := range outOfFold {
+		if weights == nil {
+			e.baseCVMSE[k] = meanSquaredError(predictions, target)
+		} else {
+			e.baseCVMSE[k] = weightedMeanSquaredError(predictions, target, weights)
+		}
+	}
+
+	meta := e.newMeta()
+	if err := fitWithWeights(meta, metaFeatures, target, weights); err != nil {
+		return err
+	}
+	e.models, e.meta = models, meta
+	return nil
+}
+
+func (e *stackingEnsemble) Predict(featureRow []float64) float64 {
+	return e.meta.Predict(basePredictions(e.models, featureRow))
+}
+
+func (e *blendingEnsemble) Fit(features [][]float64, target []float64) error {
+	return e.FitWeighted(features, target, nil)
+}
+
+// FitWeighted passes the sample weights of the training rows to the base models and
+// those of the held-out rows to the meta-regressor.
+func (e *blendingEnsemble) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(e.newModels) == 0 {
+		return fmt.Errorf("ensemble has no base models")
+	}
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	if weights != nil {
+		if _, err := checkWeights(weights, len(target)); err != nil {
+			return err
+		}
+	}
+	numHoldout := int(e.holdoutFraction * float64(len(target)))
+	if numHoldout < 1 || numHoldout >= len(target) {
+		return fmt.Errorf("holdout fraction %v leaves no training or no held-out rows out of %d", e.holdoutFraction, len(target))
+	}
+
+	// The first numHoldout rows of a shuffle are held out
+	var trainFeatures, holdoutFeatures [][]float64
+	var trainTarget, holdoutTarget, trainWeights, holdoutWeights []float64
+	for position, i := range rand.New(rand.NewSource(e.seed)).Perm(len(target)) {
+		if position < numHoldout {
+			holdoutFeatures = append(holdoutFeatures, features[i])
+			holdoutTarget = append(holdoutTarget, target[i])
+			if weights != nil {
+				holdoutWeights = append(holdoutWeights, weights[i])
+			}
+		} else {
+			trainFeatures = append(trainFeatures, features[i])
+			trainTarget = append(trainTarget, target[i])
+			if weights != nil {
+				trainWeights = append(trainWeights, weights[i])
+			}
+		}
+	}
+
+	numModels := len(e.newModels)
+	models := make([]regressor, numModels)
+	errs := make([]error, numModels)
+	runTasks(numModels, func(k int) {
+		models[k] = e.newModels[k]()
+		errs[k] = fitWithWeights(models[k], trainFeatures, trainTarget, trainWeights)
+	})
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	metaFeatures := make([][]float64, len(holdoutTarget))
+	for i, row := range holdoutFeatures {
+		metaFeatures[i] = basePredictions(models, row)
+	}
+	e.holdoutMSE = make([]float64, numModels)
+	for k := range models {
+		predictions := targetColumn(metaFeatures, k)
+		if weights == nil {
+			e.holdoutMSE[k] = meanSquaredError(predictions, holdoutTarget)
+		} else {
+			e.holdoutMSE[k] = weightedMeanSquaredError(predictions, holdoutTarget, holdoutWeights)
+		}
+	}
+
+	meta := e.newMeta()
+	if err := fitWithWeights(meta, metaFeatures, holdoutTarget, holdoutWeights); err != nil {
+		return err
+	}
+	e.models, e.meta = models, meta
+	return nil
+}
+
+func (e *blendingEnsemble) Predict(featureRow []float64) float64 {
+	return e.meta.Predict(basePredictions(e.models, featureRow))
+}
+
+// basePredictions returns the prediction of every model for featureRow.
+func basePredictions(models []regressor, featureRow []float64) []float64 {
+	predictions := make([]float64, len(models))
+	for k, model := range models {
+		predictions[k] = model.Predict(featureRow)
+	}
+	return predictions
+}
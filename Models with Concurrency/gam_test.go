@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestGAMFitsSmoothEffect(t *testing.T) {
+	// Create sample data with a nonlinear effect in x1 and a linear effect in x2
+	var features [][]float64
+	var target []float64
+	for i := 0; i < 200; i++ {
+		x1 := float64(i) / 20
+		x2 := float64(i % 7)
+		features = append(features, []float64{x1, x2})
+		target = append(target, math.Sin(x1)+0.5*x2)
+	}
+
+	for _, spline := range []string{"ns", "bs"} {
+		model := newGAM([]string{"x1", "x2"}, gamTerm{column: "x1", spline: spline, knots: 8, lambda: 1e-8})
+		if err := model.Fit(features, target); err != nil {
+			t.Fatalf("Unexpected error fitting %s GAM: %v", spline, err)
+		}
+
+		predictions := make([]float64, len(features))
+		for i, row := range features {
+			predictions[i] = model.Predict(row)
+		}
+		if rmse := rootMeanSquaredError(predictions, target); rmse > 0.05 {
+			t.Errorf("Unexpected %s GAM training RMSE. Expected at most 0.05, got %f", spline, rmse)
+		}
+		if math.Abs(model.linearCoefficients[0]-0.5) > 0.01 {
+			t.Errorf("Unexpected linear coefficient. Expected 0.5, got %f", model.linearCoefficients[0])
+		}
+	}
+}
+
+func TestGAMExportPartialEffects(t *testing.T) {
+	// Create sample data for a single smooth term
+	var features [][]float64
+	var target []float64
+	for i := 0; i < 50; i++ {
+		x := float64(i)
+		features = append(features, []float64{x})
+		target = append(target, x*x)
+	}
+
+	model := newGAM([]string{"x"}, gamTerm{column: "x", spline: "bs", knots: 5, lambda: 1})
+	if err := model.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	values, effects, err := model.partialEffect("x", 25)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(values) != 25 || len(effects) != 25 {
+		t.Errorf("Unexpected partial effect length. Expected 25, got %d and %d", len(values), len(effects))
+	}
+
+	filename := filepath.Join(t.TempDir(), "effects.csv")
+	if err := model.exportPartialEffects(filename, 25); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	lines := 0
+	for _, b := range data {
+		if b == '\n' {
+			lines++
+		}
+	}
+	if lines != 26 {
+		t.Errorf("Unexpected number of CSV lines. Expected 26, got %d", lines)
+	}
+}
+
+func TestGAMUnknownColumn(t *testing.T) {
+	model := newGAM([]string{"x"}, gamTerm{column: "lstat", spline: "ns", knots: 4, lambda: 1})
+	if err := model.Fit([][]float64{{1}, {2}, {3}}, []float64{1, 2, 3}); err == nil {
+		t.Errorf("Expected an error for an unknown column")
+	}
+}
+
+func TestGAMRejectsInvalidTerms(t *testing.T) {
+	features := [][]float64{{1, 0}, {2, 0}, {3, 0}, {4, 0}, {5, 0}}
+	target := []float64{1, 4, 9, 16, 25}
+
+	terms := map[string]gamTerm{
+		"too few knots":   {column: "x", spline: "ns", knots: 2, lambda: 1},
+		"negative knots":  {column: "x", spline: "bs", knots: -1, lambda: 1},
+		"constant column": {column: "chas", spline: "ns", knots: 4, lambda: 1},
+	}
+	for name, term := range terms {
+		model := newGAM([]string{"x", "chas"}, term)
+		if err := model.Fit(features, target); err == nil {
+			t.Errorf("Expected an error for %s", name)
+		}
+	}
+}
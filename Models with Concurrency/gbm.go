@@ -0,0 +1,331 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// treeNode is a node of a binary regression tree. Rows with feature value <= threshold go
+// left; a node without children is a leaf that predicts value.
+type treeNode struct {
+	feature      int
+	threshold    float64
+	left, right  *treeNode
+	value        float64
+	samples      int     // number of training rows that reached the node
+	weight       float64 // sum of the sample weights of those rows
+	squaredError float64 // weighted sum of squared errors of those rows around value, used for pruning
+}
+
+func (n *treeNode) predict(featureRow []float64) float64 {
+	for n.left != nil {
+		if featureRow[n.feature] <= n.threshold {
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+	return n.value
+}
+
+// gradientBoosting is a gradient boosted ensemble of regression trees for squared loss.
+// Trees are grown on histograms of binned features, so split search costs O(bins) per
+// feature instead of a sort. Split search runs one task per feature through runTasks.
+type gradientBoosting struct {
+	numTrees            int     // maximum number of boosting rounds
+	learningRate        float64 // shrinkage applied to every tree
+	maxDepth            int
+	minSamplesLeaf      int
+	subsample           float64 // fraction of training rows used to grow each tree
+	maxBins             int     // at most 256
+	validationFraction  float64 // rows held out for early stopping, 0 disables it
+	earlyStoppingRounds int     // stop after this many rounds without validation improvement
+	seed                int64
+
+	baseline float64
+	trees    []*treeNode
+	// validationLoss is the validation MSE after each round, when early stopping is on
+	validationLoss []float64
+}
+
+// newGradientBoosting returns a gradientBoosting with commonly used defaults.
+func newGradientBoosting() *gradientBoosting {
+	return &gradientBoosting{
+		numTrees:            200,
+		learningRate:        0.1,
+		maxDepth:            3,
+		minSamplesLeaf:      5,
+		subsample:           0.8,
+		maxBins:             64,
+		validationFraction:  0.1,
+		earlyStoppingRounds: 10,
+		seed:                1,
+	}
+}
+
+func (g *gradientBoosting) Fit(features [][]float64, target []float64) error {
+	return g.FitWeighted(features, target, nil)
+}
+
+// FitWeighted weights every row's squared loss, so split gains, leaf values, the baseline
+// and the validation loss are all weighted.
+func (g *gradientBoosting) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	if g.maxBins < 2 || g.maxBins > 256 {
+		return fmt.Errorf("maxBins must be between 2 and 256, got %d", g.maxBins)
+	}
+	rng := rand.New(rand.NewSource(g.seed))
+
+	// Hold out a random validation split for early stopping
+	order := rng.Perm(len(features))
+	numValidation := 0
+	if g.validationFraction > 0 && g.earlyStoppingRounds > 0 {
+		numValidation = int(g.validationFraction * float64(len(features)))
+	}
+	validationRows := order[:numValidation]
+	trainRows := order[numValidation:]
+	if len(trainRows) < 2*g.minSamplesLeaf {
+		return fmt.Errorf("need at least %d training rows, got %d", 2*g.minSamplesLeaf, len(trainRows))
+	}
+
+	edges := histogramEdges(features, trainRows, g.maxBins)
+	binned := binFeatures(features, edges)
+
+	var sum, sumWeights float64
+	for _, i := range trainRows {
+		sum += weights[i] * target[i]
+		sumWeights += weights[i]
+	}
+	if sumWeights == 0 {
+		return fmt.Errorf("every training row has zero weight")
+	}
+	g.baseline = sum / sumWeights
+
+	current := make([]float64, len(features))
+	for i := range current {
+		current[i] = g.baseline
+	}
+	residuals := make([]float64, len(features))
+
+	g.trees = nil
+	g.validationLoss = nil
+	bestLoss, bestRounds := 0.0, 0
+	numSampled := int(g.subsample * float64(len(trainRows)))
+	if g.subsample <= 0 || g.subsample >= 1 || numSampled < 2*g.minSamplesLeaf {
+		numSampled = len(trainRows)
+	}
+
+	for round := 0; round < g.numTrees; round++ {
+		// The negative gradient of squared loss is the residual
+		for i := range residuals {
+			residuals[i] = target[i] - current[i]
+		}
+
+		sampled := append([]int(nil), trainRows...)
+		if numSampled < len(trainRows) {
+			rng.Shuffle(len(sampled), func(a, b int) { sampled[a], sampled[b] = sampled[b], sampled[a] })
+			sampled = sampled[:numSampled]
+		}
+
+		builder := histogramTreeBuilder{
+			binned:         binned,
+			edges:          edges,
+			gradients:      residuals,
+			weights:        weights,
+			maxDepth:       g.maxDepth,
+			minSamplesLeaf: g.minSamplesLeaf,
+		}
+		tree := builder.build(sampled, 0)
+		g.trees = append(g.trees, tree)
+		for i, row := range features {
+			current[i] += g.learningRate * tree.predict(row)
+		}
+
+		if numValidation == 0 {
+			continue
+		}
+		var loss, validationWeight float64
+		for _, i := range validationRows {
+			diff := target[i] - current[i]
+			loss += weights[i] * diff * diff
+			validationWeight += weights[i]
+		}
+		if validationWeight > 0 {
+			loss /= validationWeight
+		}
+		g.validationLoss = append(g.validationLoss, loss)
+		if bestRounds == 0 || loss < bestLoss {
+			bestLoss, bestRounds = loss, round+1
+		} else if round+1-bestRounds >= g.earlyStoppingRounds {
+			break
+		}
+	}
+
+	// Keep only the rounds up to the best validation loss
+	if numValidation > 0 {
+		g.trees = g.trees[:bestRounds]
+	}
+	return nil
+}
+
+func (g *gradientBoosting) Predict(featureRow []float64) float64 {
+	prediction := g.baseline
+	for _, tree := range g.trees {
+		prediction += g.learningRate * tree.predict(featureRow)
+	}
+	return prediction
+}
+
+// histogramEdges returns, for every feature, up to maxBins-1 increasing thresholds taken
+// from the quantiles of the given rows. Bin b holds values in (edges[b-1], edges[b]].
+func histogramEdges(features [][]float64, rows []int, maxBins int) [][]float64 {
+	numFeatures := len(features[0])
+	edges := make([][]float64, numFeatures)
+	values := make([]float64, len(rows))
+	for j := 0; j < numFeatures; j++ {
+		for k, i := range rows {
+			values[k] = features[i][j]
+		}
+		sort.Float64s(values)
+
+		var featureEdges []float64
+		for b := 1; b < maxBins; b++ {
+			edge := values[b*(len(values)-1)/maxBins]
+			if len(featureEdges) == 0 || edge > featureEdges[len(featureEdges)-1] {
+				featureEdges = append(featureEdges, edge)
+			}
+		}
+		// The largest value needs no threshold of its own; everything above the last edge
+		// falls into the final bin
+		if len(featureEdges) > 0 && featureEdges[len(featureEdges)-1] == values[len(values)-1] {
+			featureEdges = featureEdges[:len(featureEdges)-1]
+		}
+		edges[j] = featureEdges
+	}
+	return edges
+}
+
+// binFeatures maps every feature value to its histogram bin, stored column by column.
+func binFeatures(features [][]float64, edges [][]float64) [][]uint8 {
+	binned := make([][]uint8, len(edges))
+	for j := range edges {
+		binned[j] = make([]uint8, len(features))
+		for i, row := range features {
+			binned[j][i] = uint8(sort.SearchFloat64s(edges[j], row[j]))
+		}
+	}
+	return binned
+}
+
+// histogramTreeBuilder grows a regression tree on binned features that predicts the
+// weighted mean gradient in each leaf.
+type histogramTreeBuilder struct {
+	binned         [][]uint8
+	edges          [][]float64
+	gradients      []float64
+	weights        []float64
+	maxDepth       int
+	minSamplesLeaf int
+}
+
+// histogramSplit is the best split found for one feature.
+type histogramSplit struct {
+	gain float64
+	bin  int
+}
+
+func (b histogramTreeBuilder) build(rows []int, depth int) *treeNode {
+	var sum, sumWeights float64
+	for _, i := range rows {
+		sum += b.weights[i] * b.gradients[i]
+		sumWeights += b.weights[i]
+	}
+	leaf := &treeNode{samples: len(rows), weight: sumWeights}
+	if sumWeights > 0 {
+		leaf.value = sum / sumWeights
+	}
+	if depth >= b.maxDepth || len(rows) < 2*b.minSamplesLeaf || sumWeights == 0 {
+		return leaf
+	}
+
+	// Search every feature for its best split, one task per feature
+	splits := make([]histogramSplit, len(b.binned))
+	runTasks(len(b.binned), func(j int) {
+		splits[j] = b.bestSplit(j, rows, sum, sumWeights)
+	})
+
+	bestFeature := -1
+	for j, split := range splits {
+		if split.gain > 1e-12 && (bestFeature < 0 || split.gain > splits[bestFeature].gain) {
+			bestFeature = j
+		}
+	}
+	if bestFeature < 0 {
+		return leaf
+	}
+
+	bin := uint8(splits[bestFeature].bin)
+	var leftRows, rightRows []int
+	for _, i := range rows {
+		if b.binned[bestFeature][i] <= bin {
+			leftRows = append(leftRows, i)
+		} else {
+			rightRows = append(rightRows, i)
+		}
+	}
+
+	leaf.feature = bestFeature
+	leaf.threshold = b.edges[bestFeature][bin]
+	leaf.left = b.build(leftRows, depth+1)
+	leaf.right = b.build(rightRows, depth+1)
+	return leaf
+}
+
+// bestSplit scans the gradient histogram of feature j for the split that most reduces the
+// weighted squared error, i.e. maximizes sumL²/wL + sumR²/wR - sum²/w with sums of
+// weighted gradients and w the sum of weights.
+func (b histogramTreeBuilder) bestSplit(j int, rows []int, sum, sumWeights float64) histogramSplit {
+	numBins := len(b.edges[j]) + 1
+	sums := make([]float64, numBins)
+	binWeights := make([]float64, numBins)
+	counts := make([]int, numBins)
+	for _, i := range rows {
+		bin := b.binned[j][i]
+		sums[bin] += b.weights[i] * b.gradients[i]
+		binWeights[bin] += b.weights[i]
+		counts[bin]++
+	}
+
+	parent := sum * sum / sumWeights
+	best := histogramSplit{bin: -1}
+	var leftSum, leftWeight float64
+	leftCount := 0
+	for bin := 0; bin < numBins-1; bin++ {
+		leftSum += sums[bin]
+		leftWeight += binWeights[bin]
+		leftCount += counts[bin]
+		rightCount := len(rows) - leftCount
+		if leftCount < b.minSamplesLeaf {
+			continue
+		}
+		if rightCount < b.minSamplesLeaf {
+			break
+		}
+		rightSum, rightWeight := sum-leftSum, sumWeights-leftWeight
+		if leftWeight <= 0 || rightWeight <= 0 {
+			continue
+		}
+		gain := leftSum*leftSum/leftWeight + rightSum*rightSum/rightWeight - parent
+		if gain > best.gain {
+			best = histogramSplit{gain: gain, bin: bin}
+		}
+	}
+	return best
+}
@@ -2,6 +2,8 @@ module example.com/mod
 
 go 1.18
 
+require gonum.org/v1/gonum v0.13.0
+
 require (
 	github.com/chewxy/math32 v1.10.1 // indirect
 	github.com/emicklei/go-restful/v3 v3.9.0 // indirect
@@ -34,6 +36,5 @@ require (
 	golang.org/x/exp v0.0.0-20230728194245-b0cb94b80691 // indirect
 	golang.org/x/sync v0.3.0 // indirect
 	golang.org/x/tools v0.11.0 // indirect
-	gonum.org/v1/gonum v0.13.0 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.2.1 // indirect
 )
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestKernelRidgeFitsNonlinearTarget(t *testing.T) {
+	// Create sample data from a sine curve
+	var features [][]float64
+	var target []float64
+	for i := 0; i < 60; i++ {
+		x := float64(i) / 10
+		features = append(features, []float64{x})
+		target = append(target, math.Sin(x))
+	}
+
+	for _, k := range []kernel{rbfKernel{lengthScale: 1, variance: 1}, maternKernel{nu: 2.5, lengthScale: 1, variance: 1}} {
+		model := &kernelRidge{kernel: k, lambda: 1e-3}
+		if err := model.Fit(features, target); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if prediction := model.Predict([]float64{2.05}); math.Abs(prediction-math.Sin(2.05)) > 0.02 {
+			t.Errorf("Unexpected %T prediction. Expected %f, got %f", k, math.Sin(2.05), prediction)
+		}
+	}
+}
+
+func TestGaussianProcessVariance(t *testing.T) {
+	// Create sample data on [0, 3] only
+	var features [][]float64
+	var target []float64
+	for i := 0; i <= 30; i++ {
+		x := float64(i) / 10
+		features = append(features, []float64{x})
+		target = append(target, 2*x+math.Sin(3*x))
+	}
+
+	gp := newGaussianProcess(rbfKernel{lengthScale: 1, variance: 1})
+	gp.standardize = false
+	if err := gp.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Optimizing should do at least as well as the starting hyperparameters
+	start := &gaussianProcess{kernel: rbfKernel{lengthScale: 1, variance: 1}, noise: 0.1}
+	if err := start.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if gp.logMarginalLikelihood < start.logMarginalLikelihood {
+		t.Errorf("Unexpected log marginal likelihood. Expected at least %f, got %f", start.logMarginalLikelihood, gp.logMarginalLikelihood)
+	}
+
+	mean, inside := gp.predictWithVariance([]float64{1.55})
+	expected := 2*1.55 + math.Sin(3*1.55)
+	if math.Abs(mean-expected) > 0.05 {
+		t.Errorf("Unexpected posterior mean. Expected %f, got %f", expected, mean)
+	}
+	_, outside := gp.predictWithVariance([]float64{6})
+	if !(outside > inside) {
+		t.Errorf("Expected more variance away from the data: %f inside, %f outside", inside, outside)
+	}
+}
+
+func TestGaussianProcessRejectsInfeasibleStart(t *testing.T) {
+	features := [][]float64{{0}, {1}, {2}, {3}, {4}}
+	target := []float64{1, 3, 5, 7, 9}
+
+	// A zero offset is -Inf on the log scale the hyperparameters are optimized on
+	gp := newGaussianProcess(linearKernel{variance: 1})
+	if err := gp.Fit(features, target); err == nil {
+		t.Errorf("Expected an error for a linear kernel with a zero offset")
+	}
+
+	gp = newGaussianProcess(linearKernel{variance: 1, offset: 1})
+	if err := gp.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if prediction := gp.Predict([]float64{2.5}); math.Abs(prediction-6) > 0.1 {
+		t.Errorf("Unexpected prediction. Expected about %f, got %f", 6.0, prediction)
+	}
+}
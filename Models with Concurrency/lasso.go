@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/stat"
+)
+
+// lassoModel is L1-penalized least squares, minimizing
+//
+//	(1/2n) Σ (y_i - b0 - x_iᵀb)² + λ Σ |b_j|
+//
+// over standardized features by cyclic coordinate descent. With sample weights the sum
+// is weighted and n is the total weight. Unlike ridge, the penalty sets
+// coefficients exactly to zero, so the fit also selects features.
+type lassoModel struct {
+	lambda        float64
+	maxIterations int
+	tolerance     float64 // on the largest coefficient change in one sweep
+
+	coefficients []float64 // intercept first, on the original feature scale
+	iterations   int
+}
+
+// newLasso returns a lassoModel with penalty lambda on the standardized scale.
+func newLasso(lambda float64) *lassoModel {
+	return &lassoModel{lambda: lambda, maxIterations: 1000, tolerance: 1e-7}
+}
+
+func (m *lassoModel) Fit(features [][]float64, target []float64) error {
+	return m.FitWeighted(features, target, nil)
+}
+
+func (m *lassoModel) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	if m.lambda < 0 {
+		return fmt.Errorf("lambda must be non-negative, got %f", m.lambda)
+	}
+	if weights != nil {
+		if _, err := checkWeights(weights, len(target)); err != nil {
+			return err
+		}
+	}
+	scaler := fitWeightedScaler(features, weights)
+	columns, centered := lassoDesign(features, target, weights, scaler)
+	slopes := make([]float64, len(columns))
+	m.iterations = lassoCoordinateDescent(columns, centered, m.lambda, slopes, m.maxIterations, m.tolerance)
+	m.coefficients = unscaleCoefficients(slopes, stat.Mean(target, weights), scaler)
+	return nil
+}
+
+func (m *lassoModel) Predict(featureRow []float64) float64 {
+	return predictLin(featureRow, m.coefficients)
+}
+
+func (m *lassoModel) linearCoefficients() []float64 {
+	return m.coefficients
+}
+
+// lassoDesign returns the standardized feature columns and the centered target. With
+// weights, row i is also multiplied by sqrt(n w_i / Σw), so the unweighted objective on
+// the result is the weighted objective on the rows.
+func lassoDesign(features [][]float64, target []float64, weights []float64, scaler standardScaler) ([][]float64, []float64) {
+	root := make([]float64, len(target))
+	for i := range root {
+		root[i] = 1
+	}
+	if weights != nil {
+		scale := float64(len(target)) / floats.Sum(weights)
+		for i, w := range weights {
+			root[i] = math.Sqrt(scale * w)
+		}
+	}
+	columns := make([][]float64, len(features[0]))
+	for j := range columns {
+		columns[j] = make([]float64, len(features))
+		for i, row := range features {
+			columns[j][i] = root[i] * (row[j] - scaler.means[j]) / scaler.scale[j]
+		}
+	}
+	mean := stat.Mean(target, weights)
+	centered := make([]float64, len(target))
+	for i, value := range target {
+		centered[i] = root[i] * (value - mean)
+	}
+	return columns, centered
+}
+
+// lassoMaxLambda is the smallest penalty at which every standardized slope is zero.
+func lassoMaxLambda(columns [][]float64, centered []float64) float64 {
+	var largest float64
+	for _, column := range columns {
+		largest = math.Max(largest, math.Abs(floats.Dot(column, centered)))
+	}
+	return largest / float64(len(centered))
+}
+
+// lassoCoordinateDescent minimizes the lasso objective over the columns and centered y,
+// starting from slopes and updating them in place, and returns the number of sweeps.
+// Each coordinate update is the soft-thresholded least squares fit to the partial
+// residual; the full residual is kept up to date so a sweep costs O(np).
+func lassoCoordinateDescent(columns [][]float64, y []float64, lambda float64, slopes []float64, maxIterations int, tolerance float64) int {
+	n := float64(len(y))
+	residual := make([]float64, len(y))
+	copy(residual, y)
+	squaredNorms := make([]float64, len(columns))
+	for j, column := range columns {
+		floats.AddScaled(residual, -slopes[j], column)
+		squaredNorms[j] = floats.Dot(column, column) / n
+	}
+
+	for iteration := 1; iteration <= maxIterations; iteration++ {
+		var largestChange float64
+		for j, column := range columns {
+			if squaredNorms[j] == 0 {
+				continue
+			}
+			rho := floats.Dot(column, residual)/n + squaredNorms[j]*slopes[j]
+			updated := softThreshold(rho, lambda) / squaredNorms[j]
+			if change := updated - slopes[j]; change != 0 {
+				floats.AddScaled(residual, -change, column)
+				largestChange = math.Max(largestChange, math.Abs(change))
+				slopes[j] = updated
+			}
+		}
+		if largestChange < tolerance {
+			return iteration
+		}
+	}
+	return maxIterations
+}
+
+// softThreshold shrinks value toward zero by threshold.
+func softThreshold(value, threshold float64) float64 {
+	switch {
+	case value > threshold:
+		return value - threshold
+	case value < -threshold:
+		return value + threshold
+	}
+	return 0
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// logisticRegression models P(y = 1 | x) = 1 / (1 + exp(-(b0 + xᵀb))) for 0/1 labels such
+// as "the tract is above the median value" or chas. It minimizes the weighted mean
+// log-loss plus an optional penalty on the standardized slopes (never the intercept):
+// (λ/2)|b|² for "l2" or λ|b|₁ for "l1".
+//
+// The "newton" solver is IRLS: Newton steps with step halving, where each step is a
+// weighted least squares fit. With the L1 penalty each weighted least squares problem is
+// solved by coordinate descent instead (proximal Newton, as in glmnet). The "lbfgs" solver
+// uses lbfgs, which handles L1 through OWL-QN. A solver that reaches maxIterations, or
+// finds no step that lowers the objective, makes Fit return an error.
+//
+// Predict returns the probability of the positive class, so the model works wherever a
+// regressor does, e.g. with crossValidatedPredictions.
+type logisticRegression struct {
+	penalty       string  // "none", "l2" or "l1"
+	lambda        float64 // penalty strength on the standardized scale
+	solver        string  // "newton" or "lbfgs"
+	maxIterations int
+	tolerance     float64
+	memory        int // correction pairs kept by lbfgs
+
+	scaler       standardScaler
+	coefficients []float64 // log-odds, intercept first, on the original feature scale
+	iterations   int
+	converged    bool
+}
+
+// newLogisticRegression returns a logisticRegression with a light L2 penalty, fit by
+// Newton's method.
+func newLogisticRegression() *logisticRegression {
+	return &logisticRegression{penalty: "l2", lambda: 1e-3, solver: "newton", maxIterations: 100, tolerance: 1e-8, memory: 10}
+}
+
+func (m *logisticRegression) Fit(features [][]float64, labels []float64) error {
+	return m.FitWeighted(features, labels, nil)
+}
+
+func (m *logisticRegression) FitWeighted(features [][]float64, labels []float64, weights []float64) error {
+	if len(features) != len(labels) {
+		return fmt.Errorf("features and labels length mismatch: %d vs %d", len(features), len(labels))
+	}
+	for i, y := range labels {
+		if y != 0 && y != 1 {
+			return fmt.Errorf("labels must be 0 or 1, got %v at row %d", y, i)
+		}
+	}
+	switch m.penalty {
+	case "none", "l2", "l1":
+	default:
+		return fmt.Errorf("unknown penalty %q", m.penalty)
+	}
+	if m.solver != "newton" && m.solver != "lbfgs" {
+		return fmt.Errorf("unknown solver %q", m.solver)
+	}
+	weights, err := checkWeights(weights, len(labels))
+	if err != nil {
+		return err
+	}
+	// Normalize the weights so the loss is a weighted mean
+	normalized := make([]float64, len(weights))
+	floats.ScaleTo(normalized, 1/floats.Sum(weights), weights)
+
+	m.scaler = fitScaler(features)
+	numColumns := len(features[0]) + 1
+	design := mat.NewDense(len(features), numColumns, nil)
+	for i, row := range m.scaler.transformAll(features) {
+		design.Set(i, 0, 1)
+		for j, value := range row {
+			design.Set(i, j+1, value)
+		}
+	}
+	problem := logisticProblem{design: design, labels: labels, weights: normalized}
+	if m.penalty == "l2" {
+		problem.l2 = m.lambda
+	}
+	if m.penalty == "l1" {
+		problem.l1 = m.lambda
+	}
+
+	var beta []float64
+	switch {
+	case m.solver == "lbfgs":
+		l1 := make([]float64, numColumns)
+		for j := 1; j < numColumns; j++ {
+			l1[j] = problem.l1
+		}
+		beta, m.iterations, m.converged = lbfgs(problem.smoothLoss, make([]float64, numColumns), l1, m.memory, m.maxIterations, m.tolerance)
+	case m.penalty == "l1":
+		beta, m.iterations, m.converged = problem.proximalNewton(m.maxIterations, m.tolerance)
+	default:
+		beta, m.iterations, m.converged, err = problem.newton(m.maxIterations, m.tolerance)
+		if err != nil {
+			return err
+		}
+	}
+	m.coefficients = unscaleCoefficients(beta[1:], beta[0], m.scaler)
+	if !m.converged {
+		return fmt.Errorf("%s solver did not converge after %d iterations; the coefficients are from the last accepted step", m.solver, m.iterations)
+	}
+	return nil
+}
+
+// Predict returns the probability that the label of featureRow is 1.
+func (m *logisticRegression) Predict(featureRow []float64) float64 {
+	return sigmoid(predictLin(featureRow, m.coefficients))
+}
+
+// predictClass returns 1 when the predicted probability is at least 0.5, and 0 otherwise.
+func (m *logisticRegression) predictClass(featureRow []float64) float64 {
+	if m.Predict(featureRow) >= 0.5 {
+		return 1
+	}
+	return 0
+}
+
+func (m *logisticRegression) linearCoefficients() []float64 {
+	return m.coefficients
+}
+
+// logisticProblem is the penalized log-loss on a standardized design whose first column
+// is the intercept, with weights summing to 1.
+type logisticProblem struct {
+	design  *mat.Dense
+	labels  []float64
+	weights []float64
+	l2, l1  float64
+}
+
+// smoothLoss returns the log-loss plus the L2 penalty at beta and writes its gradient.
+func (p logisticProblem) smoothLoss(beta, gradient []float64) float64 {
+	var eta mat.VecDense
+	eta.MulVec(p.design, mat.NewVecDense(len(beta), beta))
+	residuals := make([]float64, len(p.labels))
+	var loss float64
+	for i, y := range p.labels {
+		e := eta.AtVec(i)
+		loss += p.weights[i] * (softplus(e) - y*e)
+		residuals[i] = p.weights[i] * (sigmoid(e) - y)
+	}
+	gradientVec := mat.NewVecDense(len(gradient), gradient)
+	gradientVec.MulVec(p.design.T(), mat.NewVecDense(len(residuals), residuals))
+	for j := 1; j < len(beta); j++ {
+		loss += p.l2 / 2 * beta[j] * beta[j]
+		gradient[j] += p.l2 * beta[j]
+	}
+	return loss
+}
+
+// objective is smoothLoss plus the L1 penalty.
+func (p logisticProblem) objective(beta []float64) float64 {
+	loss := p.smoothLoss(beta, make([]float64, len(beta)))
+	return loss + p.l1*floats.Norm(beta[1:], 1)
+}
+
+// curvature returns the probabilities and the IRLS weights w_i p_i (1 - p_i) at beta.
+func (p logisticProblem) curvature(beta []float64) ([]float64, []float64) {
+	var eta mat.VecDense
+	eta.MulVec(p.design, mat.NewVecDense(len(beta), beta))
+	probabilities := make([]float64, len(p.labels))
+	irlsWeights := make([]float64, len(p.labels))
+	for i := range p.labels {
+		probabilities[i] = sigmoid(eta.AtVec(i))
+		irlsWeights[i] = p.weights[i] * probabilities[i] * (1 - probabilities[i])
+	}
+	return probabilities, irlsWeights
+}
+
+// newton runs Newton's method with step halving: H δ = g with H = XᵀDX + λI' and D the
+// IRLS weights.
+func (p logisticProblem) newton(maxIterations int, tolerance float64) ([]float64, int, bool, error) {
+	_, numColumns := p.design.Dims()
+	beta := make([]float64, numColumns)
+	gradient := make([]float64, numColumns)
+	value := p.smoothLoss(beta, gradient)
+	for iteration := 1; iteration <= maxIterations; iteration++ {
+		_, irlsWeights := p.curvature(beta)
+		var weighted mat.Dense
+		weighted.Apply(func(i, j int, v float64) float64 { return v * irlsWeights[i] }, p.design)
+		hessian := mat.NewSymDense(numColumns, nil)
+		var product mat.Dense
+		product.Mul(p.design.T(), &weighted)
+		for r := 0; r < numColumns; r++ {
+			for c := r; c < numColumns; c++ {
+				hessian.SetSym(r, c, product.At(r, c))
+			}
+			if r > 0 {
+				hessian.SetSym(r, r, hessian.At(r, r)+p.l2)
+			}
+		}
+		var chol mat.Cholesky
+		if ok := chol.Factorize(hessian); !ok {
+			return nil, iteration, false, fmt.Errorf("hessian is singular; the classes may be separable, try a penalty")
+		}
+		var delta mat.VecDense
+		if err := chol.SolveVecTo(&delta, mat.NewVecDense(numColumns, gradient)); err != nil {
+			return nil, iteration, false, err
+		}
+
+		// Halve the step until the loss does not increase
+		step := 1.0
+		candidate := make([]float64, numColumns)
+		candidateGradient := make([]float64, numColumns)
+		var candidateValue float64
+		accepted := false
+		for halvings := 0; halvings < 30; halvings++ {
+			for j := range candidate {
+				candidate[j] = beta[j] - step*delta.AtVec(j)
+			}
+			if candidateValue = p.smoothLoss(candidate, candidateGradient); candidateValue <= value {
+				accepted = true
+				break
+			}
+			step /= 2
+		}
+		if !accepted {
+			// No step lowers the loss: keep beta and stop unconverged
+			return beta, iteration, false, nil
+		}
+		change := step * floats.Norm(delta.RawVector().Data, math.Inf(1))
+		beta, gradient, value = candidate, candidateGradient, candidateValue
+		if change < tolerance {
+			return beta, iteration, true, nil
+		}
+	}
+	return beta, maxIterations, false, nil
+}
+
+// proximalNewton minimizes the L1-penalized loss: every iteration fits the penalized
+// weighted least squares approximation around beta by coordinate descent, then moves
+// toward its solution with step halving on the true objective.
+func (p logisticProblem) proximalNewton(maxIterations int, tolerance float64) ([]float64, int, bool) {
+	numRows, numColumns := p.design.Dims()
+	beta := make([]float64, numColumns)
+	value := p.objective(beta)
+	columns := make([][]float64, numColumns)
+	for j := range columns {
+		columns[j] = mat.Col(nil, j, p.design)
+	}
+
+	for iteration := 1; iteration <= maxIterations; iteration++ {
+		probabilities, irlsWeights := p.curvature(beta)
+		// Residuals of the working response z = η + (y - p) / (p(1 - p)) from η
+		residuals := make([]float64, numRows)
+		for i, y := range p.labels {
+			variance := math.Max(probabilities[i]*(1-probabilities[i]), 1e-10)
+			residuals[i] = (y - probabilities[i]) / variance
+			irlsWeights[i] = math.Max(irlsWeights[i], p.weights[i]*1e-10)
+		}
+
+		target := append([]float64(nil), beta...)
+		for sweep := 0; sweep < 1000; sweep++ {
+			var largestChange float64
+			for j, column := range columns {
+				var curvature, rho float64
+				for i, x := range column {
+					curvature += irlsWeights[i] * x * x
+					rho += irlsWeights[i] * x * residuals[i]
+				}
+				if curvature == 0 {
+					continue
+				}
+				rho += curvature * target[j]
+				updated := rho / curvature
+				if j > 0 {
+					updated = softThreshold(rho, p.l1) / curvature
+				}
+				if change := updated - target[j]; change != 0 {
+					floats.AddScaled(residuals, -change, column)
+					largestChange = math.Max(largestChange, math.Abs(change))
+					target[j] = updated
+				}
+			}
+			if largestChange < tolerance {
+				break
+			}
+		}
+
+		step := 1.0
+		candidate := make([]float64, numColumns)
+		var candidateValue float64
+		accepted := false
+		for halvings := 0; halvings < 30; halvings++ {
+			for j := range candidate {
+				candidate[j] = beta[j] + step*(target[j]-beta[j])
+			}
+			if candidateValue = p.objective(candidate); candidateValue <= value {
+				accepted = true
+				break
+			}
+			step /= 2
+		}
+		if !accepted {
+			return beta, iteration, false
+		}
+		var change float64
+		for j := range beta {
+			change = math.Max(change, math.Abs(candidate[j]-beta[j]))
+		}
+		beta, value = candidate, candidateValue
+		if change < tolerance {
+			return beta, iteration, true
+		}
+	}
+	return beta, maxIterations, false
+}
+
+// sigmoid is the logistic function 1 / (1 + exp(-x)).
+func sigmoid(x float64) float64 {
+	if x >= 0 {
+		return 1 / (1 + math.Exp(-x))
+	}
+	e := math.Exp(x)
+	return e / (1 + e)
+}
+
+// softplus is log(1 + exp(x)) without overflow.
+func softplus(x float64) float64 {
+	return math.Max(x, 0) + math.Log1p(math.Exp(-math.Abs(x)))
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// logisticData returns labels drawn with P(y = 1) = sigmoid(-1 + 2*x1 - x2) on the
+// features of noisyLinearData, whose third feature has no effect.
+func logisticData(n int) ([][]float64, []float64) {
+	features, _ := noisyLinearData(n)
+	rng := rand.New(rand.NewSource(2))
+	labels := make([]float64, n)
+	for i, row := range features {
+		if rng.Float64() < sigmoid(-1+2*row[0]-row[1]) {
+			labels[i] = 1
+		}
+	}
+	return features, labels
+}
+
+func TestLogisticRegressionSolversAgree(t *testing.T) {
+	features, labels := logisticData(500)
+	for _, penalty := range []string{"none", "l2", "l1"} {
+		newton := newLogisticRegression()
+		newton.penalty = penalty
+		newton.lambda = 0.01
+		if err := newton.Fit(features, labels); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		quasiNewton := newLogisticRegression()
+		quasiNewton.penalty = penalty
+		quasiNewton.lambda = 0.01
+		quasiNewton.solver = "lbfgs"
+		if err := quasiNewton.Fit(features, labels); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !newton.converged || !quasiNewton.converged {
+			t.Errorf("Expected both solvers to converge with the %s penalty", penalty)
+		}
+		for j, expected := range newton.coefficients {
+			if math.Abs(quasiNewton.coefficients[j]-expected) > 1e-4 {
+				t.Errorf("Unexpected lbfgs coefficient %d with the %s penalty. Expected %f, got %f", j, penalty, expected, quasiNewton.coefficients[j])
+			}
+		}
+	}
+
+	model := newLogisticRegression()
+	model.penalty = "none"
+	if err := model.Fit(features, labels); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for j, expected := range []float64{-1, 2, -1, 0} {
+		if math.Abs(model.coefficients[j]-expected) > 0.6 {
+			t.Errorf("Unexpected coefficient %d. Expected about %f, got %f", j, expected, model.coefficients[j])
+		}
+	}
+}
+
+func TestLogisticRegressionL1ZeroesIrrelevantFeature(t *testing.T) {
+	features, labels := logisticData(500)
+	for _, solver := range []string{"newton", "lbfgs"} {
+		model := newLogisticRegression()
+		model.penalty = "l1"
+		model.lambda = 0.05
+		model.solver = solver
+		if err := model.Fit(features, labels); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if model.coefficients[3] != 0 {
+			t.Errorf("Unexpected coefficient of the irrelevant feature with %s. Expected 0, got %f", solver, model.coefficients[3])
+		}
+		if model.coefficients[1] <= 0 || model.coefficients[2] >= 0 {
+			t.Errorf("Unexpected signs with %s: %v", solver, model.coefficients)
+		}
+	}
+}
+
+func TestLogisticRegressionRejectsInvalidLabels(t *testing.T) {
+	model := newLogisticRegression()
+	if err := model.Fit([][]float64{{1}, {2}}, []float64{0, 2}); err == nil {
+		t.Errorf("Expected an error for a label that is not 0 or 1")
+	}
+}
+
+func TestLogisticRegressionReportsNonConvergence(t *testing.T) {
+	features, labels := logisticData(200)
+	for _, solver := range []string{"newton", "lbfgs"} {
+		model := newLogisticRegression()
+		model.solver = solver
+		model.maxIterations = 1
+		if err := model.Fit(features, labels); err == nil || model.converged {
+			t.Errorf("Expected %s to report that one iteration did not converge", solver)
+		}
+	}
+}
+
+func TestLogisticRegressionOnBoston(t *testing.T) {
+	features, targets, _, err := loadMultiTargetCSV("boston.csv", "mv")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	labels := binaryLabels(targetColumn(targets, 0), 21.2)
+	model := newLogisticRegression()
+	if err := model.Fit(features, labels); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	probabilities := predictAll(model, features)
+	if auc := rocAUC(probabilities, labels); auc < 0.9 {
+		t.Errorf("Unexpected ROC AUC of above-median value. Expected above 0.9, got %f", auc)
+	}
+	if acc := accuracy(probabilities, labels, 0.5); acc < 0.8 {
+		t.Errorf("Unexpected accuracy of above-median value. Expected above 0.8, got %f", acc)
+	}
+}
+
+func TestClassificationMetrics(t *testing.T) {
+	probabilities := []float64{0.9, 0.8, 0.6, 0.4, 0.3, 0.6}
+	labels := []float64{1, 1, 0, 1, 0, 1}
+
+	if acc := accuracy(probabilities, labels, 0.5); math.Abs(acc-4.0/6) > 1e-12 {
+		t.Errorf("Unexpected accuracy. Expected %f, got %f", 4.0/6, acc)
+	}
+	precision, recall := precisionRecall(probabilities, labels, 0.5)
+	if math.Abs(precision-0.75) > 1e-12 || math.Abs(recall-0.75) > 1e-12 {
+		t.Errorf("Unexpected precision and recall. Expected 0.75 and 0.75, got %f and %f", precision, recall)
+	}
+	// 8 pairs: the tied 0.6 pair counts one half and 0.4 < 0.6 is wrong
+	if auc := rocAUC(probabilities, labels); math.Abs(auc-6.5/8) > 1e-12 {
+		t.Errorf("Unexpected ROC AUC. Expected %f, got %f", 6.5/8, auc)
+	}
+	if loss := logLoss([]float64{0.5, 1}, []float64{1, 1}); math.Abs(loss-math.Log(2)/2) > 1e-12 {
+		t.Errorf("Unexpected log-loss. Expected %f, got %f", math.Log(2)/2, loss)
+	}
+
+	meanPredicted, observed, counts := calibrationCurve(probabilities, labels, 2)
+	if len(counts) != 2 || counts[0] != 2 || counts[1] != 4 {
+		t.Fatalf("Unexpected calibration counts. Expected [2 4], got %v", counts)
+	}
+	if math.Abs(meanPredicted[0]-0.35) > 1e-12 || math.Abs(observed[1]-0.75) > 1e-12 {
+		t.Errorf("Unexpected calibration curve: %v %v", meanPredicted, observed)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// multiOutputRegressor is a model fit to a target matrix with one row per sample and one
+// column per output, such as the price and the tax rate of every tract.
+type multiOutputRegressor interface {
+	FitMulti(features [][]float64, targets [][]float64) error
+	PredictMulti(featureRow []float64) []float64
+}
+
+// multiLinearModel is OLS (lambda 0) or ridge regression on every output at once. The
+// design is factorized a single time and solved against all target columns together,
+// which gives the same coefficients as separate fits per output.
+type multiLinearModel struct {
+	lambda       float64
+	coefficients *mat.Dense // (features + 1) × outputs, intercept in the first row
+}
+
+// perOutputRegressor fits an independent model from newModel to every output column,
+// concurrently through runTasks, so any regressor can take a target matrix.
+type perOutputRegressor struct {
+	newModel func() regressor
+
+	models []regressor
+}
+
+func (m *multiLinearModel) FitMulti(features [][]float64, targets [][]float64) error {
+	if err := checkTargets(features, targets); err != nil {
+		return err
+	}
+	numColumns := len(features[0]) + 1
+	numOutputs := len(targets[0])
+
+	design := mat.NewDense(len(features), numColumns, nil)
+	for i, row := range features {
+		design.Set(i, 0, 1)
+		for j, value := range row {
+			design.Set(i, j+1, value)
+		}
+	}
+	targetMatrix := mat.NewDense(len(targets), numOutputs, nil)
+	for i, row := range targets {
+		targetMatrix.SetRow(i, row)
+	}
+
+	var solution mat.Dense
+	if m.lambda == 0 {
+		// Least squares through the QR factorization, like linearRegression
+		if err := solution.Solve(design, targetMatrix); err != nil {
+			return err
+		}
+	} else {
+		// (XᵀX + λI) B = XᵀY with the intercept unpenalized, like ridgeRegression
+		var lhs mat.Dense
+		lhs.Mul(design.T(), design)
+		for j := 1; j < numColumns; j++ {
+			lhs.Set(j, j, lhs.At(j, j)+m.lambda)
+		}
+		var rhs mat.Dense
+		rhs.Mul(design.T(), targetMatrix)
+		if err := solution.Solve(&lhs, &rhs); err != nil {
+			if cond, ok := err.(mat.Condition); !ok || math.IsInf(float64(cond), 1) {
+				return err
+			}
+		}
+	}
+	m.coefficients = &solution
+	return nil
+}
+
+func (m *multiLinearModel) PredictMulti(featureRow []float64) []float64 {
+	numColumns, numOutputs := m.coefficients.Dims()
+	if len(featureRow)+1 != numColumns {
+		panic("Feature row and coefficients length mismatch")
+	}
+	var predictions mat.VecDense
+	predictions.MulVec(m.coefficients.T(), mat.NewVecDense(numColumns, append([]float64{1}, featureRow...)))
+	return predictions.RawVector().Data[:numOutputs]
+}
+
+// outputCoefficients returns the coefficients of output k, intercept first like
+// linearRegression.
+func (m *multiLinearModel) outputCoefficients(k int) []float64 {
+	return mat.Col(nil, k, m.coefficients)
+}
+
+func (m *perOutputRegressor) FitMulti(features [][]float64, targets [][]float64) error {
+	if err := checkTargets(features, targets); err != nil {
+		return err
+	}
+	numOutputs := len(targets[0])
+	models := make([]regressor, numOutputs)
+	errs := make([]error, numOutputs)
+	runTasks(numOutputs, func(k int) {
+		models[k] = m.newModel()
+		errs[k] = models[k].Fit(features, targetColumn(targets, k))
+	})
+	for k, err := range errs {
+		if err != nil {
+			return fmt.Errorf("output %d: %v", k, err)
+		}
+	}
+	m.models = models
+	return nil
+}
+
+func (m *perOutputRegressor) PredictMulti(featureRow []float64) []float64 {
+	predictions := make([]float64, len(m.models))
+	for k, model := range m.models {
+		predictions[k] = model.Predict(featureRow)
+	}
+	return predictions
+}
+
+// predictAllMulti runs model.PredictMulti on every row of features.
+func predictAllMulti(model multiOutputRegressor, features [][]float64) [][]float64 {
+	predictions := make([][]float64, len(features))
+	for i, row := range features {
+		predictions[i] = model.PredictMulti(row)
+	}
+	return predictions
+}
+
+// checkTargets validates a target matrix against the features: one row per feature row,
+// and the same positive number of outputs in every row.
+func checkTargets(features [][]float64, targets [][]float64) error {
+	if len(features) != len(targets) {
+		return fmt.Errorf("features and targets length mismatch: %d vs %d", len(features), len(targets))
+	}
+	if len(targets) == 0 || len(targets[0]) == 0 {
+		return fmt.Errorf("targets must have at least one row and one output")
+	}
+	for i, row := range targets {
+		if len(row) != len(targets[0]) {
+			return fmt.Errorf("target row %d has %d outputs, want %d", i, len(row), len(targets[0]))
+		}
+	}
+	return nil
+}
+
+// targetColumn returns output k of every row of a target matrix.
+func targetColumn(targets [][]float64, k int) []float64 {
+	column := make([]float64, len(targets))
+	for i, row := range targets {
+		column[i] = row[k]
+	}
+	return column
+}
+
+// perOutputMetric applies a single-output metric such as rootMeanSquaredError to every
+// output column.
+func perOutputMetric(metric func(predictions []float64, targets []float64) float64, predictions, targets [][]float64) []float64 {
+	if len(predictions) != len(targets) {
+		panic("Predictions and targets length mismatch")
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	scores := make([]float64, len(targets[0]))
+	for k := range scores {
+		scores[k] = metric(targetColumn(predictions, k), targetColumn(targets, k))
+	}
+	return scores
+}
+
+// multiOutputReport renders RMSE, MAE, MAPE and R² for every output, labelled by
+// outputNames.
+func multiOutputReport(predictions, targets [][]float64, outputNames []string) string {
+	rmse := perOutputMetric(rootMeanSquaredError, predictions, targets)
+	mae := perOutputMetric(meanAbsoluteError, predictions, targets)
+	mape := perOutputMetric(meanAbsolutePercentageError, predictions, targets)
+	r2 := perOutputMetric(rSquared, predictions, targets)
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-10s %10s %10s %10s %8s\n", "output", "RMSE", "MAE", "MAPE", "R2")
+	for k := range rmse {
+		fmt.Fprintf(&sb, "%-10s %10.4f %10.4f %9.2f%% %8.4f\n", featureName(outputNames, k), rmse[k], mae[k], 100*mape[k], r2[k])
+	}
+	return sb.String()
+}
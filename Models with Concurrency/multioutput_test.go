@@ -0,0 +1,105 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// twoOutputData returns noisyLinearData with a second output, 3 - x2 + 4*x3 plus noise.
+func twoOutputData(n int) ([][]float64, [][]float64) {
+	features, target := noisyLinearData(n)
+	targets := make([][]float64, n)
+	for i, row := range features {
+		targets[i] = []float64{target[i], 3 - row[1] + 4*row[2] + 0.1*math.Sin(float64(i))}
+	}
+	return features, targets
+}
+
+func TestMultiLinearModelMatchesPerOutputFits(t *testing.T) {
+	features, targets := twoOutputData(100)
+
+	for _, lambda := range []float64{0, 2} {
+		joint := &multiLinearModel{lambda: lambda}
+		if err := joint.FitMulti(features, targets); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		for k := 0; k < 2; k++ {
+			expected := linearRegression(features, targetColumn(targets, k))
+			if lambda > 0 {
+				expected = ridgeRegression(features, targetColumn(targets, k), lambda)
+			}
+			got := joint.outputCoefficients(k)
+			for j := range expected {
+				if math.Abs(got[j]-expected[j]) > 1e-8 {
+					t.Errorf("Unexpected coefficient %d of output %d with lambda %v. Expected %f, got %f", j, k, lambda, expected[j], got[j])
+				}
+			}
+		}
+	}
+
+	// Wrapping a single-output model per column gives the same predictions as the joint fit
+	joint := &multiLinearModel{}
+	perOutput := &perOutputRegressor{newModel: func() regressor { return &linearModel{} }}
+	if err := joint.FitMulti(features, targets); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := perOutput.FitMulti(features, targets); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	a, b := joint.PredictMulti(features[3]), perOutput.PredictMulti(features[3])
+	for k := range a {
+		if math.Abs(a[k]-b[k]) > 1e-8 {
+			t.Errorf("Unexpected prediction of output %d. Expected %f, got %f", k, a[k], b[k])
+		}
+	}
+
+	targets[5] = []float64{1}
+	if err := joint.FitMulti(features, targets); err == nil {
+		t.Errorf("Expected an error for a ragged target matrix")
+	}
+}
+
+func TestMultiOutputMetrics(t *testing.T) {
+	predictions := [][]float64{{1, 10}, {2, 20}, {3, 33}}
+	targets := [][]float64{{1, 10}, {2, 20}, {4, 30}}
+
+	mae := perOutputMetric(meanAbsoluteError, predictions, targets)
+	if math.Abs(mae[0]-1.0/3) > 1e-12 || math.Abs(mae[1]-1) > 1e-12 {
+		t.Errorf("Unexpected per-output MAE. Expected [0.333333 1], got %v", mae)
+	}
+	r2 := perOutputMetric(rSquared, predictions, targets)
+	// Output 0: RSS = 1 and TSS = 14/3
+	if expected := 1 - 3.0/14; math.Abs(r2[0]-expected) > 1e-12 {
+		t.Errorf("Unexpected R² of output 0. Expected %f, got %f", expected, r2[0])
+	}
+	report := multiOutputReport(predictions, targets, []string{"mv", "tax"})
+	if !strings.Contains(report, "mv") || !strings.Contains(report, "tax") {
+		t.Errorf("Expected the report to name every output:\n%s", report)
+	}
+}
+
+func TestLoadMultiTargetCSV(t *testing.T) {
+	features, targets, names, err := loadMultiTargetCSV("boston.csv", "mv", "tax")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	allNames, err := loadColumnNames("boston.csv")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(names) != len(allNames)-1 || indexOf(names, "tax") >= 0 {
+		t.Errorf("Unexpected feature names: %v", names)
+	}
+	if len(features[0]) != len(names) || len(targets[0]) != 2 {
+		t.Errorf("Unexpected shapes: %d features and %d outputs", len(features[0]), len(targets[0]))
+	}
+	// The first tract sold for 24 with a tax rate of 296
+	if targets[0][0] != 24 || targets[0][1] != 296 {
+		t.Errorf("Unexpected first targets. Expected [24 296], got %v", targets[0])
+	}
+
+	if _, _, _, err := loadMultiTargetCSV("boston.csv", "mv", "missing"); err == nil {
+		t.Errorf("Expected an error for an unknown column")
+	}
+}
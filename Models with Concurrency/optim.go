@@ -0,0 +1,251 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// nelderMead minimizes f with the Nelder-Mead simplex method, starting from start with an
+// initial step of 1 along every axis, and returns the best point found after at most
+// maxEvaluations calls to f. It needs no gradient, which suits small hyperparameter
+// searches on the log scale.
+func nelderMead(f func(x []float64) float64, start []float64, maxEvaluations int) []float64 {
+	dim := len(start)
+	simplex := make([][]float64, dim+1)
+	values := make([]float64, dim+1)
+	for i := range simplex {
+		simplex[i] = append([]float64(nil), start...)
+		if i > 0 {
+			simplex[i][i-1]++
+		}
+		values[i] = f(simplex[i])
+	}
+	evaluations := dim + 1
+
+	// point returns centroid + t * (centroid - worst)
+	point := func(centroid, worst []float64, t float64) []float64 {
+		p := make([]float64, dim)
+		for j := range p {
+			p[j] = centroid[j] + t*(centroid[j]-worst[j])
+		}
+		return p
+	}
+
+	for evaluations < maxEvaluations {
+		order := make([]int, dim+1)
+		for i := range order {
+			order[i] = i
+		}
+		sort.Slice(order, func(a, b int) bool { return values[order[a]] < values[order[b]] })
+		sortedSimplex := make([][]float64, dim+1)
+		sortedValues := make([]float64, dim+1)
+		for i, k := range order {
+			sortedSimplex[i], sortedValues[i] = simplex[k], values[k]
+		}
+		simplex, values = sortedSimplex, sortedValues
+
+		if math.Abs(values[dim]-values[0]) < 1e-10*(math.Abs(values[0])+1e-10) {
+			break
+		}
+
+		centroid := make([]float64, dim)
+		for _, p := range simplex[:dim] {
+			for j := range centroid {
+				centroid[j] += p[j] / float64(dim)
+			}
+		}
+		worst := simplex[dim]
+
+		reflected := point(centroid, worst, 1)
+		reflectedValue := f(reflected)
+		evaluations++
+		switch {
+		case reflectedValue < values[0]:
+			expanded := point(centroid, worst, 2)
+			expandedValue := f(expanded)
+			evaluations++
+			if expandedValue < reflectedValue {
+				simplex[dim], values[dim] = expanded, expandedValue
+			} else {
+				simplex[dim], values[dim] = reflected, reflectedValue
+			}
+		case reflectedValue < values[dim-1]:
+			simplex[dim], values[dim] = reflected, reflectedValue
+		default:
+			contracted := point(centroid, worst, -0.5)
+			contractedValue := f(contracted)
+			evaluations++
+			if contractedValue < values[dim] {
+				simplex[dim], values[dim] = contracted, contractedValue
+				continue
+			}
+			// Shrink every point towards the best one
+			for i := 1; i <= dim; i++ {
+				for j := range simplex[i] {
+					simplex[i][j] = simplex[0][j] + 0.5*(simplex[i][j]-simplex[0][j])
+				}
+				values[i] = f(simplex[i])
+				evaluations++
+			}
+		}
+	}
+
+	best := 0
+	for i := range values {
+		if values[i] < values[best] {
+			best = i
+		}
+	}
+	return simplex[best]
+}
+
+// lbfgs minimizes f(x) + Σ_j l1[j] |x_j| with limited-memory BFGS, starting from start,
+// where f returns its value at x and writes its gradient into gradient. memory is the
+// number of correction pairs kept. With l1 nil (or all zeros) it is plain L-BFGS with a
+// backtracking Armijo line search; otherwise it is OWL-QN (Andrew and Gao, 2007), which
+// steps along the pseudo-gradient of the nondifferentiable L1 term and keeps every step
+// inside one orthant, so coefficients can land exactly on zero.
+//
+// It returns the minimizer, the number of iterations and whether it converged: the
+// largest pseudo-gradient entry fell below tolerance, or the objective stopped decreasing
+// beyond rounding error.
+func lbfgs(f func(x, gradient []float64) float64, start []float64, l1 []float64, memory, maxIterations int, tolerance float64) ([]float64, int, bool) {
+	dim := len(start)
+	penalty := func(x []float64) float64 {
+		var sum float64
+		for j := range l1 {
+			sum += l1[j] * math.Abs(x[j])
+		}
+		return sum
+	}
+	// pseudoGradient is the gradient of f + penalty, or the minimum-norm subgradient at
+	// coordinates sitting on zero
+	pseudoGradient := func(x, gradient, out []float64) {
+		copy(out, gradient)
+		for j := range l1 {
+			switch {
+			case l1[j] == 0:
+			case x[j] > 0:
+				out[j] += l1[j]
+			case x[j] < 0:
+				out[j] -= l1[j]
+			case gradient[j]+l1[j] < 0:
+				out[j] += l1[j]
+			case gradient[j]-l1[j] > 0:
+				out[j] -= l1[j]
+			default:
+				out[j] = 0
+			}
+		}
+	}
+
+	x := append([]float64(nil), start...)
+	gradient := make([]float64, dim)
+	value := f(x, gradient) + penalty(x)
+	pseudo := make([]float64, dim)
+	pseudoGradient(x, gradient, pseudo)
+
+	var sHistory, yHistory [][]float64
+	var rhoHistory []float64
+	direction := make([]float64, dim)
+	next := make([]float64, dim)
+	nextGradient := make([]float64, dim)
+	alpha := make([]float64, memory)
+	for iteration := 1; iteration <= maxIterations; iteration++ {
+		if floats.Norm(pseudo, math.Inf(1)) < tolerance {
+			return x, iteration - 1, true
+		}
+
+		// Two-loop recursion: direction = -H pseudo
+		for j := range direction {
+			direction[j] = -pseudo[j]
+		}
+		for k := len(sHistory) - 1; k >= 0; k-- {
+			alpha[k] = rhoHistory[k] * floats.Dot(sHistory[k], direction)
+			floats.AddScaled(direction, -alpha[k], yHistory[k])
+		}
+		if k := len(sHistory) - 1; k >= 0 {
+			floats.Scale(floats.Dot(sHistory[k], yHistory[k])/floats.Dot(yHistory[k], yHistory[k]), direction)
+		}
+		for k := range sHistory {
+			beta := rhoHistory[k] * floats.Dot(yHistory[k], direction)
+			floats.AddScaled(direction, alpha[k]-beta, sHistory[k])
+		}
+		// OWL-QN drops components that disagree in sign with the steepest descent direction
+		for j := range l1 {
+			if l1[j] > 0 && direction[j]*pseudo[j] >= 0 {
+				direction[j] = 0
+			}
+		}
+		if floats.Dot(direction, pseudo) >= 0 {
+			// Not a descent direction: restart from steepest descent
+			for j := range direction {
+				direction[j] = -pseudo[j]
+			}
+			sHistory, yHistory, rhoHistory = nil, nil, nil
+		}
+
+		// Backtracking line search, projecting every trial point onto the current orthant
+		step := 1.0
+		if len(sHistory) == 0 {
+			step = math.Min(1, 1/floats.Norm(pseudo, 2))
+		}
+		var nextValue float64
+		accepted := false
+		for trial := 0; trial < 50; trial++ {
+			for j := range next {
+				next[j] = x[j] + step*direction[j]
+			}
+			for j := range l1 {
+				if l1[j] == 0 {
+					continue
+				}
+				orthant := math.Copysign(1, x[j])
+				if x[j] == 0 {
+					orthant = math.Copysign(1, -pseudo[j])
+				}
+				if next[j]*orthant <= 0 {
+					next[j] = 0
+				}
+			}
+			nextValue = f(next, nextGradient) + penalty(next)
+			var decrease float64
+			for j := range next {
+				decrease += pseudo[j] * (next[j] - x[j])
+			}
+			if nextValue <= value+1e-4*decrease {
+				accepted = true
+				break
+			}
+			step /= 2
+		}
+		if !accepted {
+			return x, iteration, false
+		}
+
+		s := make([]float64, dim)
+		y := make([]float64, dim)
+		floats.SubTo(s, next, x)
+		floats.SubTo(y, nextGradient, gradient)
+		if sy := floats.Dot(s, y); sy > 1e-12 {
+			if len(sHistory) == memory {
+				sHistory, yHistory, rhoHistory = sHistory[1:], yHistory[1:], rhoHistory[1:]
+			}
+			sHistory = append(sHistory, s)
+			yHistory = append(yHistory, y)
+			rhoHistory = append(rhoHistory, 1/sy)
+		}
+
+		previous := value
+		copy(x, next)
+		copy(gradient, nextGradient)
+		value = nextValue
+		pseudoGradient(x, gradient, pseudo)
+		if previous-value <= 1e-15*math.Max(1, math.Abs(value)) {
+			return x, iteration, true
+		}
+	}
+	return x, maxIterations, floats.Norm(pseudo, math.Inf(1)) < tolerance
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNelderMeadRosenbrock(t *testing.T) {
+	// The Rosenbrock function has its minimum at (1, 1)
+	rosenbrock := func(x []float64) float64 {
+		a := 1 - x[0]
+		b := x[1] - x[0]*x[0]
+		return a*a + 100*b*b
+	}
+
+	best := nelderMead(rosenbrock, []float64{-1.2, 1}, 2000)
+	if math.Abs(best[0]-1) > 1e-3 || math.Abs(best[1]-1) > 1e-3 {
+		t.Errorf("Unexpected minimum. Expected (1, 1), got (%f, %f)", best[0], best[1])
+	}
+}
+
+func TestLBFGSRosenbrock(t *testing.T) {
+	rosenbrock := func(x, gradient []float64) float64 {
+		a := 1 - x[0]
+		b := x[1] - x[0]*x[0]
+		gradient[0] = -2*a - 400*x[0]*b
+		gradient[1] = 200 * b
+		return a*a + 100*b*b
+	}
+
+	best, _, converged := lbfgs(rosenbrock, []float64{-1.2, 1}, nil, 5, 500, 1e-10)
+	if !converged {
+		t.Errorf("Expected lbfgs to converge")
+	}
+	if math.Abs(best[0]-1) > 1e-5 || math.Abs(best[1]-1) > 1e-5 {
+		t.Errorf("Unexpected minimum. Expected (1, 1), got (%f, %f)", best[0], best[1])
+	}
+}
+
+func TestLBFGSWithL1PenaltyZeroesCoordinates(t *testing.T) {
+	// (x0 - 3)² + (x1 - 0.2)² + |x0| + |x1| is minimized at (2.5, 0)
+	quadratic := func(x, gradient []float64) float64 {
+		gradient[0] = 2 * (x[0] - 3)
+		gradient[1] = 2 * (x[1] - 0.2)
+		return (x[0]-3)*(x[0]-3) + (x[1]-0.2)*(x[1]-0.2)
+	}
+
+	best, _, _ := lbfgs(quadratic, []float64{0, 0}, []float64{1, 1}, 5, 200, 1e-10)
+	if math.Abs(best[0]-2.5) > 1e-6 || best[1] != 0 {
+		t.Errorf("Unexpected minimum. Expected (2.5, 0), got (%f, %f)", best[0], best[1])
+	}
+}
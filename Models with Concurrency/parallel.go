@@ -0,0 +1,17 @@
+package main
+
+import "sync"
+
+// runTasks calls task for every index from 0 to n-1, each in its own goroutine, and waits
+// for all of them to finish. Tasks must only write to state owned by their own index.
+func runTasks(n int, task func(i int)) {
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			task(i)
+			wg.Done()
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,329 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+)
+
+// pcrRegressor is principal component regression: the standardized features are
+// projected onto their first numComponents principal components, which are uncorrelated,
+// and the target is regressed on those scores. Dropping the low-variance components
+// removes the directions in which collinear features such as nox, indus and dis make OLS
+// unstable.
+type pcrRegressor struct {
+	numComponents int // 0 chooses the count by cross-validation
+	maxComponents int // largest count tried by cross-validation, 0 means all
+	numFolds      int
+	seed          int64
+
+	scaler            standardScaler
+	loadings          *mat.Dense // features × components, the principal axes
+	explainedVariance []float64  // fraction of the feature variance per component
+	coefficients      []float64  // intercept first, on the original feature scale
+	cvMSE             []float64  // cross-validated MSE for 1, 2, ... components
+}
+
+// plsRegressor is partial least squares regression (PLS1, fit by NIPALS). Unlike PCR its
+// components are chosen to covary with the target, so it usually needs fewer of them.
+type plsRegressor struct {
+	numComponents int // 0 chooses the count by cross-validation
+	maxComponents int // largest count tried by cross-validation, 0 means all
+	numFolds      int
+	seed          int64
+
+	scaler                  standardScaler
+	weights                 *mat.Dense // features × components, the NIPALS weights w
+	loadings                *mat.Dense // features × components, the X loadings p
+	explainedVariance       []float64  // fraction of the feature variance per component
+	explainedTargetVariance []float64  // fraction of the target variance per component
+	coefficients            []float64  // intercept first, on the original feature scale
+	cvMSE                   []float64
+}
+
+// newPCR returns a pcrRegressor that picks its component count by 5-fold cross-validation.
+func newPCR() *pcrRegressor {
+	return &pcrRegressor{numFolds: 5, seed: 1}
+}
+
+// newPLS returns a plsRegressor that picks its component count by 5-fold cross-validation.
+func newPLS() *plsRegressor {
+	return &plsRegressor{numFolds: 5, seed: 1}
+}
+
+// chooseComponents returns the component count in 1..maxComponents with the lowest
+// cross-validated MSE, and the MSE of every count. maxComponents is capped at the number of
+// features and at the rows of the smallest training fold. Nil weights weight every row equally.
+func chooseComponents(newModel func(numComponents int) regressor, features [][]float64, target []float64, weights []float64, maxComponents, numFolds int, seed int64) (int, []float64, error) {
+	if maxComponents <= 0 || maxComponents > len(features[0]) {
+		maxComponents = len(features[0])
+	}
+	// Every training fold needs at least as many rows as components
+	if numFolds > 0 {
+		if smallestFold := len(features) - (len(features)+numFolds-1)/numFolds; maxComponents > smallestFold {
+			maxComponents = smallestFold
+		}
+	}
+	mse := make([]float64, maxComponents)
+	best := 1
+	for k := 1; k <= maxComponents; k++ {
+		var err error
+		mse[k-1], err = crossValidatedMSE(func() regressor { return newModel(k) }, features, target, weights, numFolds, seed)
+		if err != nil {
+			return 0, nil, err
+		}
+		if mse[k-1] < mse[best-1] {
+			best = k
+		}
+	}
+	return best, mse, nil
+}
+
+func (m *pcrRegressor) Fit(features [][]float64, target []float64) error {
+	return m.FitWeighted(features, target, nil)
+}
+
+// FitWeighted fits PCR by weighted least squares: the features are centered and scaled
+// with weighted means and standard deviations, and the principal components are those of
+// the weighted covariance.
+func (m *pcrRegressor) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	rowWeights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	numFeatures := len(features[0])
+	numComponents := m.numComponents
+	// The thin SVD has only min(rows, features) components
+	limit := numFeatures
+	if len(features) < limit {
+		limit = len(features)
+	}
+	if numComponents > limit {
+		return fmt.Errorf("at most %d components for %d rows and %d features, got %d", limit, len(features), numFeatures, numComponents)
+	}
+	if numComponents <= 0 {
+		best, mse, err := chooseComponents(func(k int) regressor { return &pcrRegressor{numComponents: k} }, features, target, weights, m.maxComponents, m.numFolds, m.seed)
+		if err != nil {
+			return err
+		}
+		numComponents, m.cvMSE = best, mse
+	}
+
+	m.scaler = fitWeightedScaler(features, weights)
+	scaled, root := weightedDesign(m.scaler.transformAll(features), rowWeights)
+	var svd mat.SVD
+	if ok := svd.Factorize(scaled, mat.SVDThin); !ok {
+		return fmt.Errorf("SVD of the features failed")
+	}
+	values := svd.Values(nil)
+	var axes mat.Dense
+	svd.VTo(&axes)
+
+	totalVariance := floats.Dot(values, values)
+	m.explainedVariance = make([]float64, numComponents)
+	for k := range m.explainedVariance {
+		m.explainedVariance[k] = values[k] * values[k] / totalVariance
+	}
+	m.loadings = mat.DenseCopyOf(axes.Slice(0, numFeatures, 0, numComponents))
+
+	// Scores t_k = X v_k are orthogonal with |t_k|² = s_k², so each regression coefficient
+	// is t_kᵀy / s_k² and the centering of y drops out
+	weightedTarget := make([]float64, len(target))
+	floats.MulTo(weightedTarget, root, target)
+	targetMean := stat.Mean(target, rowWeights)
+	slopes := make([]float64, numFeatures)
+	for k := 0; k < numComponents; k++ {
+		if values[k] < 1e-12*values[0] {
+			break
+		}
+		axis := mat.Col(nil, k, m.loadings)
+		var score mat.VecDense
+		score.MulVec(scaled, mat.NewVecDense(numFeatures, axis))
+		gamma := mat.Dot(&score, mat.NewVecDense(len(target), weightedTarget)) / (values[k] * values[k])
+		floats.AddScaled(slopes, gamma, axis)
+	}
+	m.coefficients = unscaleCoefficients(slopes, targetMean, m.scaler)
+	return nil
+}
+
+func (m *pcrRegressor) Predict(featureRow []float64) float64 {
+	return predictLin(featureRow, m.coefficients)
+}
+
+// report lists the explained variance of every component and the loadings of every
+// feature, by column name.
+func (m *pcrRegressor) report(columnNames []string) string {
+	return componentReport(columnNames, m.loadings, m.explainedVariance, nil, m.cvMSE)
+}
+
+func (m *plsRegressor) Fit(features [][]float64, target []float64) error {
+	return m.FitWeighted(features, target, nil)
+}
+
+// FitWeighted fits PLS by weighted least squares: NIPALS runs on the rows of the
+// weighted-centered features and target multiplied by the square roots of the weights.
+func (m *plsRegressor) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	rowWeights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	numFeatures := len(features[0])
+	numComponents := m.numComponents
+	if numComponents > numFeatures {
+		return fmt.Errorf("at most %d components, got %d", numFeatures, numComponents)
+	}
+	if numComponents <= 0 {
+		best, mse, err := chooseComponents(func(k int) regressor { return &plsRegressor{numComponents: k} }, features, target, weights, m.maxComponents, m.numFolds, m.seed)
+		if err != nil {
+			return err
+		}
+		numComponents, m.cvMSE = best, mse
+	}
+
+	m.scaler = fitWeightedScaler(features, weights)
+	x, root := weightedDesign(m.scaler.transformAll(features), rowWeights)
+	targetMean := stat.Mean(target, rowWeights)
+	y := mat.NewVecDense(len(target), nil)
+	for i, value := range target {
+		y.SetVec(i, root[i]*(value-targetMean))
+	}
+	totalVariance := math.Pow(mat.Norm(x, 2), 2)
+	targetVariance := mat.Dot(y, y)
+
+	m.weights = mat.NewDense(numFeatures, numComponents, nil)
+	m.loadings = mat.NewDense(numFeatures, numComponents, nil)
+	m.explainedVariance = make([]float64, 0, numComponents)
+	m.explainedTargetVariance = make([]float64, 0, numComponents)
+	targetLoadings := make([]float64, 0, numComponents)
+	for k := 0; k < numComponents; k++ {
+		// w ∝ Xᵀy, t = Xw, p = Xᵀt / tᵀt, q = yᵀt / tᵀt, then deflate X and y
+		var w mat.VecDense
+		w.MulVec(x.T(), y)
+		norm := mat.Norm(&w, 2)
+		if norm < 1e-12 {
+			break
+		}
+		w.ScaleVec(1/norm, &w)
+		var t mat.VecDense
+		t.MulVec(x, &w)
+		tt := mat.Dot(&t, &t)
+		var p mat.VecDense
+		p.MulVec(x.T(), &t)
+		p.ScaleVec(1/tt, &p)
+		q := mat.Dot(y, &t) / tt
+
+		var deflation mat.Dense
+		deflation.Outer(1, &t, &p)
+		x.Sub(x, &deflation)
+		y.AddScaledVec(y, -q, &t)
+
+		m.weights.SetCol(k, w.RawVector().Data)
+		m.loadings.SetCol(k, p.RawVector().Data)
+		targetLoadings = append(targetLoadings, q)
+		m.explainedVariance = append(m.explainedVariance, tt*mat.Dot(&p, &p)/totalVariance)
+		m.explainedTargetVariance = append(m.explainedTargetVariance, q*q*tt/targetVariance)
+	}
+	numComponents = len(targetLoadings)
+	if numComponents == 0 {
+		m.coefficients = unscaleCoefficients(make([]float64, numFeatures), targetMean, m.scaler)
+		return nil
+	}
+	m.weights = mat.DenseCopyOf(m.weights.Slice(0, numFeatures, 0, numComponents))
+	m.loadings = mat.DenseCopyOf(m.loadings.Slice(0, numFeatures, 0, numComponents))
+
+	// β = W (PᵀW)⁻¹ q on the standardized scale
+	var ptw mat.Dense
+	ptw.Mul(m.loadings.T(), m.weights)
+	var solved mat.VecDense
+	if err := solved.SolveVec(&ptw, mat.NewVecDense(numComponents, targetLoadings)); err != nil {
+		return err
+	}
+	var slopes mat.VecDense
+	slopes.MulVec(m.weights, &solved)
+	m.coefficients = unscaleCoefficients(slopes.RawVector().Data, targetMean, m.scaler)
+	return nil
+}
+
+func (m *plsRegressor) Predict(featureRow []float64) float64 {
+	return predictLin(featureRow, m.coefficients)
+}
+
+// report lists the feature and target variance explained by every component and the
+// loadings of every feature, by column name.
+func (m *plsRegressor) report(columnNames []string) string {
+	return componentReport(columnNames, m.loadings, m.explainedVariance, m.explainedTargetVariance, m.cvMSE)
+}
+
+// weightedDesign returns the rows of scaled multiplied by the square roots of weights, and
+// those square roots. Least squares on the result is weighted least squares on scaled.
+func weightedDesign(scaled [][]float64, weights []float64) (*mat.Dense, []float64) {
+	root := make([]float64, len(weights))
+	design := mat.NewDense(len(scaled), len(scaled[0]), nil)
+	for i, row := range scaled {
+		root[i] = math.Sqrt(weights[i])
+		for j, value := range row {
+			design.Set(i, j, root[i]*value)
+		}
+	}
+	return design, root
+}
+
+// unscaleCoefficients maps slopes on standardized features to coefficients on the
+// original features, intercept first like linearRegression.
+func unscaleCoefficients(slopes []float64, targetMean float64, scaler standardScaler) []float64 {
+	coefficients := make([]float64, len(slopes)+1)
+	coefficients[0] = targetMean
+	for j, slope := range slopes {
+		coefficients[j+1] = slope / scaler.scale[j]
+		coefficients[0] -= coefficients[j+1] * scaler.means[j]
+	}
+	return coefficients
+}
+
+// componentReport renders the explained variance per component (and per target when
+// targetVariance is non-nil), the cross-validation curve when there is one, and the
+// loadings table with one row per feature.
+func componentReport(columnNames []string, loadings *mat.Dense, variance, targetVariance, cvMSE []float64) string {
+	var sb strings.Builder
+	numFeatures, numComponents := loadings.Dims()
+	fmt.Fprintf(&sb, "%-10s", "component")
+	for k := 0; k < numComponents; k++ {
+		fmt.Fprintf(&sb, " %8s", fmt.Sprintf("PC%d", k+1))
+	}
+	fmt.Fprintf(&sb, "\n%-10s", "X var %")
+	for _, v := range variance {
+		fmt.Fprintf(&sb, " %8.2f", 100*v)
+	}
+	if targetVariance != nil {
+		fmt.Fprintf(&sb, "\n%-10s", "y var %")
+		for _, v := range targetVariance {
+			fmt.Fprintf(&sb, " %8.2f", 100*v)
+		}
+	}
+	sb.WriteString("\n")
+	for j := 0; j < numFeatures; j++ {
+		fmt.Fprintf(&sb, "%-10s", featureName(columnNames, j))
+		for k := 0; k < numComponents; k++ {
+			fmt.Fprintf(&sb, " %8.3f", loadings.At(j, k))
+		}
+		sb.WriteString("\n")
+	}
+	if cvMSE != nil {
+		sb.WriteString("cross-validated RMSE by number of components:")
+		for k, mse := range cvMSE {
+			fmt.Fprintf(&sb, " %d: %.3f", k+1, math.Sqrt(mse))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestQuantileRegressionCoverage(t *testing.T) {
+	// Create sample data with spread that grows with x, so quantile lines fan out
+	var features [][]float64
+	var target []float64
+	for i := 0; i < 200; i++ {
+		x := float64(i%20) + 1
+		offset := float64(i/20)/9 - 0.5 // evenly spread in [-0.5, 0.5]
+		features = append(features, []float64{x})
+		target = append(target, 10+x+offset*x)
+	}
+
+	model := newQuantileRegressor(0.9, 0.1, 0.5)
+	if err := model.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Predictions come back in increasing quantile order
+	below := make([]int, 3)
+	for i, row := range features {
+		predictions := model.predictQuantiles(row)
+		if predictions[0] > predictions[1] || predictions[1] > predictions[2] {
+			t.Fatalf("Quantile predictions cross: %v", predictions)
+		}
+		for k, p := range predictions {
+			if target[i] <= p+1e-9 {
+				below[k]++
+			}
+		}
+	}
+	for k, tau := range []float64{0.1, 0.5, 0.9} {
+		coverage := float64(below[k]) / float64(len(target))
+		if math.Abs(coverage-tau) > 0.06 {
+			t.Errorf("Unexpected coverage for quantile %v. Expected about %f, got %f", tau, tau, coverage)
+		}
+	}
+
+	// Predict uses the median
+	if prediction, expected := model.Predict([]float64{10}), 20.0; math.Abs(prediction-expected) > 0.5 {
+		t.Errorf("Unexpected median prediction. Expected about %f, got %f", expected, prediction)
+	}
+}
+
+func TestPinballLoss(t *testing.T) {
+	predictions := []float64{10, 10}
+	targets := []float64{12, 7}
+
+	// Under-prediction by 2 costs 0.9*2, over-prediction by 3 costs 0.1*3
+	expected := (0.9*2 + 0.1*3) / 2
+	if loss := pinballLoss(predictions, targets, 0.9); math.Abs(loss-expected) > 1e-12 {
+		t.Errorf("Unexpected pinball loss. Expected %f, got %f", expected, loss)
+	}
+}
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// madToSigma converts a median absolute deviation into a standard deviation estimate for
+// normally distributed residuals.
+const madToSigma = 1.4826
+
+// huberRegressor is a linear model fit by Huber M-estimation with iteratively reweighted
+// least squares. Residuals within epsilon robust standard deviations get full weight and
+// larger ones get weight epsilon/|r|, so capped or mis-recorded prices lose influence.
+type huberRegressor struct {
+	epsilon       float64 // 1.345 gives 95% efficiency on normal errors
+	maxIterations int
+	tolerance     float64
+
+	coefficients []float64 // intercept first, like linearRegression
+	scale        float64   // robust standard deviation of the residuals
+	inliers      []bool    // training rows with |residual| <= epsilon * scale
+}
+
+// ransacRegressor fits OLS to many random minimal subsets, keeps the fit with the most
+// training rows within threshold of it, and refits OLS on those inliers.
+type ransacRegressor struct {
+	threshold  float64 // absolute residual for an inlier, 0 means the MAD of the target
+	minSamples int     // rows per subset, 0 means features + 1
+	maxTrials  int
+	seed       int64
+
+	coefficients []float64
+	inliers      []bool
+}
+
+// theilSenRegressor takes the exact fits to random subsets of features + 1 rows and
+// returns their spatial median, which tolerates close to 30% outliers. Rows within
+// 2.5 robust standard deviations of the fit are reported as inliers.
+type theilSenRegressor struct {
+	maxSubsets int
+	seed       int64
+
+	coefficients []float64
+	inliers      []bool
+}
+
+// newHuber returns a huberRegressor with the usual epsilon of 1.345.
+func newHuber() *huberRegressor {
+	return &huberRegressor{epsilon: 1.345, maxIterations: 100, tolerance: 1e-6}
+}
+
+// newRANSAC returns a ransacRegressor with a MAD threshold and minimal subsets.
+func newRANSAC() *ransacRegressor {
+	return &ransacRegressor{maxTrials: 100, seed: 1}
+}
+
+// newTheilSen returns a theilSenRegressor that samples up to 10000 subsets.
+func newTheilSen() *theilSenRegressor {
+	return &theilSenRegressor{maxSubsets: 10000, seed: 1}
+}
+
+func (h *huberRegressor) Fit(features [][]float64, target []float64) error {
+	return h.FitWeighted(features, target, nil)
+}
+
+// FitWeighted multiplies the Huber weights by the sample weights in every IRLS step and
+// estimates the scale by a weighted MAD.
+func (h *huberRegressor) FitWeighted(features [][]float64, target []float64, sampleWeights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	sampleWeights, err := checkWeights(sampleWeights, len(target))
+	if err != nil {
+		return err
+	}
+
+	weights := append([]float64(nil), sampleWeights...)
+	residuals := make([]float64, len(target))
+	var coefficients []float64
+	for iteration := 0; iteration < h.maxIterations; iteration++ {
+		next, err := weightedLinearRegression(features, target, weights)
+		if err != nil {
+			return err
+		}
+		converged := coefficients != nil && floats.Distance(next, coefficients, math.Inf(1)) < h.tolerance*(1+floats.Norm(coefficients, math.Inf(1)))
+		coefficients = next
+
+		linearResiduals(features, target, coefficients, residuals)
+		h.scale = madToSigma * weightedMedianAbsoluteDeviation(residuals, sampleWeights)
+		if h.scale == 0 {
+			break
+		}
+		for i, r := range residuals {
+			weights[i] = sampleWeights[i]
+			if z := math.Abs(r) / h.scale; z > h.epsilon {
+				weights[i] *= h.epsilon / z
+			}
+		}
+		if converged {
+			break
+		}
+	}
+
+	h.coefficients = coefficients
+	h.inliers = make([]bool, len(target))
+	for i, r := range residuals {
+		h.inliers[i] = math.Abs(r) <= h.epsilon*h.scale
+	}
+	return nil
+}
+
+func (h *huberRegressor) Predict(featureRow []float64) float64 {
+	return predictLin(featureRow, h.coefficients)
+}
+
+func (r *ransacRegressor) Fit(features [][]float64, target []float64) error {
+	return r.FitWeighted(features, target, nil)
+}
+
+// FitWeighted scores each candidate by the total weight of its inliers and refits on them
+// by weighted least squares.
+func (r *ransacRegressor) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	minSamples := r.minSamples
+	if minSamples <= 0 {
+		minSamples = len(features[0]) + 1
+	}
+	if minSamples > len(features) {
+		return fmt.Errorf("need at least %d rows, got %d", minSamples, len(features))
+	}
+	threshold := r.threshold
+	if threshold <= 0 {
+		threshold = weightedMedianAbsoluteDeviation(target, weights)
+	}
+
+	rng := rand.New(rand.NewSource(r.seed))
+	residuals := make([]float64, len(target))
+	var bestInliers []bool
+	bestCount, bestWeight, bestError := -1, -1.0, math.Inf(1)
+	for trial := 0; trial < r.maxTrials; trial++ {
+		subset := rng.Perm(len(features))[:minSamples]
+		coefficients, err := subsetRegression(features, target, weights, subset)
+		if err != nil {
+			continue
+		}
+
+		linearResiduals(features, target, coefficients, residuals)
+		inliers := make([]bool, len(target))
+		count := 0
+		var inlierWeight, squaredError float64
+		for i, res := range residuals {
+			if math.Abs(res) <= threshold {
+				inliers[i] = true
+				count++
+				inlierWeight += weights[i]
+				squaredError += weights[i] * res * res
+			}
+		}
+		if inlierWeight > bestWeight || (inlierWeight == bestWeight && squaredError < bestError) {
+			bestInliers, bestCount, bestWeight, bestError = inliers, count, inlierWeight, squaredError
+		}
+	}
+	if bestCount < minSamples {
+		return fmt.Errorf("RANSAC found no subset with at least %d inliers", minSamples)
+	}
+
+	var inlierRows []int
+	for i, inlier := range bestInliers {
+		if inlier {
+			inlierRows = append(inlierRows, i)
+		}
+	}
+	coefficients, err := subsetRegression(features, target, weights, inlierRows)
+	if err != nil {
+		return err
+	}
+	r.coefficients = coefficients
+	r.inliers = bestInliers
+	return nil
+}
+
+func (r *ransacRegressor) Predict(featureRow []float64) float64 {
+	return predictLin(featureRow, r.coefficients)
+}
+
+func (ts *theilSenRegressor) Fit(features [][]float64, target []float64) error {
+	return ts.FitWeighted(features, target, nil)
+}
+
+// FitWeighted weights the fit to each subset by the product of its rows' weights, the
+// number of distinct subsets the rows would form if every row were repeated weight times,
+// and takes the weighted spatial median.
+func (ts *theilSenRegressor) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	subsetSize := len(features[0]) + 1
+	if subsetSize > len(features) {
+		return fmt.Errorf("need at least %d rows, got %d", subsetSize, len(features))
+	}
+
+	// Use every subset when there are few of them, otherwise a random sample
+	var subsets [][]int
+	if total := binomial(len(features), subsetSize); total <= float64(ts.maxSubsets) {
+		subsets = combinations(len(features), subsetSize)
+	} else {
+		rng := rand.New(rand.NewSource(ts.seed))
+		for len(subsets) < ts.maxSubsets {
+			subset := rng.Perm(len(features))[:subsetSize]
+			sort.Ints(subset)
+			subsets = append(subsets, subset)
+		}
+	}
+
+	var solutions [][]float64
+	var solutionWeights []float64
+	for _, subset := range subsets {
+		coefficients, err := subsetRegression(features, target, weights, subset)
+		if err != nil {
+			continue
+		}
+		weight := 1.0
+		for _, i := range subset {
+			weight *= weights[i]
+		}
+		solutions = append(solutions, coefficients)
+		solutionWeights = append(solutionWeights, weight)
+	}
+	if len(solutions) == 0 {
+		return fmt.Errorf("every subset was singular")
+	}
+	ts.coefficients = spatialMedian(solutions, solutionWeights)
+
+	residuals := make([]float64, len(target))
+	linearResiduals(features, target, ts.coefficients, residuals)
+	scale := madToSigma * weightedMedianAbsoluteDeviation(residuals, weights)
+	ts.inliers = make([]bool, len(target))
+	for i, r := range residuals {
+		ts.inliers[i] = math.Abs(r) <= 2.5*scale
+	}
+	return nil
+}
+
+func (ts *theilSenRegressor) Predict(featureRow []float64) float64 {
+	return predictLin(featureRow, ts.coefficients)
+}
+
+// subsetRegression fits weighted least squares with an intercept to the given rows only.
+// Zero-weight rows count as missing, so a subset that needs them is singular.
+func subsetRegression(features [][]float64, target []float64, weights []float64, rows []int) ([]float64, error) {
+	subsetFeatures := make([][]float64, len(rows))
+	subsetTarget := make([]float64, len(rows))
+	subsetWeights := make([]float64, len(rows))
+	for k, i := range rows {
+		subsetFeatures[k] = features[i]
+		subsetTarget[k] = target[i]
+		subsetWeights[k] = weights[i]
+	}
+	coefficients, err := weightedLinearRegression(subsetFeatures, subsetTarget, subsetWeights)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range coefficients {
+		if math.IsNaN(c) || math.IsInf(c, 0) {
+			return nil, fmt.Errorf("singular subset")
+		}
+	}
+	return coefficients, nil
+}
+
+// linearResiduals stores y - prediction for every row in residuals.
+func linearResiduals(features [][]float64, target []float64, coefficients []float64, residuals []float64) {
+	for i, row := range features {
+		residuals[i] = target[i] - predictLin(row, coefficients)
+	}
+}
+
+// median returns the middle value of values without modifying it.
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// medianAbsoluteDeviation returns median(|v - median(v)|).
+func medianAbsoluteDeviation(values []float64) float64 {
+	center := median(values)
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - center)
+	}
+	return median(deviations)
+}
+
+// weightedMedian returns the value at which the cumulative weight of the sorted values
+// reaches half the total, averaging with the next value on an exact tie so that equal
+// weights give the ordinary median.
+func weightedMedian(values []float64, weights []float64) float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return values[order[a]] < values[order[b]] })
+
+	half := floats.Sum(weights) / 2
+	var cumulative float64
+	for k, i := range order {
+		cumulative += weights[i]
+		if cumulative < half || weights[i] == 0 {
+			continue
+		}
+		if cumulative == half {
+			for _, next := range order[k+1:] {
+				if weights[next] > 0 {
+					return (values[i] + values[next]) / 2
+				}
+			}
+		}
+		return values[i]
+	}
+	return values[order[len(order)-1]]
+}
+
+// weightedMedianAbsoluteDeviation returns the weighted median of |v - weighted median(v)|.
+func weightedMedianAbsoluteDeviation(values []float64, weights []float64) float64 {
+	center := weightedMedian(values, weights)
+	deviations := make([]float64, len(values))
+	for i, v := range values {
+		deviations[i] = math.Abs(v - center)
+	}
+	return weightedMedian(deviations, weights)
+}
+
+// spatialMedian returns the point minimizing the weighted sum of Euclidean distances to
+// points, found with Weiszfeld's algorithm starting from the weighted coordinate-wise mean.
+func spatialMedian(points [][]float64, weights []float64) []float64 {
+	current := make([]float64, len(points[0]))
+	for k, p := range points {
+		floats.AddScaled(current, weights[k], p)
+	}
+	floats.Scale(1/floats.Sum(weights), current)
+
+	next := make([]float64, len(current))
+	for iteration := 0; iteration < 300; iteration++ {
+		for j := range next {
+			next[j] = 0
+		}
+		var sumWeights float64
+		for k, p := range points {
+			distance := floats.Distance(p, current, 2)
+			if distance < 1e-12 {
+				continue
+			}
+			floats.AddScaled(next, weights[k]/distance, p)
+			sumWeights += weights[k] / distance
+		}
+		if sumWeights == 0 {
+			break
+		}
+		floats.Scale(1/sumWeights, next)
+		moved := floats.Distance(next, current, 2)
+		copy(current, next)
+		if moved < 1e-9*(1+floats.Norm(current, 2)) {
+			break
+		}
+	}
+	return current
+}
+
+// binomial returns n choose k as a float64 so large values do not overflow.
+func binomial(n, k int) float64 {
+	result := 1.0
+	for i := 1; i <= k; i++ {
+		result *= float64(n-k+i) / float64(i)
+	}
+	return result
+}
+
+// combinations lists every k-element subset of 0..n-1 in lexicographic order.
+func combinations(n, k int) [][]int {
+	var result [][]int
+	subset := make([]int, k)
+	var generate func(start, depth int)
+	generate = func(start, depth int) {
+		if depth == k {
+			result = append(result, append([]int(nil), subset...))
+			return
+		}
+		for i := start; i <= n-(k-depth); i++ {
+			subset[depth] = i
+			generate(i+1, depth+1)
+		}
+	}
+	generate(0, 0)
+	return result
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// outlierData returns rows on y = 1 + 2*x1 - x2 with a little noise and every tenth
+// target capped far above the line.
+func outlierData() ([][]float64, []float64) {
+	var features [][]float64
+	var target []float64
+	for i := 0; i < 60; i++ {
+		x1 := float64(i) / 6
+		x2 := float64((i * 7) % 5)
+		noise := 0.1 * math.Sin(float64(i))
+		y := 1 + 2*x1 - x2 + noise
+		if i%10 == 3 {
+			y += 40
+		}
+		features = append(features, []float64{x1, x2})
+		target = append(target, y)
+	}
+	return features, target
+}
+
+func TestRobustRegressorsIgnoreOutliers(t *testing.T) {
+	features, target := outlierData()
+	expected := []float64{1, 2, -1}
+
+	models := map[string]regressor{
+		"huber":     newHuber(),
+		"ransac":    newRANSAC(),
+		"theil-sen": newTheilSen(),
+	}
+	for name, model := range models {
+		if err := model.Fit(features, target); err != nil {
+			t.Fatalf("Unexpected %s error: %v", name, err)
+		}
+
+		var coefficients []float64
+		var inliers []bool
+		switch m := model.(type) {
+		case *huberRegressor:
+			coefficients, inliers = m.coefficients, m.inliers
+		case *ransacRegressor:
+			coefficients, inliers = m.coefficients, m.inliers
+		case *theilSenRegressor:
+			coefficients, inliers = m.coefficients, m.inliers
+		}
+
+		// Coefficients are laid out like linearRegression: intercept first
+		if len(coefficients) != len(expected) {
+			t.Fatalf("Unexpected %s coefficient length. Expected %d, got %d", name, len(expected), len(coefficients))
+		}
+		for j := range expected {
+			if math.Abs(coefficients[j]-expected[j]) > 0.3 {
+				t.Errorf("Unexpected %s coefficient %d. Expected about %f, got %f", name, j, expected[j], coefficients[j])
+			}
+		}
+		for i := range target {
+			if inliers[i] == (i%10 == 3) {
+				t.Errorf("Unexpected %s inlier flag for row %d: %v", name, i, inliers[i])
+			}
+		}
+	}
+}
+
+func TestMedianAbsoluteDeviation(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 100}
+	if mad := medianAbsoluteDeviation(values); mad != 1 {
+		t.Errorf("Unexpected MAD. Expected %f, got %f", 1.0, mad)
+	}
+	if m := median([]float64{4, 1, 3, 2}); m != 2.5 {
+		t.Errorf("Unexpected median. Expected %f, got %f", 2.5, m)
+	}
+}
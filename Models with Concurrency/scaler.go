@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+
+	"gonum.org/v1/gonum/stat"
+)
+
+// standardScaler rescales every feature to zero mean and unit variance, using statistics
+// learned from the training rows. Constant features are only centered.
+type standardScaler struct {
+	means []float64
+	scale []float64
+}
+
+// fitScaler learns the mean and standard deviation of every feature column.
+func fitScaler(features [][]float64) standardScaler {
+	return fitWeightedScaler(features, nil)
+}
+
+// fitWeightedScaler learns the weighted mean and standard deviation of every feature
+// column, counting row i weights[i] times. Nil weights count every row once.
+func fitWeightedScaler(features [][]float64, weights []float64) standardScaler {
+	numFeatures := len(features[0])
+	s := standardScaler{means: make([]float64, numFeatures), scale: make([]float64, numFeatures)}
+	column := make([]float64, len(features))
+	for j := 0; j < numFeatures; j++ {
+		for i, row := range features {
+			column[i] = row[j]
+		}
+		mean, std := weightedMeanStdDev(column, weights)
+		s.means[j] = mean
+		s.scale[j] = 1
+		if std > 0 {
+			s.scale[j] = std
+		}
+	}
+	return s
+}
+
+// weightedMeanStdDev returns the weighted mean and population standard deviation of x.
+// Dividing by the total weight rather than by the total weight less one leaves the result
+// unchanged when every weight is multiplied by a constant, so weights that sum to 1 or
+// less are fine. The standard deviation is 0 unless it is positive and finite.
+func weightedMeanStdDev(x []float64, weights []float64) (float64, float64) {
+	mean, variance := stat.PopMeanVariance(x, weights)
+	std := math.Sqrt(variance)
+	if !(std > 0) || math.IsInf(std, 1) {
+		return mean, 0
+	}
+	return mean, std
+}
+
+// identityScaler returns a scaler that leaves numFeatures features unchanged.
+func identityScaler(numFeatures int) standardScaler {
+	s := standardScaler{means: make([]float64, numFeatures), scale: make([]float64, numFeatures)}
+	for j := range s.scale {
+		s.scale[j] = 1
+	}
+	return s
+}
+
+func (s standardScaler) transform(featureRow []float64) []float64 {
+	if len(featureRow) != len(s.means) {
+		panic("Feature row and training features length mismatch")
+	}
+	scaled := make([]float64, len(featureRow))
+	for j, v := range featureRow {
+		scaled[j] = (v - s.means[j]) / s.scale[j]
+	}
+	return scaled
+}
+
+func (s standardScaler) transformAll(features [][]float64) [][]float64 {
+	scaled := make([][]float64, len(features))
+	for i, row := range features {
+		scaled[i] = s.transform(row)
+	}
+	return scaled
+}
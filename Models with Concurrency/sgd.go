@@ -0,0 +1,343 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/stat"
+)
+
+// sgdRegressor is a linear model trained by stochastic gradient descent, one row at a
+// time, on a squared, Huber or epsilon-insensitive loss with an L2, L1 or elastic net
+// penalty. L1 uses the cumulative penalty of Tsuruoka et al. (2009): every update clips
+// each coefficient toward zero by the L1 penalty accumulated so far that it has not yet
+// received, so coefficients reach and stay at exactly zero despite the gradient noise.
+//
+// Features and target are standardized with running weighted means and variances over
+// every row the model has seen. When PartialFit brings new rows the statistics are updated and the
+// coefficients re-expressed on the new scale, so the fitted function carries over and
+// training continues from where it stopped.
+type sgdRegressor struct {
+	loss      string  // "squared", "huber" or "epsilon_insensitive"
+	epsilon   float64 // threshold of the Huber and epsilon-insensitive losses, standardized scale
+	penalty   string  // "none", "l2", "l1" or "elasticnet"
+	alpha     float64 // penalty strength
+	l1Ratio   float64 // share of L1 in the elastic net penalty
+	schedule  string  // "constant", "invscaling" or "adaptive"
+	eta0      float64 // initial learning rate
+	powerT    float64 // exponent of the invscaling schedule
+	epochs    int
+	tolerance float64 // smallest epoch loss improvement that counts
+	patience  int     // epochs without improvement before stopping (or, for adaptive, slowing)
+	seed      int64
+
+	scaler                standardScaler
+	targetMean, targetStd float64
+	numSeen               float64   // total weight of the rows in the running statistics
+	featureSquares        []float64 // sums of squared deviations from scaler.means
+	targetSquares         float64
+	slopes                []float64 // standardized scale
+	bias                  float64
+	eta                   float64   // current learning rate for the adaptive schedule
+	updates               int       // single row updates so far
+	l1Total               float64   // L1 penalty every coefficient should have received
+	l1Applied             []float64 // L1 penalty each coefficient actually received
+	rng                   *rand.Rand
+	// epochLoss is the penalized training loss (standardized scale) after every epoch
+	epochLoss []float64
+}
+
+// newSGD returns an sgdRegressor on squared loss with a small L2 penalty and an inverse
+// scaling learning rate.
+func newSGD() *sgdRegressor {
+	return &sgdRegressor{
+		loss:      "squared",
+		epsilon:   0.1,
+		penalty:   "l2",
+		alpha:     1e-4,
+		l1Ratio:   0.15,
+		schedule:  "invscaling",
+		eta0:      0.01,
+		powerT:    0.25,
+		epochs:    1000,
+		tolerance: 1e-4,
+		patience:  5,
+		seed:      1,
+	}
+}
+
+func (m *sgdRegressor) Fit(features [][]float64, target []float64) error {
+	return m.FitWeighted(features, target, nil)
+}
+
+// FitWeighted starts from zero coefficients and runs shuffled epochs until the epoch loss
+// stops improving; each row's gradient is scaled by its weight, and the standardization
+// uses weighted means and variances.
+func (m *sgdRegressor) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if err := m.check(features, target); err != nil {
+		return err
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	m.initialize(len(features[0]))
+	m.updateScaling(features, target, weights)
+
+	scaled, normalized := m.standardize(features, target)
+	best := math.Inf(1)
+	stale := 0
+	for epoch := 0; epoch < m.epochs; epoch++ {
+		m.epoch(scaled, normalized, weights)
+		loss := m.objective(scaled, normalized, weights)
+		m.epochLoss = append(m.epochLoss, loss)
+		if loss < best-m.tolerance {
+			best, stale = loss, 0
+			continue
+		}
+		if stale++; stale < m.patience {
+			continue
+		}
+		if m.schedule != "adaptive" || m.eta < 1e-6 {
+			break
+		}
+		m.eta /= 5
+		stale = 0
+	}
+	return nil
+}
+
+// PartialFit adds the new rows to the running statistics and runs one shuffled pass over
+// them, continuing from the current coefficients and learning rate.
+func (m *sgdRegressor) PartialFit(features [][]float64, target []float64) error {
+	return m.PartialFitWeighted(features, target, nil)
+}
+
+// PartialFitWeighted is PartialFit with sample weights, which count in the running
+// statistics and scale each row's gradient as in FitWeighted.
+func (m *sgdRegressor) PartialFitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if err := m.check(features, target); err != nil {
+		return err
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	if m.slopes == nil {
+		m.initialize(len(features[0]))
+	} else if len(features[0]) != len(m.slopes) {
+		return fmt.Errorf("expected %d features, got %d", len(m.slopes), len(features[0]))
+	}
+	m.updateScaling(features, target, weights)
+	scaled, normalized := m.standardize(features, target)
+	m.epoch(scaled, normalized, weights)
+	m.epochLoss = append(m.epochLoss, m.objective(scaled, normalized, weights))
+	return nil
+}
+
+func (m *sgdRegressor) check(features [][]float64, target []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	if len(target) == 0 {
+		return fmt.Errorf("no rows to fit")
+	}
+	switch m.loss {
+	case "squared", "huber", "epsilon_insensitive":
+	default:
+		return fmt.Errorf("unknown loss %q", m.loss)
+	}
+	switch m.penalty {
+	case "none", "l2", "l1", "elasticnet":
+	default:
+		return fmt.Errorf("unknown penalty %q", m.penalty)
+	}
+	switch m.schedule {
+	case "constant", "invscaling", "adaptive":
+	default:
+		return fmt.Errorf("unknown learning rate schedule %q", m.schedule)
+	}
+	if m.eta0 <= 0 || m.alpha < 0 || m.l1Ratio < 0 || m.l1Ratio > 1 {
+		return fmt.Errorf("SGD needs eta0 > 0, alpha >= 0 and l1Ratio in [0, 1]")
+	}
+	return nil
+}
+
+// initialize resets the coefficients, the statistics and the schedule.
+func (m *sgdRegressor) initialize(numFeatures int) {
+	m.scaler = standardScaler{means: make([]float64, numFeatures), scale: make([]float64, numFeatures)}
+	m.featureSquares = make([]float64, numFeatures)
+	m.targetMean, m.targetStd, m.targetSquares, m.numSeen = 0, 1, 0, 0
+	m.slopes = make([]float64, numFeatures)
+	m.l1Total, m.l1Applied = 0, make([]float64, numFeatures)
+	m.bias = 0
+	m.eta = m.eta0
+	m.updates = 0
+	m.rng = rand.New(rand.NewSource(m.seed))
+	m.epochLoss = nil
+}
+
+// updateScaling merges the weighted rows into the running means and variances (Chan et
+// al.'s pairwise update) and rescales the coefficients so predictions are unchanged:
+// w_j (x_j - μ_j) / s_j = w'_j (x_j - μ'_j) / s'_j + w_j (μ'_j - μ_j) / s_j.
+func (m *sgdRegressor) updateScaling(features [][]float64, target []float64, weights []float64) {
+	fresh := m.numSeen == 0
+	total := m.numSeen + floats.Sum(weights)
+	oldMeans := append([]float64(nil), m.scaler.means...)
+	oldScale := append([]float64(nil), m.scaler.scale...)
+	oldTargetMean, oldTargetStd := m.targetMean, m.targetStd
+
+	column := make([]float64, len(features))
+	for j := range m.scaler.means {
+		for i, row := range features {
+			column[i] = row[j]
+		}
+		m.scaler.means[j], m.featureSquares[j] = mergeMoments(m.scaler.means[j], m.featureSquares[j], m.numSeen, column, weights)
+		m.scaler.scale[j] = runningStdDev(m.featureSquares[j], total)
+	}
+	m.targetMean, m.targetSquares = mergeMoments(m.targetMean, m.targetSquares, m.numSeen, target, weights)
+	m.targetStd = runningStdDev(m.targetSquares, total)
+	m.numSeen = total
+
+	// Predictions are targetMean + targetStd × (Σ w_j z_j + b) for the standardized z
+	if fresh {
+		return
+	}
+	for j, w := range m.slopes {
+		m.bias += w * (m.scaler.means[j] - oldMeans[j]) / oldScale[j]
+		m.slopes[j] = w * m.scaler.scale[j] / oldScale[j] * oldTargetStd / m.targetStd
+	}
+	m.bias = (oldTargetMean + oldTargetStd*m.bias - m.targetMean) / m.targetStd
+}
+
+// mergeMoments combines a running mean and weighted sum of squared deviations over a
+// total weight of numSeen with the values of a new batch and their weights.
+func mergeMoments(mean, squares, numSeen float64, values []float64, weights []float64) (float64, float64) {
+	n := floats.Sum(weights)
+	batchMean := stat.Mean(values, weights)
+	var batchSquares float64
+	for i, v := range values {
+		batchSquares += weights[i] * (v - batchMean) * (v - batchMean)
+	}
+	delta := batchMean - mean
+	total := numSeen + n
+	return mean + delta*n/total, squares + batchSquares + delta*delta*numSeen*n/total
+}
+
+// runningStdDev is the population standard deviation from a weighted sum of squared
+// deviations and the total weight, like weightedMeanStdDev, or 1 when it is not positive
+// so constant columns are only centered.
+func runningStdDev(squares, total float64) float64 {
+	if total <= 0 || squares <= 0 {
+		return 1
+	}
+	return math.Sqrt(squares / total)
+}
+
+func (m *sgdRegressor) standardize(features [][]float64, target []float64) ([][]float64, []float64) {
+	normalized := make([]float64, len(target))
+	for i, y := range target {
+		normalized[i] = (y - m.targetMean) / m.targetStd
+	}
+	return m.scaler.transformAll(features), normalized
+}
+
+// epoch updates the coefficients once per row, in shuffled order.
+func (m *sgdRegressor) epoch(scaled [][]float64, normalized, weights []float64) {
+	l1, l2 := m.penaltyStrengths()
+	for _, i := range m.rng.Perm(len(scaled)) {
+		m.updates++
+		eta := m.learningRate()
+		residual := floats.Dot(m.slopes, scaled[i]) + m.bias - normalized[i]
+		gradient := weights[i] * m.lossGradient(residual)
+
+		// Gradient step on the loss and the L2 part, then the cumulative L1 clipping
+		floats.Scale(1-eta*l2, m.slopes)
+		floats.AddScaled(m.slopes, -eta*gradient, scaled[i])
+		if l1 > 0 {
+			m.l1Total += eta * l1
+			for j, w := range m.slopes {
+				switch {
+				case w > 0:
+					m.slopes[j] = math.Max(0, w-(m.l1Total+m.l1Applied[j]))
+				case w < 0:
+					m.slopes[j] = math.Min(0, w+(m.l1Total-m.l1Applied[j]))
+				}
+				m.l1Applied[j] += m.slopes[j] - w
+			}
+		}
+		m.bias -= eta * gradient
+	}
+}
+
+func (m *sgdRegressor) learningRate() float64 {
+	if m.schedule == "invscaling" {
+		return m.eta0 / math.Pow(float64(m.updates), m.powerT)
+	}
+	return m.eta
+}
+
+// penaltyStrengths splits alpha into its L1 and L2 parts.
+func (m *sgdRegressor) penaltyStrengths() (float64, float64) {
+	switch m.penalty {
+	case "l2":
+		return 0, m.alpha
+	case "l1":
+		return m.alpha, 0
+	case "elasticnet":
+		return m.alpha * m.l1Ratio, m.alpha * (1 - m.l1Ratio)
+	}
+	return 0, 0
+}
+
+// lossGradient is the derivative of the loss with respect to the prediction.
+func (m *sgdRegressor) lossGradient(residual float64) float64 {
+	switch m.loss {
+	case "huber":
+		return math.Max(-m.epsilon, math.Min(m.epsilon, residual))
+	case "epsilon_insensitive":
+		if math.Abs(residual) <= m.epsilon {
+			return 0
+		}
+		return math.Copysign(1, residual)
+	}
+	return residual
+}
+
+func (m *sgdRegressor) lossValue(residual float64) float64 {
+	r := math.Abs(residual)
+	switch m.loss {
+	case "huber":
+		if r <= m.epsilon {
+			return r * r / 2
+		}
+		return m.epsilon * (r - m.epsilon/2)
+	case "epsilon_insensitive":
+		return math.Max(0, r-m.epsilon)
+	}
+	return r * r / 2
+}
+
+// objective is the weighted mean loss plus the penalty, on the standardized scale.
+func (m *sgdRegressor) objective(scaled [][]float64, normalized, weights []float64) float64 {
+	var loss float64
+	for i, row := range scaled {
+		loss += weights[i] * m.lossValue(floats.Dot(m.slopes, row)+m.bias-normalized[i])
+	}
+	l1, l2 := m.penaltyStrengths()
+	return loss/floats.Sum(weights) + l1*floats.Norm(m.slopes, 1) + l2*floats.Dot(m.slopes, m.slopes)/2
+}
+
+func (m *sgdRegressor) Predict(featureRow []float64) float64 {
+	return m.targetMean + m.targetStd*(floats.Dot(m.slopes, m.scaler.transform(featureRow))+m.bias)
+}
+
+// linearCoefficients maps the standardized coefficients back to the original scale,
+// intercept first.
+func (m *sgdRegressor) linearCoefficients() []float64 {
+	slopes := make([]float64, len(m.slopes))
+	floats.ScaleTo(slopes, m.targetStd, m.slopes)
+	return unscaleCoefficients(slopes, m.targetMean+m.targetStd*m.bias, m.scaler)
+}
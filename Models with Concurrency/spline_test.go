@@ -0,0 +1,49 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+func TestBSplinePartitionOfUnity(t *testing.T) {
+	// Create sample data for placing the knots
+	x := make([]float64, 50)
+	floats.Span(x, 0, 10)
+	basis := newBSpline(x, 4, 3)
+
+	// Expected number of basis columns: interior knots + degree + 1
+	expectedSize := 4 + 3 + 1
+	if basis.size() != expectedSize {
+		t.Errorf("Unexpected basis size. Expected %d, got %d", expectedSize, basis.size())
+	}
+
+	// B-spline basis functions sum to one everywhere inside the boundary knots
+	for _, v := range []float64{0, 1.3, 5, 9.99, 10} {
+		sum := floats.Sum(basis.expand(v))
+		if math.Abs(sum-1) > 1e-9 {
+			t.Errorf("Unexpected basis sum at %v. Expected 1, got %f", v, sum)
+		}
+	}
+}
+
+func TestNaturalCubicSplineLinearBeyondBoundary(t *testing.T) {
+	// Create sample data for placing the knots
+	x := make([]float64, 50)
+	floats.Span(x, 0, 10)
+	basis := newNaturalCubicSpline(x, 5)
+
+	if basis.size() != 4 {
+		t.Errorf("Unexpected basis size. Expected %d, got %d", 4, basis.size())
+	}
+
+	// Beyond the last knot every basis function is linear, so second differences vanish
+	a, b, c := basis.expand(12), basis.expand(14), basis.expand(16)
+	for j := range a {
+		secondDifference := a[j] - 2*b[j] + c[j]
+		if math.Abs(secondDifference) > 1e-9 {
+			t.Errorf("Basis column %d is not linear beyond the boundary: second difference %f", j, secondDifference)
+		}
+	}
+}
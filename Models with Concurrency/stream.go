@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// streamChunkRows is the number of rows every worker parses per chunk in accumulateCSV.
+const streamChunkRows = 10000
+
+// normalEquations accumulates the sufficient statistics of a linear regression one row
+// at a time, so data far larger than memory can be fit in a single pass. It keeps means
+// and cross products of deviations from the means (Welford's update) rather than raw sums
+// of squares, which would lose every significant digit when features have a large mean
+// relative to their spread. Accumulators built by parallel workers combine with merge.
+type normalEquations struct {
+	count      float64 // total weight
+	means      []float64
+	targetMean float64
+	xx         *mat.SymDense // Σ w (x - x̄)(x - x̄)ᵀ
+	xy         []float64     // Σ w (x - x̄)(y - ȳ)
+	yy         float64       // Σ w (y - ȳ)²
+}
+
+// newNormalEquations returns an empty accumulator for rows of numFeatures features.
+func newNormalEquations(numFeatures int) *normalEquations {
+	return &normalEquations{
+		means: make([]float64, numFeatures),
+		xx:    mat.NewSymDense(numFeatures, nil),
+		xy:    make([]float64, numFeatures),
+	}
+}
+
+// add accumulates one row with the given sample weight.
+func (a *normalEquations) add(featureRow []float64, target, weight float64) {
+	if len(featureRow) != len(a.means) {
+		panic("Feature row and accumulator length mismatch")
+	}
+	if weight == 0 {
+		return
+	}
+	total := a.count + weight
+	// With d = x - x̄ before the update, the co-moments grow by w (W / W') d dᵀ
+	scale := weight * a.count / total
+	deviation := make([]float64, len(featureRow))
+	for j, v := range featureRow {
+		deviation[j] = v - a.means[j]
+		a.means[j] += deviation[j] * weight / total
+	}
+	targetDeviation := target - a.targetMean
+	a.targetMean += targetDeviation * weight / total
+	a.xx.SymRankOne(a.xx, scale, mat.NewVecDense(len(deviation), deviation))
+	for j, d := range deviation {
+		a.xy[j] += scale * d * targetDeviation
+	}
+	a.yy += scale * targetDeviation * targetDeviation
+	a.count = total
+}
+
+// merge adds the rows accumulated by other, as if they had been added to a directly.
+func (a *normalEquations) merge(other *normalEquations) {
+	if len(other.means) != len(a.means) {
+		panic("Accumulator length mismatch")
+	}
+	if other.count == 0 {
+		return
+	}
+	total := a.count + other.count
+	scale := a.count * other.count / total
+	delta := make([]float64, len(a.means))
+	for j := range delta {
+		delta[j] = other.means[j] - a.means[j]
+		a.means[j] += delta[j] * other.count / total
+	}
+	targetDelta := other.targetMean - a.targetMean
+	a.targetMean += targetDelta * other.count / total
+	a.xx.AddSym(a.xx, other.xx)
+	a.xx.SymRankOne(a.xx, scale, mat.NewVecDense(len(delta), delta))
+	for j, d := range delta {
+		a.xy[j] += other.xy[j] + scale*d*targetDelta
+	}
+	a.yy += other.yy + scale*targetDelta*targetDelta
+	a.count = total
+}
+
+// ols solves the accumulated least squares problem, intercept first like linearRegression.
+func (a *normalEquations) ols() ([]float64, error) {
+	return a.ridge(0)
+}
+
+// ridge solves the accumulated ridge problem with the intercept unpenalized, matching
+// ridgeRegression on the same rows: (Σ(x - x̄)(x - x̄)ᵀ + λI) b = Σ(x - x̄)(y - ȳ) for the
+// slopes, then b0 = ȳ - x̄ᵀb.
+func (a *normalEquations) ridge(lambda float64) ([]float64, error) {
+	numFeatures := len(a.means)
+	if a.count <= float64(numFeatures) {
+		return nil, fmt.Errorf("need more than %d rows, got %v", numFeatures, a.count)
+	}
+	system := mat.NewSymDense(numFeatures, nil)
+	system.CopySym(a.xx)
+	for j := 0; j < numFeatures; j++ {
+		system.SetSym(j, j, system.At(j, j)+lambda)
+	}
+	var chol mat.Cholesky
+	if ok := chol.Factorize(system); !ok {
+		return nil, fmt.Errorf("accumulated features are collinear; use ridge with a positive lambda")
+	}
+	slopes := mat.NewVecDense(numFeatures, nil)
+	if err := chol.SolveVecTo(slopes, mat.NewVecDense(numFeatures, a.xy)); err != nil {
+		return nil, err
+	}
+
+	coefficients := make([]float64, numFeatures+1)
+	coefficients[0] = a.targetMean
+	for j := 0; j < numFeatures; j++ {
+		coefficients[j+1] = slopes.AtVec(j)
+		coefficients[0] -= coefficients[j+1] * a.means[j]
+	}
+	return coefficients, nil
+}
+
+// accumulateCSV streams filename, laid out like the file loadCSV reads, into a
+// normalEquations without holding it in memory. Rows are read in chunks; each chunk is
+// split between numWorkers workers that parse into their own accumulators through
+// runTasks, and the workers' accumulators are merged at the end.
+func accumulateCSV(filename string, numWorkers int) (*normalEquations, error) {
+	if numWorkers < 1 {
+		return nil, fmt.Errorf("numWorkers must be at least 1, got %d", numWorkers)
+	}
+	basePath, err := getBasePath()
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(filepath.Join(basePath, filename))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: missing header row", filename)
+	}
+	header := strings.Split(scanner.Text(), ",")
+	if len(header) < 3 {
+		return nil, fmt.Errorf("%s: need a label, features and a target in the header", filename)
+	}
+	numFeatures := len(header) - 2
+
+	workers := make([]*normalEquations, numWorkers)
+	for w := range workers {
+		workers[w] = newNormalEquations(numFeatures)
+	}
+	errs := make([]error, numWorkers)
+	chunk := make([]string, 0, streamChunkRows*numWorkers)
+	lineNumber := 1
+	flush := func() error {
+		// Worker w parses every numWorkers-th line of the chunk
+		firstLine := lineNumber - len(chunk) + 1
+		runTasks(numWorkers, func(w int) {
+			for k := w; k < len(chunk); k += numWorkers {
+				row, target, err := parseStreamLine(chunk[k], numFeatures)
+				if err != nil {
+					errs[w] = fmt.Errorf("%s line %d: %v", filename, firstLine+k, err)
+					return
+				}
+				workers[w].add(row, target, 1)
+			}
+		})
+		chunk = chunk[:0]
+		for _, err := range errs {
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for scanner.Scan() {
+		lineNumber++
+		chunk = append(chunk, scanner.Text())
+		if len(chunk) == cap(chunk) {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+
+	total := workers[0]
+	for _, worker := range workers[1:] {
+		total.merge(worker)
+	}
+	return total, nil
+}
+
+// parseStreamLine parses one CSV line into its features (every column but the first and
+// last) and its target (the last column).
+func parseStreamLine(line string, numFeatures int) ([]float64, float64, error) {
+	fields := strings.Split(line, ",")
+	if len(fields) != numFeatures+2 {
+		return nil, 0, fmt.Errorf("expected %d columns, got %d", numFeatures+2, len(fields))
+	}
+	row := make([]float64, numFeatures)
+	for j, field := range fields[1 : len(fields)-1] {
+		value, err := strconv.ParseFloat(field, 64)
+		if err != nil {
+			return nil, 0, err
+		}
+		row[j] = value
+	}
+	target, err := strconv.ParseFloat(fields[len(fields)-1], 64)
+	return row, target, err
+}
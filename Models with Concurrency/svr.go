@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// svr is epsilon-insensitive support vector regression. Residuals smaller than epsilon
+// cost nothing and larger ones cost linearly, with C trading that cost off against model
+// flatness, so a few extreme prices pull the fit far less than they pull OLS.
+//
+// Fit solves the dual problem with SMO in the LIBSVM formulation: 2n variables α (one for
+// each side of the epsilon tube) in [0, C], updated two at a time along the maximal
+// violating pair until the KKT conditions hold to within tolerance. With sample weights
+// the box of row i becomes [0, C w_i], so a row's residual costs in proportion to its
+// weight.
+type svr struct {
+	kernel        kernel
+	c             float64
+	epsilon       float64
+	tolerance     float64
+	maxIterations int
+	standardize   bool
+
+	scaler       standardScaler
+	features     [][]float64 // scaled support vectors
+	coefficients []float64   // α_i - α*_i for every support vector
+	bias         float64
+	iterations   int
+}
+
+// newSVR returns an svr with the given kernel, C = 1 and epsilon = 0.1 on standardized
+// features.
+func newSVR(k kernel) *svr {
+	return &svr{kernel: k, c: 1, epsilon: 0.1, tolerance: 1e-3, maxIterations: 100000, standardize: true}
+}
+
+func (m *svr) Fit(features [][]float64, target []float64) error {
+	return m.FitWeighted(features, target, nil)
+}
+
+func (m *svr) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	if m.c <= 0 || m.epsilon < 0 {
+		return fmt.Errorf("SVR needs C > 0 and epsilon >= 0, got C = %v and epsilon = %v", m.c, m.epsilon)
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	features, target, weights = positiveWeightRows(features, target, weights)
+
+	m.scaler = identityScaler(len(features[0]))
+	if m.standardize {
+		m.scaler = fitScaler(features)
+	}
+	scaled := m.scaler.transformAll(features)
+
+	n := len(scaled)
+	gram := gramMatrix(m.kernel, scaled, nil)
+
+	// Variables t < n are α_t with sign +1, variables t >= n are α*_t with sign -1.
+	// The dual is min ½ αᵀQα + pᵀα subject to Σ sign_t α_t = 0 and 0 <= α_t <= C_t, with
+	// Q_st = sign_s sign_t K, p = [ε - y, ε + y] and C_t = C w_t.
+	sign := func(t int) float64 {
+		if t < n {
+			return 1
+		}
+		return -1
+	}
+	q := func(s, t int) float64 {
+		return sign(s) * sign(t) * gram.At(s%n, t%n)
+	}
+	bound := func(t int) float64 {
+		return m.c * weights[t%n]
+	}
+	alpha := make([]float64, 2*n)
+	gradient := make([]float64, 2*n)
+	for i, y := range target {
+		gradient[i] = m.epsilon - y
+		gradient[i+n] = m.epsilon + y
+	}
+	inUp := func(t int) bool {
+		return (sign(t) > 0 && alpha[t] < bound(t)) || (sign(t) < 0 && alpha[t] > 0)
+	}
+	inLow := func(t int) bool {
+		return (sign(t) > 0 && alpha[t] > 0) || (sign(t) < 0 && alpha[t] < bound(t))
+	}
+
+	m.iterations = 0
+	for ; m.iterations < m.maxIterations; m.iterations++ {
+		// Select the maximal violating pair
+		i, j := -1, -1
+		maxUp, minLow := math.Inf(-1), math.Inf(1)
+		for t := 0; t < 2*n; t++ {
+			score := -sign(t) * gradient[t]
+			if inUp(t) && score > maxUp {
+				i, maxUp = t, score
+			}
+			if inLow(t) && score < minLow {
+				j, minLow = t, score
+			}
+		}
+		if i < 0 || j < 0 || maxUp-minLow < m.tolerance {
+			break
+		}
+
+		oldI, oldJ := alpha[i], alpha[j]
+		boundI, boundJ := bound(i), bound(j)
+		if sign(i) != sign(j) {
+			quad := math.Max(q(i, i)+q(j, j)+2*q(i, j), 1e-12)
+			delta := (-gradient[i] - gradient[j]) / quad
+			diff := alpha[i] - alpha[j]
+			alpha[i] += delta
+			alpha[j] += delta
+			if diff > 0 {
+				if alpha[j] < 0 {
+					alpha[j], alpha[i] = 0, diff
+				}
+			} else if alpha[i] < 0 {
+				alpha[i], alpha[j] = 0, -diff
+			}
+			if diff > boundI-boundJ {
+				if alpha[i] > boundI {
+					alpha[i], alpha[j] = boundI, boundI-diff
+				}
+			} else if alpha[j] > boundJ {
+				alpha[j], alpha[i] = boundJ, boundJ+diff
+			}
+		} else {
+			quad := math.Max(q(i, i)+q(j, j)-2*q(i, j), 1e-12)
+			delta := (gradient[i] - gradient[j]) / quad
+			sum := alpha[i] + alpha[j]
+			alpha[i] -= delta
+			alpha[j] += delta
+			if sum > boundI {
+				if alpha[i] > boundI {
+					alpha[i], alpha[j] = boundI, sum-boundI
+				}
+			} else if alpha[j] < 0 {
+				alpha[j], alpha[i] = 0, sum
+			}
+			if sum > boundJ {
+				if alpha[j] > boundJ {
+					alpha[j], alpha[i] = boundJ, sum-boundJ
+				}
+			} else if alpha[i] < 0 {
+				alpha[i], alpha[j] = 0, sum
+			}
+		}
+
+		deltaI, deltaJ := alpha[i]-oldI, alpha[j]-oldJ
+		for t := 0; t < 2*n; t++ {
+			gradient[t] += q(t, i)*deltaI + q(t, j)*deltaJ
+		}
+	}
+
+	// The bias is the average over free variables, or the middle of the feasible range
+	var sumFree float64
+	numFree := 0
+	upper, lower := math.Inf(1), math.Inf(-1)
+	for t := 0; t < 2*n; t++ {
+		value := sign(t) * gradient[t]
+		switch {
+		case alpha[t] > 0 && alpha[t] < bound(t):
+			sumFree += value
+			numFree++
+		case (alpha[t] >= bound(t)) == (sign(t) > 0):
+			lower = math.Max(lower, value)
+		default:
+			upper = math.Min(upper, value)
+		}
+	}
+	rho := (upper + lower) / 2
+	if numFree > 0 {
+		rho = sumFree / float64(numFree)
+	}
+	m.bias = -rho
+
+	// Keep only the support vectors, the rows outside or on the tube
+	m.features, m.coefficients = nil, nil
+	for i := 0; i < n; i++ {
+		if coefficient := alpha[i] - alpha[i+n]; coefficient != 0 {
+			m.features = append(m.features, scaled[i])
+			m.coefficients = append(m.coefficients, coefficient)
+		}
+	}
+	return nil
+}
+
+func (m *svr) Predict(featureRow []float64) float64 {
+	scaled := m.scaler.transform(featureRow)
+	prediction := m.bias
+	for i, row := range m.features {
+		prediction += m.coefficients[i] * m.kernel.eval(row, scaled)
+	}
+	return prediction
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSVRLinearKernelResistsOutlier(t *testing.T) {
+	// Create sample data on the line y = 3 + 2x with one gross outlier
+	var features [][]float64
+	var target []float64
+	for i := 0; i < 40; i++ {
+		x := float64(i) / 4
+		features = append(features, []float64{x})
+		target = append(target, 3+2*x)
+	}
+	target[35] += 100
+
+	model := newSVR(linearKernel{variance: 1})
+	model.c = 10
+	model.epsilon = 0.05
+	if err := model.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := 3 + 2*5.0
+	if prediction := model.Predict([]float64{5}); math.Abs(prediction-expected) > 0.2 {
+		t.Errorf("Unexpected SVR prediction. Expected %f, got %f", expected, prediction)
+	}
+
+	// OLS is pulled towards the outlier much more
+	ols := &linearModel{}
+	if err := ols.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if math.Abs(ols.Predict([]float64{5})-expected) < math.Abs(model.Predict([]float64{5})-expected) {
+		t.Errorf("Expected SVR to be closer to the clean line than OLS")
+	}
+}
+
+func TestSVRRBFKernel(t *testing.T) {
+	// Create sample data from a sine curve
+	var features [][]float64
+	var target []float64
+	for i := 0; i < 60; i++ {
+		x := float64(i) / 10
+		features = append(features, []float64{x})
+		target = append(target, math.Sin(x))
+	}
+
+	model := newSVR(rbfKernel{lengthScale: 0.5, variance: 1})
+	model.c = 100
+	model.epsilon = 0.01
+	if err := model.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	predictions := predictAll(model, features)
+	if rmse := rootMeanSquaredError(predictions, target); rmse > 0.02 {
+		t.Errorf("Unexpected training RMSE. Expected at most 0.02, got %f", rmse)
+	}
+	if len(model.coefficients) == 0 || len(model.coefficients) > len(features) {
+		t.Errorf("Unexpected number of support vectors: %d", len(model.coefficients))
+	}
+}
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/stat"
+)
+
+// targetTransform maps the target to a scale that suits the model better and back again.
+type targetTransform interface {
+	// fit estimates any parameters of the transform (e.g. the Box-Cox lambda) from target,
+	// with optional sample weights (nil means equal weights)
+	fit(target []float64, weights []float64) error
+	transform(y float64) float64
+	inverse(z float64) float64
+}
+
+// logTransform fits on log(y); the target must be positive.
+type logTransform struct{}
+
+// boxCoxTransform is (y^λ - 1) / λ, or log(y) when λ is 0. The target must be positive.
+// Unless fixed is set, λ is estimated by maximum likelihood in fit.
+type boxCoxTransform struct {
+	lambda float64
+	fixed  bool
+}
+
+// yeoJohnsonTransform extends Box-Cox to zero and negative targets. Unless fixed is set,
+// λ is estimated by maximum likelihood in fit.
+type yeoJohnsonTransform struct {
+	lambda float64
+	fixed  bool
+}
+
+// transformedTargetRegressor fits the wrapped regressor on a transformed target and maps
+// its predictions back to the original scale, so metrics compare like with like.
+//
+// Inverting a prediction of the mean on the transformed scale gives the median, not the
+// mean, on the original scale. With smearing set, predictions use Duan's smearing
+// estimator instead: the average of inverse(prediction + residual) over the training
+// residuals.
+type transformedTargetRegressor struct {
+	regressor regressor
+	transform targetTransform
+	smearing  bool
+	residuals []float64 // training residuals on the transformed scale
+	// residualWeights are the sample weights of the residuals in the smearing average
+	residualWeights []float64
+}
+
+func (t *transformedTargetRegressor) Fit(features [][]float64, target []float64) error {
+	return t.FitWeighted(features, target, nil)
+}
+
+// FitWeighted passes the weights to the transform and the wrapped regressor, which must
+// then be a weightedRegressor, and weights the smearing average.
+func (t *transformedTargetRegressor) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if weights != nil {
+		if _, err := checkWeights(weights, len(target)); err != nil {
+			return err
+		}
+	}
+	if err := t.transform.fit(target, weights); err != nil {
+		return err
+	}
+
+	transformed := make([]float64, len(target))
+	for i, y := range target {
+		transformed[i] = t.transform.transform(y)
+	}
+	if err := fitWithWeights(t.regressor, features, transformed, weights); err != nil {
+		return err
+	}
+
+	t.residuals, t.residualWeights = nil, weights
+	if t.smearing {
+		t.residuals = make([]float64, len(features))
+		for i, row := range features {
+			t.residuals[i] = transformed[i] - t.regressor.Predict(row)
+		}
+	}
+	return nil
+}
+
+func (t *transformedTargetRegressor) Predict(featureRow []float64) float64 {
+	prediction := t.regressor.Predict(featureRow)
+	if !t.smearing {
+		return t.transform.inverse(prediction)
+	}
+
+	var sum, sumWeights float64
+	for i, residual := range t.residuals {
+		sum += weightAt(t.residualWeights, i) * t.transform.inverse(prediction+residual)
+		sumWeights += weightAt(t.residualWeights, i)
+	}
+	return sum / sumWeights
+}
+
+func (logTransform) fit(target []float64, weights []float64) error {
+	return checkPositive("log", target)
+}
+
+func (logTransform) transform(y float64) float64 {
+	return math.Log(y)
+}
+
+func (logTransform) inverse(z float64) float64 {
+	return math.Exp(z)
+}
+
+func (b *boxCoxTransform) fit(target []float64, weights []float64) error {
+	if err := checkPositive("Box-Cox", target); err != nil {
+		return err
+	}
+	if b.fixed {
+		return nil
+	}
+
+	var sumLog float64
+	for i, y := range target {
+		sumLog += weightAt(weights, i) * math.Log(y)
+	}
+	transformed := make([]float64, len(target))
+	b.lambda = maximizeScalar(func(lambda float64) float64 {
+		for i, y := range target {
+			transformed[i] = boxCox(y, lambda)
+		}
+		return transformLogLikelihood(transformed, weights, (lambda-1)*sumLog)
+	}, -2, 2)
+	return nil
+}
+
+func (b *boxCoxTransform) transform(y float64) float64 {
+	return boxCox(y, b.lambda)
+}
+
+func (b *boxCoxTransform) inverse(z float64) float64 {
+	if b.lambda == 0 {
+		return math.Exp(z)
+	}
+	// Predictions below -1/λ have no preimage; clamp them to the boundary of the range
+	return math.Pow(math.Max(b.lambda*z+1, 0), 1/b.lambda)
+}
+
+func boxCox(y, lambda float64) float64 {
+	if lambda == 0 {
+		return math.Log(y)
+	}
+	return (math.Pow(y, lambda) - 1) / lambda
+}
+
+func (yj *yeoJohnsonTransform) fit(target []float64, weights []float64) error {
+	if yj.fixed {
+		return nil
+	}
+
+	var sumSignedLog float64
+	for i, y := range target {
+		if y >= 0 {
+			sumSignedLog += weightAt(weights, i) * math.Log1p(y)
+		} else {
+			sumSignedLog -= weightAt(weights, i) * math.Log1p(-y)
+		}
+	}
+	transformed := make([]float64, len(target))
+	yj.lambda = maximizeScalar(func(lambda float64) float64 {
+		for i, y := range target {
+			transformed[i] = yeoJohnson(y, lambda)
+		}
+		return transformLogLikelihood(transformed, weights, (lambda-1)*sumSignedLog)
+	}, -2, 4)
+	return nil
+}
+
+func (yj *yeoJohnsonTransform) transform(y float64) float64 {
+	return yeoJohnson(y, yj.lambda)
+}
+
+func (yj *yeoJohnsonTransform) inverse(z float64) float64 {
+	lambda := yj.lambda
+	switch {
+	case z >= 0 && lambda == 0:
+		return math.Expm1(z)
+	case z >= 0:
+		return math.Pow(math.Max(z*lambda+1, 0), 1/lambda) - 1
+	case lambda == 2:
+		return -math.Expm1(-z)
+	default:
+		return 1 - math.Pow(math.Max(-(2-lambda)*z+1, 0), 1/(2-lambda))
+	}
+}
+
+func yeoJohnson(y, lambda float64) float64 {
+	switch {
+	case y >= 0 && lambda == 0:
+		return math.Log1p(y)
+	case y >= 0:
+		return (math.Pow(y+1, lambda) - 1) / lambda
+	case lambda == 2:
+		return -math.Log1p(-y)
+	default:
+		return -(math.Pow(1-y, 2-lambda) - 1) / (2 - lambda)
+	}
+}
+
+// transformLogLikelihood is the profile log-likelihood of a normal model for transformed
+// data, up to a constant; logJacobian is the log of the transform's Jacobian. Rows count
+// weights[i] times, or once each when weights is nil.
+func transformLogLikelihood(transformed []float64, weights []float64, logJacobian float64) float64 {
+	n := float64(len(transformed))
+	if weights != nil {
+		n = floats.Sum(weights)
+	}
+	_, variance := stat.PopMeanVariance(transformed, weights)
+	return -n/2*math.Log(variance) + logJacobian
+}
+
+// weightAt returns weights[i], or 1 when weights is nil.
+func weightAt(weights []float64, i int) float64 {
+	if weights == nil {
+		return 1
+	}
+	return weights[i]
+}
+
+// maximizeScalar finds the maximum of a unimodal function on [lo, hi] by golden section
+// search.
+func maximizeScalar(f func(float64) float64, lo, hi float64) float64 {
+	invPhi := (math.Sqrt(5) - 1) / 2
+	a, b := lo, hi
+	c := b - invPhi*(b-a)
+	d := a + invPhi*(b-a)
+	fc, fd := f(c), f(d)
+	for b-a > 1e-6 {
+		if fc > fd {
+			b, d, fd = d, c, fc
+			c = b - invPhi*(b-a)
+			fc = f(c)
+		} else {
+			a, c, fc = c, d, fd
+			d = a + invPhi*(b-a)
+			fd = f(d)
+		}
+	}
+	return (a + b) / 2
+}
+
+func checkPositive(name string, target []float64) error {
+	for i, y := range target {
+		if y <= 0 {
+			return fmt.Errorf("%s transform needs a positive target, got %v at row %d", name, y, i)
+		}
+	}
+	return nil
+}
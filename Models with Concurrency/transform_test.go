@@ -0,0 +1,73 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestTargetTransformsRoundTrip(t *testing.T) {
+	transforms := map[string]targetTransform{
+		"log":           logTransform{},
+		"box-cox":       &boxCoxTransform{lambda: 0.5, fixed: true},
+		"box-cox-0":     &boxCoxTransform{lambda: 0, fixed: true},
+		"yeo-johnson":   &yeoJohnsonTransform{lambda: 0.5, fixed: true},
+		"yeo-johnson-2": &yeoJohnsonTransform{lambda: 2, fixed: true},
+	}
+	for name, transform := range transforms {
+		for _, y := range []float64{0.5, 5, 24, 50} {
+			got := transform.inverse(transform.transform(y))
+			if math.Abs(got-y) > 1e-9 {
+				t.Errorf("Unexpected %s round trip. Expected %f, got %f", name, y, got)
+			}
+		}
+	}
+
+	// Yeo-Johnson also handles negative values
+	yj := &yeoJohnsonTransform{lambda: 0.5, fixed: true}
+	if got := yj.inverse(yj.transform(-3)); math.Abs(got+3) > 1e-9 {
+		t.Errorf("Unexpected Yeo-Johnson round trip. Expected -3, got %f", got)
+	}
+}
+
+func TestBoxCoxEstimatesLambda(t *testing.T) {
+	// Create sample data that is exactly the exponential of evenly spaced values,
+	// so log (λ = 0) makes it symmetric
+	target := make([]float64, 101)
+	for i := range target {
+		target[i] = math.Exp(float64(i-50) / 25)
+	}
+
+	transform := &boxCoxTransform{}
+	if err := transform.fit(target, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if math.Abs(transform.lambda) > 0.1 {
+		t.Errorf("Unexpected Box-Cox lambda. Expected about 0, got %f", transform.lambda)
+	}
+
+	if err := transform.fit([]float64{1, 0, 2}, nil); err == nil {
+		t.Errorf("Expected an error for a non-positive target")
+	}
+}
+
+func TestTransformedTargetRegressor(t *testing.T) {
+	// Create sample data where log(y) is linear in x
+	var features [][]float64
+	var target []float64
+	for i := 0; i < 30; i++ {
+		x := float64(i) / 10
+		features = append(features, []float64{x})
+		target = append(target, math.Exp(1+0.5*x))
+	}
+
+	model := &transformedTargetRegressor{regressor: &linearModel{}, transform: logTransform{}, smearing: true}
+	if err := model.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Predictions come back on the original scale
+	expected := math.Exp(1 + 0.5*1.5)
+	if prediction := model.Predict([]float64{1.5}); math.Abs(prediction-expected) > 1e-6 {
+		t.Errorf("Unexpected prediction. Expected %f, got %f", expected, prediction)
+	}
+}
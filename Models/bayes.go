@@ -0,0 +1,420 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat/distuv"
+)
+
+// bayesianLinearRegression is a linear model with the conjugate normal-inverse-gamma
+// prior b | σ² ~ N(m0, σ² Λ0⁻¹), σ² ~ InvGamma(a0, b0). The posterior has the same form,
+// so Fit is exact: the coefficients are marginally multivariate Student t and the price
+// of a new row is Student t with 2a_n degrees of freedom.
+//
+// The prior on a coefficient is in units of the noise: setPrior(…, "rooms", 8, 2) says
+// the effect of a room is about 8 give or take 2σ.
+type bayesianLinearRegression struct {
+	priorMean      []float64 // m0, intercept first; nil means zeros
+	priorPrecision []float64 // diagonal of Λ0, intercept first; nil means 1e-6 (nearly flat)
+	priorShape     float64   // a0
+	priorRate      float64   // b0
+
+	posteriorMean  []float64     // m_n, intercept first like linearRegression
+	posteriorScale *mat.SymDense // Λ_n⁻¹, so the coefficient covariance is b_n/(a_n-1) Λ_n⁻¹
+	posteriorShape float64       // a_n
+	posteriorRate  float64       // b_n
+	// logEvidence is log p(y | X) under the prior, for comparing priors
+	logEvidence float64
+}
+
+// empiricalBayesRegression is Bayesian ridge regression whose prior precision α and noise
+// precision β are chosen by maximizing the evidence (type II maximum likelihood) with
+// MacKay's fixed-point updates. With perFeature set every coefficient gets its own α
+// (automatic relevance determination), and features whose α exceeds pruneThreshold are
+// pruned to exactly zero. Features and target are centered, so the intercept is
+// unpenalized.
+type empiricalBayesRegression struct {
+	perFeature     bool
+	maxIterations  int
+	tolerance      float64
+	pruneThreshold float64
+
+	coefficients        []float64     // posterior mean, intercept first
+	posteriorCovariance *mat.SymDense // of the coefficients, intercept first
+	alphas              []float64     // prior precision per feature, +Inf when pruned
+	noisePrecision      float64       // β
+	iterations          int
+}
+
+// newBayesianLinearRegression returns a bayesianLinearRegression with a nearly flat prior.
+func newBayesianLinearRegression() *bayesianLinearRegression {
+	return &bayesianLinearRegression{priorShape: 1e-6, priorRate: 1e-6}
+}
+
+// newBayesianRidge returns an empiricalBayesRegression with one shared prior precision.
+func newBayesianRidge() *empiricalBayesRegression {
+	return &empiricalBayesRegression{maxIterations: 300, tolerance: 1e-6, pruneThreshold: 1e4}
+}
+
+// newARD returns an empiricalBayesRegression with automatic relevance determination.
+func newARD() *empiricalBayesRegression {
+	model := newBayesianRidge()
+	model.perFeature = true
+	return model
+}
+
+// setPrior puts the prior N(mean, (scale σ)²) on the coefficient of column. Coefficients
+// without a prior of their own keep the nearly flat default.
+func (m *bayesianLinearRegression) setPrior(columnNames []string, column string, mean, scale float64) error {
+	j := indexOf(columnNames, column)
+	if j < 0 {
+		return fmt.Errorf("unknown column %q", column)
+	}
+	if scale <= 0 {
+		return fmt.Errorf("prior scale must be positive, got %v", scale)
+	}
+	numCoefficients := len(columnNames) + 1
+	if m.priorMean == nil {
+		m.priorMean = make([]float64, numCoefficients)
+	}
+	if m.priorPrecision == nil {
+		m.priorPrecision = make([]float64, numCoefficients)
+		for k := range m.priorPrecision {
+			m.priorPrecision[k] = 1e-6
+		}
+	}
+	m.priorMean[j+1] = mean
+	m.priorPrecision[j+1] = 1 / (scale * scale)
+	return nil
+}
+
+func (m *bayesianLinearRegression) Fit(features [][]float64, target []float64) error {
+	return m.FitWeighted(features, target, nil)
+}
+
+// FitWeighted treats the weights as frequencies, so a_n grows by half the total weight.
+func (m *bayesianLinearRegression) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	if m.priorShape <= 0 || m.priorRate <= 0 {
+		return fmt.Errorf("prior shape and rate must be positive")
+	}
+	numCoefficients := len(features[0]) + 1
+	priorMean := m.priorMean
+	if priorMean == nil {
+		priorMean = make([]float64, numCoefficients)
+	}
+	priorPrecision := m.priorPrecision
+	if priorPrecision == nil {
+		priorPrecision = make([]float64, numCoefficients)
+		for j := range priorPrecision {
+			priorPrecision[j] = 1e-6
+		}
+	}
+	if len(priorMean) != numCoefficients || len(priorPrecision) != numCoefficients {
+		return fmt.Errorf("prior needs %d entries, intercept first", numCoefficients)
+	}
+
+	// Λ_n = Λ0 + XᵀWX and m_n = Λ_n⁻¹ (Λ0 m0 + XᵀWy)
+	precision := mat.NewSymDense(numCoefficients, nil)
+	rhs := make([]float64, numCoefficients)
+	logPriorDeterminant := 0.0
+	for j := 0; j < numCoefficients; j++ {
+		if priorPrecision[j] <= 0 {
+			return fmt.Errorf("prior precision must be positive, got %v", priorPrecision[j])
+		}
+		precision.SetSym(j, j, priorPrecision[j])
+		rhs[j] = priorPrecision[j] * priorMean[j]
+		logPriorDeterminant += math.Log(priorPrecision[j])
+	}
+	row := make([]float64, numCoefficients)
+	var sumWeights float64
+	for i, featureRow := range features {
+		row[0] = 1
+		copy(row[1:], featureRow)
+		precision.SymRankOne(precision, weights[i], mat.NewVecDense(numCoefficients, row))
+		floats.AddScaled(rhs, weights[i]*target[i], row)
+		sumWeights += weights[i]
+	}
+	var chol mat.Cholesky
+	if ok := chol.Factorize(precision); !ok {
+		return fmt.Errorf("posterior precision is not positive definite")
+	}
+	mean := mat.NewVecDense(numCoefficients, nil)
+	if err := chol.SolveVecTo(mean, mat.NewVecDense(numCoefficients, rhs)); err != nil {
+		return err
+	}
+	m.posteriorMean = mean.RawVector().Data
+	m.posteriorScale = mat.NewSymDense(numCoefficients, nil)
+	if err := chol.InverseTo(m.posteriorScale); err != nil {
+		return err
+	}
+
+	// b_n = b0 + ½ (Σ w (y - x·m_n)² + (m_n - m0)ᵀ Λ0 (m_n - m0))
+	var residualSquares, priorSquares float64
+	for i, featureRow := range features {
+		r := target[i] - predictLin(featureRow, m.posteriorMean)
+		residualSquares += weights[i] * r * r
+	}
+	for j := range priorMean {
+		d := m.posteriorMean[j] - priorMean[j]
+		priorSquares += priorPrecision[j] * d * d
+	}
+	m.posteriorShape = m.priorShape + sumWeights/2
+	m.posteriorRate = m.priorRate + (residualSquares+priorSquares)/2
+
+	logGammaN, _ := math.Lgamma(m.posteriorShape)
+	logGamma0, _ := math.Lgamma(m.priorShape)
+	m.logEvidence = -sumWeights/2*math.Log(2*math.Pi) + (logPriorDeterminant-chol.LogDet())/2 +
+		m.priorShape*math.Log(m.priorRate) - m.posteriorShape*math.Log(m.posteriorRate) + logGammaN - logGamma0
+	return nil
+}
+
+// Predict returns the posterior predictive mean.
+func (m *bayesianLinearRegression) Predict(featureRow []float64) float64 {
+	return predictLin(featureRow, m.posteriorMean)
+}
+
+// posteriorCovariance returns the covariance of the coefficients, intercept first. It is
+// infinite while a_n <= 1.
+func (m *bayesianLinearRegression) posteriorCovariance() *mat.SymDense {
+	var covariance mat.SymDense
+	covariance.ScaleSym(m.posteriorRate/(m.posteriorShape-1), m.posteriorScale)
+	return &covariance
+}
+
+// predictive returns the Student t posterior predictive distribution of the price of
+// featureRow: location x·m_n, scale sqrt(b_n/a_n (1 + xᵀΛ_n⁻¹x)) and 2a_n degrees of
+// freedom.
+func (m *bayesianLinearRegression) predictive(featureRow []float64) distuv.StudentsT {
+	x := mat.NewVecDense(len(featureRow)+1, append([]float64{1}, featureRow...))
+	spread := m.posteriorRate / m.posteriorShape * (1 + mat.Inner(x, m.posteriorScale, x))
+	return distuv.StudentsT{Mu: m.Predict(featureRow), Sigma: math.Sqrt(spread), Nu: 2 * m.posteriorShape}
+}
+
+// predictiveInterval returns the central credible interval with probability level for the
+// price of featureRow.
+func (m *bayesianLinearRegression) predictiveInterval(featureRow []float64, level float64) (float64, float64) {
+	predictive := m.predictive(featureRow)
+	return predictive.Quantile((1 - level) / 2), predictive.Quantile((1 + level) / 2)
+}
+
+// credibleIntervals returns the central credible interval with probability level for every
+// coefficient, intercept first.
+func (m *bayesianLinearRegression) credibleIntervals(level float64) ([]float64, []float64) {
+	lower := make([]float64, len(m.posteriorMean))
+	upper := make([]float64, len(m.posteriorMean))
+	for j, mean := range m.posteriorMean {
+		marginal := distuv.StudentsT{
+			Mu:    mean,
+			Sigma: math.Sqrt(m.posteriorRate / m.posteriorShape * m.posteriorScale.At(j, j)),
+			Nu:    2 * m.posteriorShape,
+		}
+		lower[j], upper[j] = marginal.Quantile((1-level)/2), marginal.Quantile((1+level)/2)
+	}
+	return lower, upper
+}
+
+func (m *empiricalBayesRegression) Fit(features [][]float64, target []float64) error {
+	return m.FitWeighted(features, target, nil)
+}
+
+// FitWeighted treats the weights as frequencies in the centering, the Gram matrix and the
+// evidence.
+func (m *empiricalBayesRegression) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	numFeatures := len(features[0])
+	sumWeights := floats.Sum(weights)
+
+	// Center with the weighted means so the intercept drops out of the evidence
+	featureMeans := make([]float64, numFeatures)
+	var targetMean float64
+	for i, row := range features {
+		floats.AddScaled(featureMeans, weights[i], row)
+		targetMean += weights[i] * target[i]
+	}
+	floats.Scale(1/sumWeights, featureMeans)
+	targetMean /= sumWeights
+
+	gram := mat.NewSymDense(numFeatures, nil)
+	crossProducts := make([]float64, numFeatures)
+	centered := make([]float64, numFeatures)
+	var targetSquares float64
+	for i, row := range features {
+		floats.SubTo(centered, row, featureMeans)
+		y := target[i] - targetMean
+		gram.SymRankOne(gram, weights[i], mat.NewVecDense(numFeatures, centered))
+		floats.AddScaled(crossProducts, weights[i]*y, centered)
+		targetSquares += weights[i] * y * y
+	}
+	if targetSquares == 0 {
+		targetSquares = 1
+	}
+
+	m.alphas = make([]float64, numFeatures)
+	for j := range m.alphas {
+		m.alphas[j] = 1
+	}
+	m.noisePrecision = sumWeights / targetSquares
+
+	var slopes []float64
+	for m.iterations = 1; m.iterations <= m.maxIterations; m.iterations++ {
+		next, nextCovariance, err := m.posterior(gram, crossProducts)
+		if err != nil {
+			return err
+		}
+
+		// γ_j = 1 - α_j Σ_jj is how well determined coefficient j is by the data
+		var gamma, sumSquares float64
+		gammas := make([]float64, numFeatures)
+		for j := range next {
+			if math.IsInf(m.alphas[j], 1) {
+				continue
+			}
+			gammas[j] = 1 - m.alphas[j]*nextCovariance.At(j, j)
+			gamma += gammas[j]
+			sumSquares += next[j] * next[j]
+		}
+		// Σ w (y - x·b)² expanded on the centered data
+		residualSquares := targetSquares - 2*floats.Dot(next, crossProducts) + mat.Inner(mat.NewVecDense(numFeatures, next), gram, mat.NewVecDense(numFeatures, next))
+		residualSquares = math.Max(residualSquares, 1e-12*targetSquares)
+
+		for j := range m.alphas {
+			if math.IsInf(m.alphas[j], 1) {
+				continue
+			}
+			if m.perFeature {
+				m.alphas[j] = gammas[j] / math.Max(next[j]*next[j], 1e-300)
+				if m.alphas[j] > m.pruneThreshold {
+					m.alphas[j] = math.Inf(1)
+				}
+			} else {
+				m.alphas[j] = gamma / math.Max(sumSquares, 1e-300)
+			}
+		}
+		m.noisePrecision = math.Max(sumWeights-gamma, 1e-12) / residualSquares
+
+		converged := slopes != nil && floats.Distance(next, slopes, math.Inf(1)) < m.tolerance*(1+floats.Norm(slopes, math.Inf(1)))
+		slopes = next
+		if converged {
+			break
+		}
+	}
+	if m.iterations > m.maxIterations {
+		m.iterations = m.maxIterations
+	}
+	slopes, covariance, err := m.posterior(gram, crossProducts)
+	if err != nil {
+		return err
+	}
+
+	// The intercept is ȳ - x̄·b, with variance 1/(βW) + x̄ᵀΣx̄ and covariance -Σx̄ with b
+	m.coefficients = append([]float64{targetMean - floats.Dot(featureMeans, slopes)}, slopes...)
+	means := mat.NewVecDense(numFeatures, featureMeans)
+	var crossCovariance mat.VecDense
+	crossCovariance.MulVec(covariance, means)
+	m.posteriorCovariance = mat.NewSymDense(numFeatures+1, nil)
+	m.posteriorCovariance.SetSym(0, 0, 1/(m.noisePrecision*sumWeights)+mat.Dot(means, &crossCovariance))
+	for j := 0; j < numFeatures; j++ {
+		m.posteriorCovariance.SetSym(0, j+1, -crossCovariance.AtVec(j))
+		for k := j; k < numFeatures; k++ {
+			m.posteriorCovariance.SetSym(j+1, k+1, covariance.At(j, k))
+		}
+	}
+	return nil
+}
+
+// posterior returns the posterior mean β Σ Xᵀy and covariance Σ = (β XᵀX + diag(α))⁻¹ of
+// the slopes under the current α and β. Pruned features keep a zero mean and variance.
+func (m *empiricalBayesRegression) posterior(gram *mat.SymDense, crossProducts []float64) ([]float64, *mat.SymDense, error) {
+	numFeatures := len(crossProducts)
+	var active []int
+	for j, alpha := range m.alphas {
+		if !math.IsInf(alpha, 1) {
+			active = append(active, j)
+		}
+	}
+	slopes := make([]float64, numFeatures)
+	covariance := mat.NewSymDense(numFeatures, nil)
+	if len(active) == 0 {
+		return slopes, covariance, nil
+	}
+
+	precision := mat.NewSymDense(len(active), nil)
+	rhs := make([]float64, len(active))
+	for a, j := range active {
+		for b := a; b < len(active); b++ {
+			precision.SetSym(a, b, m.noisePrecision*gram.At(j, active[b]))
+		}
+		precision.SetSym(a, a, precision.At(a, a)+m.alphas[j])
+		rhs[a] = m.noisePrecision * crossProducts[j]
+	}
+	var chol mat.Cholesky
+	if ok := chol.Factorize(precision); !ok {
+		return nil, nil, fmt.Errorf("posterior precision is not positive definite")
+	}
+	var activeCovariance mat.SymDense
+	if err := chol.InverseTo(&activeCovariance); err != nil {
+		return nil, nil, err
+	}
+	var activeSlopes mat.VecDense
+	activeSlopes.MulVec(&activeCovariance, mat.NewVecDense(len(active), rhs))
+	for a, j := range active {
+		slopes[j] = activeSlopes.AtVec(a)
+		for b := a; b < len(active); b++ {
+			covariance.SetSym(j, active[b], activeCovariance.At(a, b))
+		}
+	}
+	return slopes, covariance, nil
+}
+
+// Predict returns the posterior predictive mean.
+func (m *empiricalBayesRegression) Predict(featureRow []float64) float64 {
+	return predictLin(featureRow, m.coefficients)
+}
+
+// predictWithVariance returns the Gaussian posterior predictive mean and variance of the
+// price of featureRow, 1/β + [1, x]ᵀ Σ [1, x].
+func (m *empiricalBayesRegression) predictWithVariance(featureRow []float64) (float64, float64) {
+	x := mat.NewVecDense(len(featureRow)+1, append([]float64{1}, featureRow...))
+	return m.Predict(featureRow), 1/m.noisePrecision + mat.Inner(x, m.posteriorCovariance, x)
+}
+
+// predictiveInterval returns the central credible interval with probability level for the
+// price of featureRow.
+func (m *empiricalBayesRegression) predictiveInterval(featureRow []float64, level float64) (float64, float64) {
+	mean, variance := m.predictWithVariance(featureRow)
+	predictive := distuv.Normal{Mu: mean, Sigma: math.Sqrt(variance)}
+	return predictive.Quantile((1 - level) / 2), predictive.Quantile((1 + level) / 2)
+}
+
+// credibleIntervals returns the central credible interval with probability level for every
+// coefficient, intercept first. Pruned coefficients get the interval [0, 0].
+func (m *empiricalBayesRegression) credibleIntervals(level float64) ([]float64, []float64) {
+	lower := make([]float64, len(m.coefficients))
+	upper := make([]float64, len(m.coefficients))
+	for j, mean := range m.coefficients {
+		sd := math.Sqrt(m.posteriorCovariance.At(j, j))
+		if sd == 0 {
+			lower[j], upper[j] = mean, mean
+			continue
+		}
+		marginal := distuv.Normal{Mu: mean, Sigma: sd}
+		lower[j], upper[j] = marginal.Quantile((1-level)/2), marginal.Quantile((1+level)/2)
+	}
+	return lower, upper
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// noisyLinearData returns rows on y = 1 + 2*x1 - x2 with Gaussian noise of standard
+// deviation 0.5, plus a third feature that has no effect.
+func noisyLinearData(n int) ([][]float64, []float64) {
+	rng := rand.New(rand.NewSource(1))
+	var features [][]float64
+	var target []float64
+	for i := 0; i < n; i++ {
+		x1, x2, x3 := rng.Float64()*4, rng.Float64()*4, rng.Float64()*4
+		features = append(features, []float64{x1, x2, x3})
+		target = append(target, 1+2*x1-x2+0.5*rng.NormFloat64())
+	}
+	return features, target
+}
+
+func TestBayesianLinearRegressionFlatPriorMatchesOLS(t *testing.T) {
+	features, target := noisyLinearData(200)
+	model := newBayesianLinearRegression()
+	if err := model.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := linearRegression(features, target)
+	for j := range expected {
+		if math.Abs(model.posteriorMean[j]-expected[j]) > 1e-4 {
+			t.Errorf("Unexpected posterior mean %d. Expected %f, got %f", j, expected[j], model.posteriorMean[j])
+		}
+	}
+
+	// The 95% credible intervals cover the true coefficients
+	lower, upper := model.credibleIntervals(0.95)
+	for j, truth := range []float64{1, 2, -1, 0} {
+		if truth < lower[j] || truth > upper[j] {
+			t.Errorf("Unexpected credible interval for coefficient %d: [%f, %f] misses %f", j, lower[j], upper[j], truth)
+		}
+	}
+	if variance := model.posteriorCovariance().At(1, 1); variance <= 0 || variance > 0.01 {
+		t.Errorf("Unexpected posterior variance of the first slope: %f", variance)
+	}
+
+	// About 95% of the training prices fall inside their predictive intervals
+	inside := 0
+	for i, row := range features {
+		lo, hi := model.predictiveInterval(row, 0.95)
+		if target[i] >= lo && target[i] <= hi {
+			inside++
+		}
+	}
+	if coverage := float64(inside) / float64(len(target)); coverage < 0.9 || coverage > 0.99 {
+		t.Errorf("Unexpected predictive coverage. Expected about 0.95, got %f", coverage)
+	}
+}
+
+func TestBayesianLinearRegressionStrongPrior(t *testing.T) {
+	features, target := noisyLinearData(20)
+	model := newBayesianLinearRegression()
+	if err := model.setPrior([]string{"rooms", "lstat", "age"}, "rooms", 5, 0.001); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := model.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if math.Abs(model.posteriorMean[1]-5) > 0.01 {
+		t.Errorf("Unexpected rooms coefficient under a tight prior. Expected about 5, got %f", model.posteriorMean[1])
+	}
+	if err := model.setPrior([]string{"rooms"}, "missing", 0, 1); err == nil {
+		t.Errorf("Expected an error for an unknown column")
+	}
+}
+
+func TestEmpiricalBayesRegression(t *testing.T) {
+	features, target := noisyLinearData(200)
+
+	ridge := newBayesianRidge()
+	if err := ridge.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []float64{1, 2, -1, 0}
+	for j := range expected {
+		if math.Abs(ridge.coefficients[j]-expected[j]) > 0.25 {
+			t.Errorf("Unexpected Bayesian ridge coefficient %d. Expected about %f, got %f", j, expected[j], ridge.coefficients[j])
+		}
+	}
+	// The noise precision estimates 1 / 0.5²
+	if ridge.noisePrecision < 3 || ridge.noisePrecision > 5.5 {
+		t.Errorf("Unexpected noise precision. Expected about 4, got %f", ridge.noisePrecision)
+	}
+
+	// ARD switches off the feature that has no effect
+	ard := newARD()
+	if err := ard.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if math.Abs(ard.coefficients[3]) > 0.02 {
+		t.Errorf("Unexpected ARD coefficient for the irrelevant feature: %f", ard.coefficients[3])
+	}
+	if math.Abs(ard.coefficients[1]-2) > 0.1 {
+		t.Errorf("Unexpected ARD coefficient for x1. Expected about 2, got %f", ard.coefficients[1])
+	}
+
+	mean, variance := ard.predictWithVariance(features[0])
+	lo, hi := ard.predictiveInterval(features[0], 0.95)
+	if variance < 1/ard.noisePrecision || lo >= mean || hi <= mean {
+		t.Errorf("Unexpected predictive distribution: mean %f, variance %f, interval [%f, %f]", mean, variance, lo, hi)
+	}
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// decisionTree is a CART regression tree. Splits are chosen to maximize the reduction in
+// variance, growth stops at maxDepth or when a leaf would get fewer than minSamplesLeaf
+// rows, and the grown tree is then pruned by minimal cost-complexity pruning with ccpAlpha.
+type decisionTree struct {
+	maxDepth       int     // 0 means no limit
+	minSamplesLeaf int     // at least 1
+	ccpAlpha       float64 // complexity parameter, 0 disables pruning
+	maxFeatures    int     // features drawn at random for each split, 0 means all
+	seed           int64   // seeds the feature draws when maxFeatures is set
+
+	root *treeNode
+}
+
+// newDecisionTree returns an unpruned decisionTree with no depth limit.
+func newDecisionTree() *decisionTree {
+	return &decisionTree{minSamplesLeaf: 1}
+}
+
+func (d *decisionTree) Fit(features [][]float64, target []float64) error {
+	return d.FitWeighted(features, target, nil)
+}
+
+// FitWeighted grows the tree on weighted variance reduction with weighted leaf means, and
+// prunes on the weighted squared error. minSamplesLeaf still counts rows.
+func (d *decisionTree) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	if len(features) == 0 {
+		return fmt.Errorf("cannot fit a tree on no rows")
+	}
+	if d.minSamplesLeaf < 1 {
+		return fmt.Errorf("minSamplesLeaf must be at least 1, got %d", d.minSamplesLeaf)
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+
+	rows := make([]int, len(features))
+	for i := range rows {
+		rows[i] = i
+	}
+	builder := cartBuilder{
+		features:       features,
+		target:         target,
+		weights:        weights,
+		maxDepth:       d.maxDepth,
+		minSamplesLeaf: d.minSamplesLeaf,
+		maxFeatures:    d.maxFeatures,
+		rng:            rand.New(rand.NewSource(d.seed)),
+	}
+	d.root = builder.build(rows, 0)
+	if d.ccpAlpha > 0 {
+		pruneTree(d.root, d.ccpAlpha)
+	}
+	return nil
+}
+
+func (d *decisionTree) Predict(featureRow []float64) float64 {
+	return d.root.predict(featureRow)
+}
+
+// cartBuilder grows a tree by exhaustive search over every threshold of every candidate
+// feature.
+type cartBuilder struct {
+	features       [][]float64
+	target         []float64
+	weights        []float64
+	maxDepth       int
+	minSamplesLeaf int
+	maxFeatures    int
+	rng            *rand.Rand
+}
+
+func (b cartBuilder) build(rows []int, depth int) *treeNode {
+	var sum, sumSquares, sumWeights float64
+	for _, i := range rows {
+		w := b.weights[i]
+		sum += w * b.target[i]
+		sumSquares += w * b.target[i] * b.target[i]
+		sumWeights += w
+	}
+	node := &treeNode{samples: len(rows), weight: sumWeights}
+	if sumWeights > 0 {
+		node.value = sum / sumWeights
+		node.squaredError = math.Max(sumSquares-sum*sum/sumWeights, 0)
+	}
+	if (b.maxDepth > 0 && depth >= b.maxDepth) || len(rows) < 2*b.minSamplesLeaf || node.squaredError == 0 {
+		return node
+	}
+
+	bestFeature, bestThreshold, bestGain := -1, 0.0, 1e-12
+	sorted := append([]int(nil), rows...)
+	for _, j := range b.candidateFeatures() {
+		sort.Slice(sorted, func(a, c int) bool { return b.features[sorted[a]][j] < b.features[sorted[c]][j] })
+
+		// Scan the thresholds between consecutive distinct values, keeping running sums
+		var leftSum, leftWeight float64
+		for k := 0; k < len(sorted)-1; k++ {
+			leftSum += b.weights[sorted[k]] * b.target[sorted[k]]
+			leftWeight += b.weights[sorted[k]]
+			leftCount := k + 1
+			rightCount := len(sorted) - leftCount
+			if leftCount < b.minSamplesLeaf {
+				continue
+			}
+			if rightCount < b.minSamplesLeaf {
+				break
+			}
+			current, next := b.features[sorted[k]][j], b.features[sorted[k+1]][j]
+			if current == next {
+				continue
+			}
+			rightSum, rightWeight := sum-leftSum, sumWeights-leftWeight
+			if leftWeight <= 0 || rightWeight <= 0 {
+				continue
+			}
+			gain := leftSum*leftSum/leftWeight + rightSum*rightSum/rightWeight - sum*sum/sumWeights
+			if gain > bestGain {
+				bestFeature, bestThreshold, bestGain = j, (current+next)/2, gain
+			}
+		}
+	}
+	if bestFeature < 0 {
+		return node
+	}
+
+	var leftRows, rightRows []int
+	for _, i := range rows {
+		if b.features[i][bestFeature] <= bestThreshold {
+			leftRows = append(leftRows, i)
+		} else {
+			rightRows = append(rightRows, i)
+		}
+	}
+	node.feature = bestFeature
+	node.threshold = bestThreshold
+	node.left = b.build(leftRows, depth+1)
+	node.right = b.build(rightRows, depth+1)
+	return node
+}
+
+// candidateFeatures returns the features to search for a split: all of them, or a random
+// subset of maxFeatures as in a random forest.
+func (b cartBuilder) candidateFeatures() []int {
+	numFeatures := len(b.features[0])
+	if b.maxFeatures <= 0 || b.maxFeatures >= numFeatures {
+		all := make([]int, numFeatures)
+		for j := range all {
+			all[j] = j
+		}
+		return all
+	}
+	return b.rng.Perm(numFeatures)[:b.maxFeatures]
+}
+
+// pruneTree repeatedly collapses the weakest link of the tree, the internal node whose
+// split buys the least reduction in training MSE per extra leaf, while that reduction is
+// at most alpha.
+func pruneTree(root *treeNode, alpha float64) {
+	for {
+		weakest, strength := weakestLink(root, root.weight)
+		if weakest == nil || strength > alpha {
+			return
+		}
+		weakest.left, weakest.right = nil, nil
+	}
+}
+
+// costComplexityPath returns the increasing effective alphas at which pruning collapses
+// another weakest link, and the total leaf MSE of the tree after each collapse. Refitting
+// with ccpAlpha set to one of the alphas reproduces that subtree.
+func (d *decisionTree) costComplexityPath() ([]float64, []float64) {
+	root := copyTree(d.root)
+	total := root.weight
+	alphas := []float64{0}
+	_, squaredError := subtreeStats(root)
+	impurities := []float64{squaredError / total}
+	for root.left != nil {
+		weakest, strength := weakestLink(root, total)
+		weakest.left, weakest.right = nil, nil
+		_, squaredError = subtreeStats(root)
+		alphas = append(alphas, math.Max(strength, alphas[len(alphas)-1]))
+		impurities = append(impurities, squaredError/total)
+	}
+	return alphas, impurities
+}
+
+// weakestLink finds the internal node with the smallest
+// g(t) = (R(t) - R(subtree)) / (leaves(subtree) - 1), with R the squared error divided by
+// the total training weight.
+func weakestLink(node *treeNode, total float64) (*treeNode, float64) {
+	if node.left == nil {
+		return nil, math.Inf(1)
+	}
+	leaves, squaredError := subtreeStats(node)
+	weakest, strength := node, (node.squaredError-squaredError)/total/float64(leaves-1)
+	for _, child := range []*treeNode{node.left, node.right} {
+		if candidate, candidateStrength := weakestLink(child, total); candidate != nil && candidateStrength < strength {
+			weakest, strength = candidate, candidateStrength
+		}
+	}
+	return weakest, strength
+}
+
+// subtreeStats returns the number of leaves under node and the sum of their squared errors.
+func subtreeStats(node *treeNode) (int, float64) {
+	if node.left == nil {
+		return 1, node.squaredError
+	}
+	leftLeaves, leftError := subtreeStats(node.left)
+	rightLeaves, rightError := subtreeStats(node.right)
+	return leftLeaves + rightLeaves, leftError + rightError
+}
+
+func copyTree(node *treeNode) *treeNode {
+	if node == nil {
+		return nil
+	}
+	copied := *node
+	copied.left = copyTree(node.left)
+	copied.right = copyTree(node.right)
+	return &copied
+}
+
+// dump renders the tree as indented text using the given feature column names, e.g.
+//
+//	|--- lstat <= 9.72
+//	|   |--- value: 29.86 (samples: 212)
+//	|--- lstat >  9.72
+//	|   |--- value: 17.48 (samples: 294)
+func (d *decisionTree) dump(columnNames []string) string {
+	var sb strings.Builder
+	dumpNode(&sb, d.root, columnNames, 0)
+	return sb.String()
+}
+
+func dumpNode(sb *strings.Builder, node *treeNode, columnNames []string, depth int) {
+	indent := strings.Repeat("|   ", depth)
+	if node.left == nil {
+		fmt.Fprintf(sb, "%s|--- value: %.2f (samples: %d)\n", indent, node.value, node.samples)
+		return
+	}
+
+	name := featureName(columnNames, node.feature)
+	fmt.Fprintf(sb, "%s|--- %s <= %.2f\n", indent, name, node.threshold)
+	dumpNode(sb, node.left, columnNames, depth+1)
+	fmt.Fprintf(sb, "%s|--- %s >  %.2f\n", indent, name, node.threshold)
+	dumpNode(sb, node.right, columnNames, depth+1)
+}
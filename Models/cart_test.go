@@ -0,0 +1,103 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecisionTreeSplitsOnInformativeFeature(t *testing.T) {
+	// Create sample data where only the second feature matters
+	features := [][]float64{
+		{1, 1}, {2, 1}, {3, 1}, {4, 1},
+		{1, 5}, {2, 5}, {3, 5}, {4, 5},
+	}
+	target := []float64{10, 10, 10, 10, 20, 20, 20, 20}
+
+	tree := newDecisionTree()
+	if err := tree.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if tree.root.feature != 1 || tree.root.threshold != 3 {
+		t.Errorf("Unexpected root split. Expected feature 1 at 3, got feature %d at %f", tree.root.feature, tree.root.threshold)
+	}
+	if prediction := tree.Predict([]float64{2, 4}); prediction != 20 {
+		t.Errorf("Unexpected prediction. Expected %f, got %f", 20.0, prediction)
+	}
+
+	dump := tree.dump([]string{"rooms", "lstat"})
+	if !strings.Contains(dump, "|--- lstat <= 3.00") || !strings.Contains(dump, "value: 10.00 (samples: 4)") {
+		t.Errorf("Unexpected tree dump:\n%s", dump)
+	}
+}
+
+func TestDecisionTreeDepthAndLeafLimits(t *testing.T) {
+	// Create sample data with a distinct target for every row
+	var features [][]float64
+	var target []float64
+	for i := 0; i < 32; i++ {
+		features = append(features, []float64{float64(i)})
+		target = append(target, float64(i*i))
+	}
+
+	tree := newDecisionTree()
+	tree.maxDepth = 2
+	tree.minSamplesLeaf = 3
+	if err := tree.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	leaves, _ := subtreeStats(tree.root)
+	if leaves > 4 {
+		t.Errorf("Unexpected number of leaves at depth 2. Expected at most 4, got %d", leaves)
+	}
+	var checkLeaves func(node *treeNode)
+	checkLeaves = func(node *treeNode) {
+		if node.left == nil {
+			if node.samples < 3 {
+				t.Errorf("Leaf has %d samples, expected at least 3", node.samples)
+			}
+			return
+		}
+		checkLeaves(node.left)
+		checkLeaves(node.right)
+	}
+	checkLeaves(tree.root)
+}
+
+func TestDecisionTreePruning(t *testing.T) {
+	// Create sample data with one large step and small noise-like wiggles
+	var features [][]float64
+	var target []float64
+	for i := 0; i < 40; i++ {
+		features = append(features, []float64{float64(i)})
+		value := float64(i%2) * 0.1
+		if i >= 20 {
+			value += 10
+		}
+		target = append(target, value)
+	}
+
+	tree := newDecisionTree()
+	if err := tree.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	alphas, impurities := tree.costComplexityPath()
+	if len(alphas) != len(impurities) || len(alphas) < 2 {
+		t.Fatalf("Unexpected pruning path lengths: %d alphas, %d impurities", len(alphas), len(impurities))
+	}
+	for k := 1; k < len(alphas); k++ {
+		if alphas[k] < alphas[k-1] || impurities[k] < impurities[k-1] {
+			t.Errorf("Pruning path is not monotone at step %d", k)
+		}
+	}
+
+	// A moderate alpha keeps the big step but prunes the wiggles
+	tree.ccpAlpha = 0.1
+	if err := tree.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if leaves, _ := subtreeStats(tree.root); leaves != 2 {
+		t.Errorf("Unexpected number of leaves after pruning. Expected %d, got %d", 2, leaves)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"math"
+	"sort"
+)
+
+// binaryLabels returns 1 for every value above threshold and 0 otherwise, e.g. to turn
+// prices into "above the median value" labels.
+func binaryLabels(values []float64, threshold float64) []float64 {
+	labels := make([]float64, len(values))
+	for i, v := range values {
+		if v > threshold {
+			labels[i] = 1
+		}
+	}
+	return labels
+}
+
+// accuracy is the share of rows whose probability, cut at threshold, gives the right label.
+func accuracy(probabilities []float64, labels []float64, threshold float64) float64 {
+	truePositives, falsePositives, trueNegatives, falseNegatives := confusionCounts(probabilities, labels, threshold)
+	return (truePositives + trueNegatives) / (truePositives + falsePositives + trueNegatives + falseNegatives)
+}
+
+// precisionRecall returns the precision (share of predicted positives that are positive)
+// and the recall (share of positives predicted positive) at threshold. Either is NaN when
+// its denominator is zero.
+func precisionRecall(probabilities []float64, labels []float64, threshold float64) (float64, float64) {
+	truePositives, falsePositives, _, falseNegatives := confusionCounts(probabilities, labels, threshold)
+	return truePositives / (truePositives + falsePositives), truePositives / (truePositives + falseNegatives)
+}
+
+func confusionCounts(probabilities []float64, labels []float64, threshold float64) (float64, float64, float64, float64) {
+	if len(probabilities) != len(labels) {
+		panic("Probabilities and labels length mismatch")
+	}
+	var truePositives, falsePositives, trueNegatives, falseNegatives float64
+	for i, p := range probabilities {
+		switch predicted := p >= threshold; {
+		case predicted && labels[i] == 1:
+			truePositives++
+		case predicted:
+			falsePositives++
+		case labels[i] == 1:
+			falseNegatives++
+		default:
+			trueNegatives++
+		}
+	}
+	return truePositives, falsePositives, trueNegatives, falseNegatives
+}
+
+// rocAUC is the area under the ROC curve: the probability that a random positive row is
+// scored above a random negative one, counting ties as one half. It is computed from the
+// ranks of the probabilities (the Mann-Whitney statistic) and is NaN when only one class
+// is present.
+func rocAUC(probabilities []float64, labels []float64) float64 {
+	if len(probabilities) != len(labels) {
+		panic("Probabilities and labels length mismatch")
+	}
+	order := make([]int, len(probabilities))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return probabilities[order[a]] < probabilities[order[b]] })
+
+	// Tied probabilities share the mean of their ranks
+	var positiveRanks, numPositives float64
+	for start := 0; start < len(order); {
+		end := start
+		for end < len(order) && probabilities[order[end]] == probabilities[order[start]] {
+			end++
+		}
+		rank := float64(start+end+1) / 2
+		for _, i := range order[start:end] {
+			if labels[i] == 1 {
+				positiveRanks += rank
+				numPositives++
+			}
+		}
+		start = end
+	}
+	numNegatives := float64(len(labels)) - numPositives
+	if numPositives == 0 || numNegatives == 0 {
+		return math.NaN()
+	}
+	return (positiveRanks - numPositives*(numPositives+1)/2) / (numPositives * numNegatives)
+}
+
+// logLoss is the mean negative log-likelihood of the labels, with probabilities clipped
+// to [1e-15, 1 - 1e-15] so a confident mistake costs a lot but not infinity.
+func logLoss(probabilities []float64, labels []float64) float64 {
+	if len(probabilities) != len(labels) {
+		panic("Probabilities and labels length mismatch")
+	}
+	const clip = 1e-15
+	var sum float64
+	for i, p := range probabilities {
+		p = math.Min(math.Max(p, clip), 1-clip)
+		if labels[i] == 1 {
+			sum -= math.Log(p)
+		} else {
+			sum -= math.Log(1 - p)
+		}
+	}
+	return sum / float64(len(probabilities))
+}
+
+// brierScore is the mean squared difference between the probabilities and the labels,
+// which rewards calibration as well as separation of the classes.
+func brierScore(probabilities []float64, labels []float64) float64 {
+	if len(probabilities) != len(labels) {
+		panic("Probabilities and labels length mismatch")
+	}
+	return meanSquaredError(probabilities, labels)
+}
+
+// calibrationCurve splits [0, 1] into numBins equal bins and returns, for every bin that
+// holds a probability, the mean predicted probability, the observed share of positives
+// and the number of rows. A calibrated model has the first two close in every bin.
+func calibrationCurve(probabilities []float64, labels []float64, numBins int) ([]float64, []float64, []int) {
+	if len(probabilities) != len(labels) {
+		panic("Probabilities and labels length mismatch")
+	}
+	sumPredicted := make([]float64, numBins)
+	sumObserved := make([]float64, numBins)
+	counts := make([]int, numBins)
+	for i, p := range probabilities {
+		bin := int(p * float64(numBins))
+		if bin >= numBins {
+			bin = numBins - 1
+		}
+		if bin < 0 {
+			bin = 0
+		}
+		sumPredicted[bin] += p
+		sumObserved[bin] += labels[i]
+		counts[bin]++
+	}
+
+	var meanPredicted, observed []float64
+	var nonEmpty []int
+	for bin, count := range counts {
+		if count == 0 {
+			continue
+		}
+		meanPredicted = append(meanPredicted, sumPredicted[bin]/float64(count))
+		observed = append(observed, sumObserved[bin]/float64(count))
+		nonEmpty = append(nonEmpty, count)
+	}
+	return meanPredicted, observed, nonEmpty
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// kFoldSplits shuffles the rows 0..n-1 with seed and deals them into k folds of nearly
+// equal size.
+func kFoldSplits(n, k int, seed int64) [][]int {
+	folds := make([][]int, k)
+	for position, i := range rand.New(rand.NewSource(seed)).Perm(n) {
+		folds[position%k] = append(folds[position%k], i)
+	}
+	return folds
+}
+
+// crossValidatedPredictions fits a fresh model from newModel on all folds but one, for
+// every fold, and returns the out-of-fold prediction for every row. Folds are trained
+// through runTasks, one task per fold. With non-nil weights every fold's model is fit by
+// FitWeighted on the weights of its training rows.
+func crossValidatedPredictions(newModel func() regressor, features [][]float64, target []float64, weights []float64, numFolds int, seed int64) ([]float64, error) {
+	if len(features) != len(target) {
+		return nil, fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	if weights != nil {
+		if _, err := checkWeights(weights, len(target)); err != nil {
+			return nil, err
+		}
+	}
+	if numFolds < 2 || numFolds > len(features) {
+		return nil, fmt.Errorf("need between 2 and %d folds, got %d", len(features), numFolds)
+	}
+
+	folds := kFoldSplits(len(features), numFolds, seed)
+	predictions := make([]float64, len(features))
+	errs := make([]error, numFolds)
+	runTasks(numFolds, func(f int) {
+		held := make(map[int]bool, len(folds[f]))
+		for _, i := range folds[f] {
+			held[i] = true
+		}
+		var trainFeatures [][]float64
+		var trainTarget, trainWeights []float64
+		for i, row := range features {
+			if !held[i] {
+				trainFeatures = append(trainFeatures, row)
+				trainTarget = append(trainTarget, target[i])
+				if weights != nil {
+					trainWeights = append(trainWeights, weights[i])
+				}
+			}
+		}
+
+		model := newModel()
+		if errs[f] = fitWithWeights(model, trainFeatures, trainTarget, trainWeights); errs[f] != nil {
+			return
+		}
+		// Each fold writes only its own rows
+		for _, i := range folds[f] {
+			predictions[i] = model.Predict(features[i])
+		}
+	})
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return predictions, nil
+}
+
+// crossValidatedMSE is the mean squared error of the out-of-fold predictions, weighted
+// when weights is non-nil.
+func crossValidatedMSE(newModel func() regressor, features [][]float64, target []float64, weights []float64, numFolds int, seed int64) (float64, error) {
+	predictions, err := crossValidatedPredictions(newModel, features, target, weights, numFolds, seed)
+	if err != nil {
+		return 0, err
+	}
+	if weights == nil {
+		return meanSquaredError(predictions, target), nil
+	}
+	return weightedMeanSquaredError(predictions, target, weights), nil
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// dataFrame holds every column of a CSV file by name. Columns whose values all parse as
+// numbers are numeric; anything else (e.g. neighborhood) is kept as text.
+type dataFrame struct {
+	names   []string
+	numeric map[string][]float64
+	text    map[string][]string
+	numRows int
+}
+
+// loadDataFrame reads a CSV file with a header row into a dataFrame. Unlike loadCSV it
+// keeps every column, so callers decide which ones become features.
+func loadDataFrame(filename string) (*dataFrame, error) {
+	basePath, err := getBasePath()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filepath.Join(basePath, filename))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lines, err := parseCSV(file)
+	if err != nil {
+		return nil, err
+	}
+	if len(lines) < 2 {
+		return nil, fmt.Errorf("%s: need a header row and at least one data row", filename)
+	}
+
+	header := lines[0]
+	records := lines[1:]
+	df := &dataFrame{
+		names:   header,
+		numeric: make(map[string][]float64),
+		text:    make(map[string][]string),
+		numRows: len(records),
+	}
+
+	for j, name := range header {
+		values := make([]string, len(records))
+		for i, record := range records {
+			if len(record) != len(header) {
+				return nil, fmt.Errorf("%s: row %d has %d fields, want %d", filename, i+2, len(record), len(header))
+			}
+			values[i] = record[j]
+		}
+
+		if numbers, ok := parseFloats(values); ok {
+			df.numeric[name] = numbers
+		} else {
+			df.text[name] = values
+		}
+	}
+	return df, nil
+}
+
+// parseFloats converts every value to a float64 and reports whether all of them parsed.
+func parseFloats(values []string) ([]float64, bool) {
+	numbers := make([]float64, len(values))
+	for i, val := range values {
+		number, err := strconv.ParseFloat(val, 64)
+		if err != nil {
+			return nil, false
+		}
+		numbers[i] = number
+	}
+	return numbers, true
+}
+
+// column returns a numeric column by name.
+func (df *dataFrame) column(name string) ([]float64, error) {
+	values, ok := df.numeric[name]
+	if !ok {
+		if _, isText := df.text[name]; isText {
+			return nil, fmt.Errorf("column %q is not numeric", name)
+		}
+		return nil, fmt.Errorf("unknown column %q", name)
+	}
+	return values, nil
+}
+
+// levels returns a column as strings, formatting numeric columns, so that any column can
+// be treated as categorical.
+func (df *dataFrame) levels(name string) ([]string, error) {
+	if values, ok := df.text[name]; ok {
+		return values, nil
+	}
+	numbers, ok := df.numeric[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown column %q", name)
+	}
+	values := make([]string, len(numbers))
+	for i, number := range numbers {
+		values[i] = strconv.FormatFloat(number, 'g', -1, 64)
+	}
+	return values, nil
+}
+
+// slice returns the rows [start, end) as a new dataFrame that shares storage with df.
+func (df *dataFrame) slice(start, end int) *dataFrame {
+	sliced := &dataFrame{
+		names:   df.names,
+		numeric: make(map[string][]float64, len(df.numeric)),
+		text:    make(map[string][]string, len(df.text)),
+		numRows: end - start,
+	}
+	for name, values := range df.numeric {
+		sliced.numeric[name] = values[start:end]
+	}
+	for name, values := range df.text {
+		sliced.text[name] = values[start:end]
+	}
+	return sliced
+}
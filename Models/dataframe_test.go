@@ -0,0 +1,40 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestLoadDataFrame(t *testing.T) {
+	df, err := loadDataFrame("boston.csv")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Every column is kept, and neighborhood is the only text column
+	if len(df.names) != 14 {
+		t.Errorf("Unexpected number of columns. Expected %d, got %d", 14, len(df.names))
+	}
+	if _, ok := df.text["neighborhood"]; !ok {
+		t.Errorf("Expected neighborhood to be a text column")
+	}
+	if len(df.text) != 1 {
+		t.Errorf("Unexpected number of text columns. Expected %d, got %d", 1, len(df.text))
+	}
+
+	rooms, err := df.column("rooms")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(rooms) != df.numRows || rooms[0] != 6.575 {
+		t.Errorf("Unexpected rooms column. Expected %d rows starting with 6.575, got %d rows starting with %v", df.numRows, len(rooms), rooms[0])
+	}
+
+	if _, err := df.column("neighborhood"); err == nil {
+		t.Errorf("Expected an error reading a text column as numeric")
+	}
+
+	train := df.slice(0, 10)
+	if train.numRows != 10 || len(train.numeric["mv"]) != 10 {
+		t.Errorf("Unexpected slice size. Expected 10 rows, got %d", train.numRows)
+	}
+}
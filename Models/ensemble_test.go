@@ -0,0 +1,103 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// constantModel predicts a fixed value, for checking how ensembles combine predictions.
+type constantModel struct {
+	value float64
+}
+
+func (m *constantModel) Fit(features [][]float64, target []float64) error { return nil }
+
+func (m *constantModel) Predict(featureRow []float64) float64 { return m.value }
+
+func TestAveragingEnsemble(t *testing.T) {
+	features, target := noisyLinearData(50)
+	low := func() regressor { return &constantModel{value: 10} }
+	high := func() regressor { return &constantModel{value: 40} }
+
+	simple := newAveragingEnsemble(nil, low, high)
+	if err := simple.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := simple.Predict(features[0]); got != 25 {
+		t.Errorf("Unexpected simple average. Expected 25, got %f", got)
+	}
+
+	weighted := newAveragingEnsemble([]float64{3, 1}, low, high)
+	if err := weighted.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := weighted.Predict(features[0]); got != 17.5 {
+		t.Errorf("Unexpected weighted average. Expected 17.5, got %f", got)
+	}
+
+	if err := newAveragingEnsemble([]float64{1}, low, high).Fit(features, target); err == nil {
+		t.Errorf("Expected an error for a weights length mismatch")
+	}
+}
+
+func TestStackingEnsemble(t *testing.T) {
+	features, target := noisyLinearData(200)
+
+	// A useless constant base model next to OLS: the meta-regressor learns to rely on OLS
+	stack := newStackingEnsemble(func() regressor { return &linearModel{} },
+		func() regressor { return &constantModel{value: 100} },
+		func() regressor { return &linearModel{} },
+	)
+	if err := stack.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stack.baseCVMSE[1] > 0.3 || stack.baseCVMSE[0] < 1 {
+		t.Errorf("Unexpected base model cross-validated MSEs: %v", stack.baseCVMSE)
+	}
+	metaCoefficients := stack.meta.(*linearModel).coefficients
+	if math.Abs(metaCoefficients[2]-1) > 0.05 {
+		t.Errorf("Unexpected meta weight of OLS. Expected about 1, got %f", metaCoefficients[2])
+	}
+	rmse := rootMeanSquaredError(predictAll(stack, features), target)
+	if rmse > 0.55 {
+		t.Errorf("Unexpected stacked RMSE. Expected about 0.5, got %f", rmse)
+	}
+
+	// Ensembles are models themselves, so they nest and cross-validate like any other
+	mse, err := crossValidatedMSE(func() regressor {
+		return newAveragingEnsemble(nil, func() regressor { return &linearModel{} }, func() regressor { return &ridgeModel{lambda: 1} })
+	}, features, target, nil, 5, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if mse > 0.3 {
+		t.Errorf("Unexpected cross-validated MSE of the averaged ensemble. Expected about 0.25, got %f", mse)
+	}
+}
+
+func TestBlendingEnsemble(t *testing.T) {
+	features, target := noisyLinearData(200)
+
+	blend := newBlendingEnsemble(func() regressor { return &linearModel{} },
+		func() regressor { return &constantModel{value: 100} },
+		func() regressor { return &linearModel{} },
+	)
+	if err := blend.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if blend.holdoutMSE[1] > 0.35 || blend.holdoutMSE[0] < 1 {
+		t.Errorf("Unexpected base model holdout MSEs: %v", blend.holdoutMSE)
+	}
+	metaCoefficients := blend.meta.(*linearModel).coefficients
+	if math.Abs(metaCoefficients[2]-1) > 0.05 {
+		t.Errorf("Unexpected meta weight of OLS. Expected about 1, got %f", metaCoefficients[2])
+	}
+	if rmse := rootMeanSquaredError(predictAll(blend, features), target); rmse > 0.55 {
+		t.Errorf("Unexpected blended RMSE. Expected about 0.5, got %f", rmse)
+	}
+
+	blend.holdoutFraction = 1
+	if err := blend.Fit(features, target); err == nil {
+		t.Errorf("Expected an error for a holdout fraction that leaves no training rows")
+	}
+}
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// randomForest averages CART trees grown on bootstrap samples of the training rows, each
+// split considering a random subset of the features. Trees are trained through runTasks,
+// one task per tree.
+//
+// Every row is left out of about a third of the bootstrap samples, so averaging only the
+// trees that did not see a row gives an out-of-bag (OOB) prediction for it and an OOB MSE
+// that estimates test error without a separate holdout.
+type randomForest struct {
+	numTrees       int
+	maxDepth       int // 0 means no limit
+	minSamplesLeaf int
+	maxFeatures    int // features per split, 0 means a third of them (at least one)
+	seed           int64
+
+	trees []*decisionTree
+	// oobPredictions holds the OOB prediction for every training row, NaN for rows that
+	// were in every bootstrap sample
+	oobPredictions []float64
+	oobMSE         float64
+}
+
+// newRandomForest returns a randomForest with commonly used defaults.
+func newRandomForest() *randomForest {
+	return &randomForest{
+		numTrees:       100,
+		minSamplesLeaf: 1,
+		seed:           1,
+	}
+}
+
+func (f *randomForest) Fit(features [][]float64, target []float64) error {
+	return f.FitWeighted(features, target, nil)
+}
+
+// FitWeighted draws the bootstrap samples uniformly and passes each sampled row's weight
+// on to its tree; the OOB MSE is weighted too.
+func (f *randomForest) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	if len(features) == 0 || f.numTrees < 1 {
+		return fmt.Errorf("need at least one row and one tree")
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+
+	maxFeatures := f.maxFeatures
+	if maxFeatures <= 0 {
+		maxFeatures = len(features[0]) / 3
+		if maxFeatures < 1 {
+			maxFeatures = 1
+		}
+	}
+
+	// Each tree gets its own seed, so results do not depend on the order trees finish in
+	f.trees = make([]*decisionTree, f.numTrees)
+	inBag := make([][]bool, f.numTrees)
+	errs := make([]error, f.numTrees)
+	runTasks(f.numTrees, func(t int) {
+		rng := rand.New(rand.NewSource(f.seed + int64(t)))
+		sampleFeatures := make([][]float64, len(features))
+		sampleTarget := make([]float64, len(features))
+		sampleWeights := make([]float64, len(features))
+		// A bootstrap sample of only zero-weight rows cannot be fit, so draw it again; the
+		// weights sum to a positive value, so some draw has a weighted row
+		for inBag[t] == nil || floats.Sum(sampleWeights) == 0 {
+			inBag[t] = make([]bool, len(features))
+			for k := range sampleFeatures {
+				i := rng.Intn(len(features))
+				inBag[t][i] = true
+				sampleFeatures[k] = features[i]
+				sampleTarget[k] = target[i]
+				sampleWeights[k] = weights[i]
+			}
+		}
+
+		tree := &decisionTree{
+			maxDepth:       f.maxDepth,
+			minSamplesLeaf: f.minSamplesLeaf,
+			maxFeatures:    maxFeatures,
+			seed:           rng.Int63(),
+		}
+		errs[t] = tree.FitWeighted(sampleFeatures, sampleTarget, sampleWeights)
+		f.trees[t] = tree
+	})
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	// Average the trees that did not see each row
+	f.oobPredictions = make([]float64, len(features))
+	var sumSquaredError, scoredWeight float64
+	for i, row := range features {
+		var sum float64
+		count := 0
+		for t, tree := range f.trees {
+			if !inBag[t][i] {
+				sum += tree.Predict(row)
+				count++
+			}
+		}
+		if count == 0 {
+			f.oobPredictions[i] = math.NaN()
+			continue
+		}
+		f.oobPredictions[i] = sum / float64(count)
+		diff := f.oobPredictions[i] - target[i]
+		sumSquaredError += weights[i] * diff * diff
+		scoredWeight += weights[i]
+	}
+	f.oobMSE = math.NaN()
+	if scoredWeight > 0 {
+		f.oobMSE = sumSquaredError / scoredWeight
+	}
+	return nil
+}
+
+func (f *randomForest) Predict(featureRow []float64) float64 {
+	var sum float64
+	for _, tree := range f.trees {
+		sum += tree.Predict(featureRow)
+	}
+	return sum / float64(len(f.trees))
+}
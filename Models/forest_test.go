@@ -0,0 +1,80 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestRandomForestOutOfBagError(t *testing.T) {
+	// Create sample data with a nonlinear target and an irrelevant feature
+	var features [][]float64
+	var target []float64
+	for i := 0; i < 150; i++ {
+		x1 := float64(i) / 15
+		x2 := float64((i * 7) % 11)
+		features = append(features, []float64{x1, x2, x1 * 0.5})
+		target = append(target, 10*math.Sin(x1))
+	}
+
+	forest := newRandomForest()
+	forest.numTrees = 50
+	if err := forest.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(forest.trees) != 50 {
+		t.Errorf("Unexpected number of trees. Expected %d, got %d", 50, len(forest.trees))
+	}
+	if len(forest.oobPredictions) != len(features) {
+		t.Errorf("Unexpected number of OOB predictions. Expected %d, got %d", len(features), len(forest.oobPredictions))
+	}
+	if math.IsNaN(forest.oobMSE) || forest.oobMSE > 2 {
+		t.Errorf("Unexpected OOB MSE. Expected at most 2, got %f", forest.oobMSE)
+	}
+	if prediction := forest.Predict([]float64{1.5, 3, 0.75}); math.Abs(prediction-10*math.Sin(1.5)) > 1.5 {
+		t.Errorf("Unexpected prediction. Expected about %f, got %f", 10*math.Sin(1.5), prediction)
+	}
+}
+
+func TestRandomForestIsDeterministic(t *testing.T) {
+	// Create sample data for two fits with the same seed
+	features := [][]float64{{1, 5}, {2, 4}, {3, 3}, {4, 2}, {5, 1}, {6, 0}}
+	target := []float64{1, 4, 9, 16, 25, 36}
+
+	first, second := newRandomForest(), newRandomForest()
+	first.numTrees, second.numTrees = 20, 20
+	if err := first.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := second.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if a, b := first.Predict([]float64{3.5, 2.5}), second.Predict([]float64{3.5, 2.5}); a != b {
+		t.Errorf("Unexpected difference between fits with the same seed: %f vs %f", a, b)
+	}
+}
+
+func TestRandomForestIgnoresZeroWeightBootstraps(t *testing.T) {
+	// Only the first two rows have weight, so some bootstrap samples hold none of them
+	var features [][]float64
+	var target, weights []float64
+	for i := 0; i < 20; i++ {
+		features = append(features, []float64{float64(i)})
+		target = append(target, 100*float64(i))
+		weights = append(weights, 0)
+	}
+	weights[0], weights[1] = 1, 1
+
+	forest := newRandomForest()
+	forest.numTrees = 50
+	if err := forest.FitWeighted(features, target, weights); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, tree := range forest.trees {
+		for _, row := range features {
+			if prediction := tree.Predict(row); prediction < 0 || prediction > 100 {
+				t.Fatalf("Unexpected tree prediction from zero-weight rows. Expected within [0, 100], got %f", prediction)
+			}
+		}
+	}
+}
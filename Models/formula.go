@@ -0,0 +1,454 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// formulaTransforms are the functions that may wrap a numeric column in a formula.
+var formulaTransforms = map[string]func(float64) float64{
+	"log":   math.Log,
+	"log1p": math.Log1p,
+	"sqrt":  math.Sqrt,
+	"exp":   math.Exp,
+	"abs":   math.Abs,
+}
+
+// formulaFactor is a single column in a formula, optionally transformed (log, sqrt, ...)
+// or marked as categorical with C(...).
+type formulaFactor struct {
+	transform string // "" for the raw column, "C" for categorical, else a formulaTransforms key
+	column    string
+}
+
+// formulaTerm is a product of factors; a single factor is a main effect and several
+// factors joined by ':' are an interaction.
+type formulaTerm []formulaFactor
+
+// formula is a parsed R/Patsy-style model formula such as
+// "mv ~ rooms + log(lstat) + C(neighborhood) + rooms:lstat - 1".
+type formula struct {
+	text      string
+	response  formulaFactor
+	intercept bool
+	terms     []formulaTerm
+
+	// levels holds the sorted categories of every categorical column, learned the first
+	// time a design matrix is built so that later frames are coded the same way.
+	levels map[string][]string
+}
+
+// modelMetadata records how a model was specified so its results can be traced back.
+type modelMetadata struct {
+	formula string
+	columns []string // names of the design matrix columns, in coefficient order
+}
+
+// formulaModel is a least squares model (ridge when lambda > 0) whose design matrix is
+// built from a formula instead of from fixed loadCSV columns.
+type formulaModel struct {
+	formula      *formula
+	lambda       float64
+	coefficients []float64
+	metadata     modelMetadata
+}
+
+func (f formulaFactor) String() string {
+	if f.transform == "" {
+		return f.column
+	}
+	return f.transform + "(" + f.column + ")"
+}
+
+func (t formulaTerm) String() string {
+	names := make([]string, len(t))
+	for i, factor := range t {
+		names[i] = factor.String()
+	}
+	return strings.Join(names, ":")
+}
+
+// parseFormula parses "response ~ rhs". The right-hand side supports '+' to add terms,
+// '-' to remove them, ':' for interactions, '*' for main effects plus interactions,
+// "0"/"-1" to drop the intercept, C(col) for categoricals and the formulaTransforms.
+func parseFormula(text string) (*formula, error) {
+	tokens, err := tokenizeFormula(text)
+	if err != nil {
+		return nil, err
+	}
+	p := &formulaParser{tokens: tokens}
+
+	response, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	if response.transform == "C" {
+		return nil, fmt.Errorf("response %s cannot be categorical", response)
+	}
+	if err := p.expect("~"); err != nil {
+		return nil, err
+	}
+
+	f := &formula{text: text, response: response, intercept: true}
+	seen := make(map[string]bool)
+	sign := "+"
+	if p.peek() == "-" {
+		sign = p.next()
+	}
+	for {
+		if p.peek() == "1" || p.peek() == "0" {
+			// "+1" keeps the intercept, "+0" and "-1" drop it, "-0" keeps it
+			f.intercept = (p.next() == "1") == (sign == "+")
+		} else {
+			terms, err := p.product()
+			if err != nil {
+				return nil, err
+			}
+			for _, term := range terms {
+				key := term.String()
+				if sign == "+" && !seen[key] {
+					seen[key] = true
+					f.terms = append(f.terms, term)
+				} else if sign == "-" && seen[key] {
+					delete(seen, key)
+					f.terms = removeTerm(f.terms, key)
+				}
+			}
+		}
+
+		if p.peek() == "" {
+			break
+		}
+		sign = p.next()
+		if sign != "+" && sign != "-" {
+			return nil, fmt.Errorf("formula %q: unexpected %q", text, sign)
+		}
+	}
+	return f, nil
+}
+
+func removeTerm(terms []formulaTerm, key string) []formulaTerm {
+	kept := terms[:0]
+	for _, term := range terms {
+		if term.String() != key {
+			kept = append(kept, term)
+		}
+	}
+	return kept
+}
+
+// tokenizeFormula splits a formula into identifiers, numbers and the operators ~+-:*().
+func tokenizeFormula(text string) ([]string, error) {
+	var tokens []string
+	runes := []rune(text)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case strings.ContainsRune("~+-:*()", r):
+			tokens = append(tokens, string(r))
+			i++
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '.':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_' || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, string(runes[start:i]))
+		default:
+			return nil, fmt.Errorf("formula %q: unexpected character %q", text, r)
+		}
+	}
+	return tokens, nil
+}
+
+type formulaParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *formulaParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *formulaParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *formulaParser) expect(token string) error {
+	if got := p.next(); got != token {
+		return fmt.Errorf("expected %q, got %q", token, got)
+	}
+	return nil
+}
+
+// product parses a*b*... and expands it into every non-empty combination of its operands.
+func (p *formulaParser) product() ([]formulaTerm, error) {
+	first, err := p.interaction()
+	if err != nil {
+		return nil, err
+	}
+	terms := []formulaTerm{first}
+	for p.peek() == "*" {
+		p.next()
+		operand, err := p.interaction()
+		if err != nil {
+			return nil, err
+		}
+		expanded := append([]formulaTerm(nil), terms...)
+		expanded = append(expanded, operand)
+		for _, term := range terms {
+			expanded = append(expanded, append(append(formulaTerm(nil), term...), operand...))
+		}
+		terms = expanded
+	}
+	return terms, nil
+}
+
+// interaction parses a:b:...
+func (p *formulaParser) interaction() (formulaTerm, error) {
+	factor, err := p.factor()
+	if err != nil {
+		return nil, err
+	}
+	term := formulaTerm{factor}
+	for p.peek() == ":" {
+		p.next()
+		factor, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		term = append(term, factor)
+	}
+	return term, nil
+}
+
+// factor parses a column name or fn(column).
+func (p *formulaParser) factor() (formulaFactor, error) {
+	name := p.next()
+	if name == "" || strings.ContainsAny(name, "~+-:*()") {
+		return formulaFactor{}, fmt.Errorf("expected a column name, got %q", name)
+	}
+	if p.peek() != "(" {
+		return formulaFactor{column: name}, nil
+	}
+
+	p.next()
+	if _, ok := formulaTransforms[name]; !ok && name != "C" {
+		return formulaFactor{}, fmt.Errorf("unknown function %q", name)
+	}
+	column := p.next()
+	if column == "" || strings.ContainsAny(column, "~+-:*()") {
+		return formulaFactor{}, fmt.Errorf("expected a column name in %s(), got %q", name, column)
+	}
+	if err := p.expect(")"); err != nil {
+		return formulaFactor{}, err
+	}
+	return formulaFactor{transform: name, column: column}, nil
+}
+
+// formulaColumn is one column of the design matrix produced by a factor.
+type formulaColumn struct {
+	name   string
+	values []float64
+}
+
+// factorColumns evaluates a factor on df. Numeric factors give one column; categorical
+// factors give one dummy column per level, minus the reference level unless full is set.
+func (f *formula) factorColumns(df *dataFrame, factor formulaFactor, full bool) ([]formulaColumn, error) {
+	if factor.transform != "C" {
+		raw, err := df.column(factor.column)
+		if err != nil {
+			return nil, err
+		}
+		values := append([]float64(nil), raw...)
+		if fn, ok := formulaTransforms[factor.transform]; ok {
+			for i, v := range values {
+				values[i] = fn(v)
+				if math.IsNaN(values[i]) || math.IsInf(values[i], 0) {
+					return nil, fmt.Errorf("%s is not finite at row %d (value %v)", factor, i, v)
+				}
+			}
+		}
+		return []formulaColumn{{name: factor.String(), values: values}}, nil
+	}
+
+	raw, err := df.levels(factor.column)
+	if err != nil {
+		return nil, err
+	}
+	levels, ok := f.levels[factor.column]
+	if !ok {
+		distinct := make(map[string]bool)
+		for _, level := range raw {
+			if !distinct[level] {
+				distinct[level] = true
+				levels = append(levels, level)
+			}
+		}
+		sort.Strings(levels)
+		if f.levels == nil {
+			f.levels = make(map[string][]string)
+		}
+		f.levels[factor.column] = levels
+	}
+
+	coded := levels
+	if !full {
+		coded = levels[1:] // the first level is the reference category
+	}
+	columns := make([]formulaColumn, len(coded))
+	index := make(map[string]int, len(coded))
+	for k, level := range coded {
+		columns[k] = formulaColumn{name: fmt.Sprintf("%s[%s]", factor, level), values: make([]float64, len(raw))}
+		index[level] = k
+	}
+	known := make(map[string]bool, len(levels))
+	for _, level := range levels {
+		known[level] = true
+	}
+	for i, level := range raw {
+		if !known[level] {
+			return nil, fmt.Errorf("%s has unknown level %q at row %d", factor, level, i)
+		}
+		if k, ok := index[level]; ok {
+			columns[k].values[i] = 1
+		}
+	}
+	return columns, nil
+}
+
+// design builds the design matrix for df, including the intercept column when the formula
+// has one, and returns it with its column names.
+func (f *formula) design(df *dataFrame) ([][]float64, []string, error) {
+	var columns []formulaColumn
+	if f.intercept {
+		columns = append(columns, formulaColumn{name: "Intercept", values: constantColumn(df.numRows, 1)})
+	}
+
+	// Without an intercept the first categorical main effect is coded with every level,
+	// so the model still spans a constant
+	fullCodingUsed := f.intercept
+	for _, term := range f.terms {
+		termColumns := []formulaColumn{{values: constantColumn(df.numRows, 1)}}
+		for _, factor := range term {
+			full := false
+			if len(term) == 1 && factor.transform == "C" && !fullCodingUsed {
+				full = true
+				fullCodingUsed = true
+			}
+			factorColumns, err := f.factorColumns(df, factor, full)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			// Interactions are the element-wise products of every pair of columns
+			var combined []formulaColumn
+			for _, left := range termColumns {
+				for _, right := range factorColumns {
+					values := make([]float64, df.numRows)
+					floats.MulTo(values, left.values, right.values)
+					name := right.name
+					if left.name != "" {
+						name = left.name + ":" + right.name
+					}
+					combined = append(combined, formulaColumn{name: name, values: values})
+				}
+			}
+			termColumns = combined
+		}
+		columns = append(columns, termColumns...)
+	}
+	if len(columns) == 0 {
+		return nil, nil, fmt.Errorf("formula %q has no terms", f.text)
+	}
+
+	names := make([]string, len(columns))
+	design := make([][]float64, df.numRows)
+	for i := range design {
+		design[i] = make([]float64, len(columns))
+	}
+	for j, column := range columns {
+		names[j] = column.name
+		for i, v := range column.values {
+			design[i][j] = v
+		}
+	}
+	return design, names, nil
+}
+
+// target evaluates the response of the formula on df.
+func (f *formula) target(df *dataFrame) ([]float64, error) {
+	columns, err := f.factorColumns(df, f.response, false)
+	if err != nil {
+		return nil, err
+	}
+	return columns[0].values, nil
+}
+
+func constantColumn(n int, value float64) []float64 {
+	column := make([]float64, n)
+	for i := range column {
+		column[i] = value
+	}
+	return column
+}
+
+// fitFormula fits a least squares model described by formulaText on df. lambda > 0 adds a
+// ridge penalty to every coefficient except the intercept.
+func fitFormula(df *dataFrame, formulaText string, lambda float64) (*formulaModel, error) {
+	f, err := parseFormula(formulaText)
+	if err != nil {
+		return nil, err
+	}
+	design, names, err := f.design(df)
+	if err != nil {
+		return nil, err
+	}
+	target, err := f.target(df)
+	if err != nil {
+		return nil, err
+	}
+
+	penalty := mat.NewDense(len(names), len(names), nil)
+	for j, name := range names {
+		if name != "Intercept" {
+			penalty.Set(j, j, lambda)
+		}
+	}
+	coefficients, err := penalizedRegression(design, target, penalty)
+	if err != nil {
+		return nil, err
+	}
+
+	return &formulaModel{
+		formula:      f,
+		lambda:       lambda,
+		coefficients: coefficients,
+		metadata:     modelMetadata{formula: f.text, columns: names},
+	}, nil
+}
+
+// predict evaluates the model on every row of df, on the scale of the formula response.
+func (m *formulaModel) predict(df *dataFrame) ([]float64, error) {
+	design, _, err := m.formula.design(df)
+	if err != nil {
+		return nil, err
+	}
+	predictions := make([]float64, len(design))
+	for i, row := range design {
+		predictions[i] = floats.Dot(row, m.coefficients)
+	}
+	return predictions, nil
+}
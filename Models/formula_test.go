@@ -0,0 +1,110 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestParseFormula(t *testing.T) {
+	f, err := parseFormula("mv ~ rooms + log(lstat) + C(neighborhood) + rooms:lstat - 1")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if f.intercept {
+		t.Errorf("Expected the intercept to be removed")
+	}
+	expectedTerms := []string{"rooms", "log(lstat)", "C(neighborhood)", "rooms:lstat"}
+	if len(f.terms) != len(expectedTerms) {
+		t.Fatalf("Unexpected number of terms. Expected %d, got %d", len(expectedTerms), len(f.terms))
+	}
+	for i, term := range f.terms {
+		if term.String() != expectedTerms[i] {
+			t.Errorf("Unexpected term %d. Expected %s, got %s", i, expectedTerms[i], term)
+		}
+	}
+
+	// a*b expands to a + b + a:b, and terms can be removed again
+	f, err = parseFormula("mv ~ rooms*age - age")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(f.terms) != 2 || f.terms[0].String() != "rooms" || f.terms[1].String() != "rooms:age" {
+		t.Errorf("Unexpected expansion of rooms*age - age: %v", f.terms)
+	}
+
+	if _, err := parseFormula("mv ~ foo(rooms)"); err == nil {
+		t.Errorf("Expected an error for an unknown function")
+	}
+}
+
+func TestFormulaDesign(t *testing.T) {
+	// Create a small data frame with a categorical column
+	df := &dataFrame{
+		names: []string{"town", "x", "y"},
+		text:  map[string][]string{"town": {"b", "a", "c", "a"}},
+		numeric: map[string][]float64{
+			"x": {1, 2, 3, 4},
+			"y": {2, 3, 4, 5},
+		},
+		numRows: 4,
+	}
+
+	f, err := parseFormula("y ~ x + C(town)")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	design, names, err := f.design(df)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expectedNames := []string{"Intercept", "x", "C(town)[b]", "C(town)[c]"}
+	if len(names) != len(expectedNames) {
+		t.Fatalf("Unexpected design columns. Expected %v, got %v", expectedNames, names)
+	}
+	for i := range names {
+		if names[i] != expectedNames[i] {
+			t.Errorf("Unexpected design column %d. Expected %s, got %s", i, expectedNames[i], names[i])
+		}
+	}
+	if design[0][2] != 1 || design[1][2] != 0 || design[2][3] != 1 {
+		t.Errorf("Unexpected dummy coding: %v", design)
+	}
+
+	// Without an intercept every level of the first categorical gets its own column
+	f, _ = parseFormula("y ~ C(town) - 1")
+	_, names, err = f.design(df)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(names) != 3 {
+		t.Errorf("Unexpected number of columns without intercept. Expected %d, got %d", 3, len(names))
+	}
+}
+
+func TestFitFormula(t *testing.T) {
+	df, err := loadDataFrame("boston.csv")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	model, err := fitFormula(df, "log(mv) ~ rooms + log(lstat) + rooms:lstat + C(chas)", 0)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if model.metadata.formula != "log(mv) ~ rooms + log(lstat) + rooms:lstat + C(chas)" {
+		t.Errorf("Unexpected formula in metadata: %s", model.metadata.formula)
+	}
+	if len(model.coefficients) != len(model.metadata.columns) || len(model.coefficients) != 5 {
+		t.Errorf("Unexpected coefficient count. Expected 5, got %d", len(model.coefficients))
+	}
+
+	predictions, err := model.predict(df)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	target, _ := model.formula.target(df)
+	if rmse := rootMeanSquaredError(predictions, target); math.IsNaN(rmse) || rmse > 0.5 {
+		t.Errorf("Unexpected training RMSE on log scale: %f", rmse)
+	}
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// gamStabilizer is a tiny ridge added to every smooth block. Centered B-spline columns sum
+// to zero, so without it the penalized system would be singular.
+const gamStabilizer = 1e-6
+
+// gamTerm is a smooth term of a generalized additive model: a spline expansion of one
+// named feature column with its own smoothing parameter.
+type gamTerm struct {
+	column string  // feature column name, e.g. "lstat"
+	spline string  // "ns" for a natural cubic spline, "bs" for a cubic B-spline
+	knots  int     // number of knots (natural spline) or interior knots (B-spline)
+	lambda float64 // smoothing parameter, larger values give smoother curves
+
+	// Set by Fit
+	index        int
+	basis        splineBasis
+	means        []float64 // training means of the basis columns, used to center the term
+	coefficients []float64
+	min, max     float64 // training range of the column, used for partial effect curves
+}
+
+// gam is a penalized generalized additive model with Gaussian errors: an intercept, a
+// linear coefficient for every feature without a smooth term, and a spline for every
+// feature with one. It is fit with the same penalized least squares solver as ridge.
+type gam struct {
+	columnNames        []string
+	terms              []gamTerm
+	intercept          float64
+	linearColumns      []int
+	linearCoefficients []float64
+}
+
+// newGAM creates a GAM over the given feature columns with the given smooth terms.
+func newGAM(columnNames []string, terms ...gamTerm) *gam {
+	return &gam{columnNames: columnNames, terms: terms}
+}
+
+func (g *gam) Fit(features [][]float64, target []float64) error {
+	return g.FitWeighted(features, target, nil)
+}
+
+// FitWeighted fits the GAM by penalized weighted least squares.
+func (g *gam) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	sumWeights := floats.Sum(weights)
+
+	smoothColumns := make(map[int]bool)
+	for t := range g.terms {
+		term := &g.terms[t]
+		term.index = indexOf(g.columnNames, term.column)
+		if term.index < 0 {
+			return fmt.Errorf("unknown column %q", term.column)
+		}
+		if smoothColumns[term.index] {
+			return fmt.Errorf("column %q has more than one smooth term", term.column)
+		}
+		smoothColumns[term.index] = true
+
+		column := make([]float64, len(features))
+		for i, row := range features {
+			column[i] = row[term.index]
+		}
+		// A constant column, e.g. chas within a small fold, leaves nowhere to place knots
+		term.min, term.max = floats.Min(column), floats.Max(column)
+		if term.min == term.max {
+			return fmt.Errorf("column %q is constant, so it cannot have a smooth term", term.column)
+		}
+		switch term.spline {
+		case "ns":
+			if term.knots < 3 {
+				return fmt.Errorf("natural spline for column %q needs at least 3 knots, got %d", term.column, term.knots)
+			}
+			term.basis = newNaturalCubicSpline(column, term.knots)
+		case "bs":
+			if term.knots < 0 {
+				return fmt.Errorf("B-spline for column %q needs a non-negative number of interior knots, got %d", term.column, term.knots)
+			}
+			term.basis = newBSpline(column, term.knots, 3)
+		default:
+			return fmt.Errorf("unknown spline type %q for column %q", term.spline, term.column)
+		}
+
+		// Center each basis column so the intercept carries the overall level
+		term.means = make([]float64, term.basis.size())
+		for i, x := range column {
+			floats.AddScaled(term.means, weights[i], term.basis.expand(x))
+		}
+		floats.Scale(1/sumWeights, term.means)
+	}
+
+	g.linearColumns = g.linearColumns[:0]
+	for j := range g.columnNames {
+		if !smoothColumns[j] {
+			g.linearColumns = append(g.linearColumns, j)
+		}
+	}
+
+	// Scaling rows by sqrt(w) turns weighted least squares into ordinary least squares
+	design := make([][]float64, len(features))
+	scaledTarget := make([]float64, len(target))
+	for i, row := range features {
+		design[i] = g.designRow(row)
+		floats.Scale(math.Sqrt(weights[i]), design[i])
+		scaledTarget[i] = math.Sqrt(weights[i]) * target[i]
+	}
+
+	// Intercept and linear terms are unpenalized; each smooth block gets λ * P
+	numColumns := len(design[0])
+	penalty := mat.NewDense(numColumns, numColumns, nil)
+	offset := 1 + len(g.linearColumns)
+	for _, term := range g.terms {
+		block := term.basis.penalty()
+		size := term.basis.size()
+		for r := 0; r < size; r++ {
+			for c := 0; c < size; c++ {
+				penalty.Set(offset+r, offset+c, term.lambda*block.At(r, c))
+			}
+			penalty.Set(offset+r, offset+r, penalty.At(offset+r, offset+r)+gamStabilizer)
+		}
+		offset += size
+	}
+
+	coefficients, err := penalizedRegression(design, scaledTarget, penalty)
+	if err != nil {
+		return err
+	}
+
+	g.intercept = coefficients[0]
+	g.linearCoefficients = coefficients[1 : 1+len(g.linearColumns)]
+	offset = 1 + len(g.linearColumns)
+	for t := range g.terms {
+		size := g.terms[t].basis.size()
+		g.terms[t].coefficients = coefficients[offset : offset+size]
+		offset += size
+	}
+	return nil
+}
+
+// designRow lays out a feature row as [1, linear features..., centered spline bases...].
+func (g *gam) designRow(featureRow []float64) []float64 {
+	row := []float64{1}
+	for _, j := range g.linearColumns {
+		row = append(row, featureRow[j])
+	}
+	for _, term := range g.terms {
+		expanded := term.basis.expand(featureRow[term.index])
+		floats.Sub(expanded, term.means)
+		row = append(row, expanded...)
+	}
+	return row
+}
+
+func (g *gam) Predict(featureRow []float64) float64 {
+	if len(featureRow) != len(g.columnNames) {
+		panic("Feature row and column names length mismatch")
+	}
+
+	prediction := g.intercept
+	for k, j := range g.linearColumns {
+		prediction += g.linearCoefficients[k] * featureRow[j]
+	}
+	for _, term := range g.terms {
+		prediction += term.effect(featureRow[term.index])
+	}
+	return prediction
+}
+
+// effect is the centered contribution of the term to a prediction at value x.
+func (term gamTerm) effect(x float64) float64 {
+	expanded := term.basis.expand(x)
+	floats.Sub(expanded, term.means)
+	return floats.Dot(expanded, term.coefficients)
+}
+
+// partialEffect evaluates the smooth term for column on numPoints evenly spaced values
+// across its training range and returns the values and the centered effects.
+func (g *gam) partialEffect(column string, numPoints int) ([]float64, []float64, error) {
+	for _, term := range g.terms {
+		if term.column != column {
+			continue
+		}
+		if term.coefficients == nil {
+			return nil, nil, fmt.Errorf("GAM has not been fit")
+		}
+		values := make([]float64, numPoints)
+		floats.Span(values, term.min, term.max)
+		effects := make([]float64, numPoints)
+		for i, x := range values {
+			effects[i] = term.effect(x)
+		}
+		return values, effects, nil
+	}
+	return nil, nil, fmt.Errorf("no smooth term for column %q", column)
+}
+
+// exportPartialEffects writes the partial effect curve of every smooth term to a CSV file
+// with the columns term, value and effect.
+func (g *gam) exportPartialEffects(filename string, numPoints int) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{"term", "value", "effect"}); err != nil {
+		return err
+	}
+	for _, term := range g.terms {
+		values, effects, err := g.partialEffect(term.column, numPoints)
+		if err != nil {
+			return err
+		}
+		for i := range values {
+			record := []string{
+				term.column,
+				strconv.FormatFloat(values[i], 'g', -1, 64),
+				strconv.FormatFloat(effects[i], 'g', -1, 64),
+			}
+			if err := writer.Write(record); err != nil {
+				return err
+			}
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// indexOf returns the position of name in names, or -1 if it is missing.
+func indexOf(names []string, name string) int {
+	for i, n := range names {
+		if n == name {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestGradientBoostingFitsStepFunction(t *testing.T) {
+	// Create sample data with a step in the first feature that a linear model cannot fit
+	var features [][]float64
+	var target []float64
+	for i := 0; i < 200; i++ {
+		x1 := float64(i) / 10
+		x2 := float64(i % 13)
+		features = append(features, []float64{x1, x2})
+		if x1 < 10 {
+			target = append(target, 10)
+		} else {
+			target = append(target, 30)
+		}
+	}
+
+	model := newGradientBoosting()
+	if err := model.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if prediction := model.Predict([]float64{2, 5}); math.Abs(prediction-10) > 1 {
+		t.Errorf("Unexpected prediction below the step. Expected about 10, got %f", prediction)
+	}
+	if prediction := model.Predict([]float64{15, 5}); math.Abs(prediction-30) > 1 {
+		t.Errorf("Unexpected prediction above the step. Expected about 30, got %f", prediction)
+	}
+}
+
+func TestGradientBoostingEarlyStopping(t *testing.T) {
+	// Create sample data that a single split fits exactly, so validation loss stops improving
+	var features [][]float64
+	var target []float64
+	for i := 0; i < 100; i++ {
+		features = append(features, []float64{float64(i)})
+		target = append(target, float64(i/50))
+	}
+
+	model := newGradientBoosting()
+	model.numTrees = 1000
+	model.learningRate = 1
+	if err := model.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(model.trees) >= model.numTrees {
+		t.Errorf("Expected early stopping to keep fewer than %d trees, got %d", model.numTrees, len(model.trees))
+	}
+	if len(model.validationLoss) == 0 {
+		t.Errorf("Expected validation losses to be recorded")
+	}
+}
@@ -0,0 +1,446 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gonum.org/v1/gonum/mat"
+)
+
+// glmFamily is the distribution of the target in a generalized linear model, described by
+// its variance function and unit deviance.
+type glmFamily interface {
+	variance(mu float64) float64
+	// unitDeviance is the contribution of one row to the deviance
+	unitDeviance(y, mu float64) float64
+	// logLikelihood is the log density of y, or NaN when it has no closed form
+	logLikelihood(y, mu, dispersion float64) float64
+	checkTarget(y float64) error
+	validMean(mu float64) bool
+	// fixedDispersion is true when the dispersion is known to be 1, as for Poisson
+	fixedDispersion() bool
+}
+
+// glmLink maps the mean of the target to the linear predictor η = b·[1, x].
+type glmLink interface {
+	link(mu float64) float64
+	inverse(eta float64) float64
+	// derivative is dη/dμ
+	derivative(mu float64) float64
+}
+
+// gaussianFamily has constant variance; with identityLink the GLM is OLS.
+type gaussianFamily struct{}
+
+// poissonFamily has variance μ and suits non-negative counts.
+type poissonFamily struct{}
+
+// gammaFamily has variance μ², i.e. a constant coefficient of variation, which often suits
+// positive, right-skewed prices.
+type gammaFamily struct{}
+
+// inverseGaussianFamily has variance μ³ and an even heavier right tail than Gamma.
+type inverseGaussianFamily struct{}
+
+// tweedieFamily has variance μ^power. Powers 0, 1, 2 and 3 are the Gaussian, Poisson,
+// Gamma and inverse Gaussian families; powers in (1, 2) give compound Poisson-Gamma
+// distributions with a mass at zero. Powers in (0, 1) do not exist.
+type tweedieFamily struct {
+	power float64
+}
+
+type identityLink struct{}
+
+type logLink struct{}
+
+type inverseLink struct{}
+
+func (gaussianFamily) variance(mu float64) float64 { return 1 }
+
+func (gaussianFamily) unitDeviance(y, mu float64) float64 { return (y - mu) * (y - mu) }
+
+func (gaussianFamily) logLikelihood(y, mu, dispersion float64) float64 {
+	return -0.5 * (math.Log(2*math.Pi*dispersion) + (y-mu)*(y-mu)/dispersion)
+}
+
+func (gaussianFamily) checkTarget(y float64) error { return nil }
+
+func (gaussianFamily) validMean(mu float64) bool { return true }
+
+func (gaussianFamily) fixedDispersion() bool { return false }
+
+func (poissonFamily) variance(mu float64) float64 { return mu }
+
+func (poissonFamily) unitDeviance(y, mu float64) float64 {
+	if y == 0 {
+		return 2 * mu
+	}
+	return 2 * (y*math.Log(y/mu) - (y - mu))
+}
+
+func (poissonFamily) logLikelihood(y, mu, dispersion float64) float64 {
+	logGamma, _ := math.Lgamma(y + 1)
+	return y*math.Log(mu) - mu - logGamma
+}
+
+func (poissonFamily) checkTarget(y float64) error {
+	if y < 0 {
+		return fmt.Errorf("Poisson family needs a non-negative target, got %v", y)
+	}
+	return nil
+}
+
+func (poissonFamily) validMean(mu float64) bool { return mu > 0 }
+
+func (poissonFamily) fixedDispersion() bool { return true }
+
+func (gammaFamily) variance(mu float64) float64 { return mu * mu }
+
+func (gammaFamily) unitDeviance(y, mu float64) float64 {
+	return 2 * (-math.Log(y/mu) + (y-mu)/mu)
+}
+
+func (gammaFamily) logLikelihood(y, mu, dispersion float64) float64 {
+	shape := 1 / dispersion
+	logGamma, _ := math.Lgamma(shape)
+	return shape*math.Log(shape*y/mu) - shape*y/mu - math.Log(y) - logGamma
+}
+
+func (gammaFamily) checkTarget(y float64) error {
+	if y <= 0 {
+		return fmt.Errorf("Gamma family needs a positive target, got %v", y)
+	}
+	return nil
+}
+
+func (gammaFamily) validMean(mu float64) bool { return mu > 0 }
+
+func (gammaFamily) fixedDispersion() bool { return false }
+
+func (inverseGaussianFamily) variance(mu float64) float64 { return mu * mu * mu }
+
+func (inverseGaussianFamily) unitDeviance(y, mu float64) float64 {
+	return (y - mu) * (y - mu) / (y * mu * mu)
+}
+
+func (inverseGaussianFamily) logLikelihood(y, mu, dispersion float64) float64 {
+	return -0.5 * (math.Log(2*math.Pi*dispersion*y*y*y) + (y-mu)*(y-mu)/(dispersion*y*mu*mu))
+}
+
+func (inverseGaussianFamily) checkTarget(y float64) error {
+	if y <= 0 {
+		return fmt.Errorf("inverse Gaussian family needs a positive target, got %v", y)
+	}
+	return nil
+}
+
+func (inverseGaussianFamily) validMean(mu float64) bool { return mu > 0 }
+
+func (inverseGaussianFamily) fixedDispersion() bool { return false }
+
+// special returns the named family equal to this Tweedie family, if there is one.
+func (t tweedieFamily) special() glmFamily {
+	switch t.power {
+	case 0:
+		return gaussianFamily{}
+	case 1:
+		return poissonFamily{}
+	case 2:
+		return gammaFamily{}
+	case 3:
+		return inverseGaussianFamily{}
+	}
+	return nil
+}
+
+func (t tweedieFamily) variance(mu float64) float64 { return math.Pow(mu, t.power) }
+
+func (t tweedieFamily) unitDeviance(y, mu float64) float64 {
+	if family := t.special(); family != nil {
+		return family.unitDeviance(y, mu)
+	}
+	p := t.power
+	return 2 * (math.Pow(y, 2-p)/((1-p)*(2-p)) - y*math.Pow(mu, 1-p)/(1-p) + math.Pow(mu, 2-p)/(2-p))
+}
+
+// logLikelihood of the general Tweedie distribution is an infinite series, so AIC is only
+// reported for the special powers.
+func (t tweedieFamily) logLikelihood(y, mu, dispersion float64) float64 {
+	if family := t.special(); family != nil {
+		return family.logLikelihood(y, mu, dispersion)
+	}
+	return math.NaN()
+}
+
+func (t tweedieFamily) checkTarget(y float64) error {
+	switch {
+	case t.power > 0 && t.power < 1:
+		return fmt.Errorf("Tweedie power must not be in (0, 1), got %v", t.power)
+	case t.power >= 1 && t.power < 2 && y < 0:
+		return fmt.Errorf("Tweedie power %v needs a non-negative target, got %v", t.power, y)
+	case t.power >= 2 && y <= 0:
+		return fmt.Errorf("Tweedie power %v needs a positive target, got %v", t.power, y)
+	case t.power < 0:
+		return fmt.Errorf("Tweedie power must be 0 or at least 1, got %v", t.power)
+	}
+	return nil
+}
+
+func (t tweedieFamily) validMean(mu float64) bool { return t.power == 0 || mu > 0 }
+
+func (t tweedieFamily) fixedDispersion() bool { return t.power == 1 }
+
+func (identityLink) link(mu float64) float64       { return mu }
+func (identityLink) inverse(eta float64) float64   { return eta }
+func (identityLink) derivative(mu float64) float64 { return 1 }
+
+func (logLink) link(mu float64) float64       { return math.Log(mu) }
+func (logLink) inverse(eta float64) float64   { return math.Exp(eta) }
+func (logLink) derivative(mu float64) float64 { return 1 / mu }
+
+func (inverseLink) link(mu float64) float64       { return 1 / mu }
+func (inverseLink) inverse(eta float64) float64   { return 1 / eta }
+func (inverseLink) derivative(mu float64) float64 { return -1 / (mu * mu) }
+
+// glm is a generalized linear model g(E[y]) = b·[1, x] fit by iteratively reweighted least
+// squares: every iteration solves weighted least squares for the working response
+// z = η + (y - μ) dη/dμ with weights 1 / (V(μ) (dη/dμ)²). Steps that leave the valid range
+// of the mean or increase the deviance are halved.
+//
+// With a log link the coefficients act multiplicatively: exp(b_j) is the factor by which
+// the expected price changes per unit of feature j.
+type glm struct {
+	family        glmFamily
+	link          glmLink
+	maxIterations int
+	tolerance     float64 // on the relative change in deviance
+
+	coefficients     []float64 // intercept first, on the link scale
+	standardErrors   []float64
+	dispersion       float64 // Pearson estimate, 1 for Poisson
+	deviance         float64
+	nullDeviance     float64 // deviance of the intercept-only model
+	aic              float64 // NaN when the family has no closed-form likelihood
+	degreesOfFreedom int     // residual degrees of freedom
+	iterations       int
+	converged        bool
+}
+
+// newGLM returns a glm for the given family and link.
+func newGLM(family glmFamily, link glmLink) *glm {
+	return &glm{family: family, link: link, maxIterations: 100, tolerance: 1e-8}
+}
+
+func (g *glm) Fit(features [][]float64, target []float64) error {
+	return g.FitWeighted(features, target, nil)
+}
+
+// FitWeighted treats the weights as frequencies: they multiply the IRLS weights, the
+// deviance and the log-likelihood.
+func (g *glm) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	var sumWeights, weightedSum float64
+	numObserved := 0
+	for i, y := range target {
+		if err := g.family.checkTarget(y); err != nil {
+			return fmt.Errorf("row %d: %v", i, err)
+		}
+		sumWeights += weights[i]
+		weightedSum += weights[i] * y
+		if weights[i] > 0 {
+			numObserved++
+		}
+	}
+	numCoefficients := len(features[0]) + 1
+	if numObserved <= numCoefficients {
+		return fmt.Errorf("need more than %d rows with positive weight, got %d", numCoefficients, numObserved)
+	}
+	meanTarget := weightedSum / sumWeights
+	if !g.family.validMean(meanTarget) {
+		return fmt.Errorf("mean target %v is outside the range of the family", meanTarget)
+	}
+
+	// Start halfway between each target and the mean, which is valid for every family
+	mu := make([]float64, len(target))
+	eta := make([]float64, len(target))
+	for i, y := range target {
+		mu[i] = (y + meanTarget) / 2
+		eta[i] = g.link.link(mu[i])
+	}
+	deviance := g.totalDeviance(target, mu, weights)
+
+	working := make([]float64, len(target))
+	irlsWeights := make([]float64, len(target))
+	var coefficients []float64
+	g.converged = false
+	for g.iterations = 1; g.iterations <= g.maxIterations; g.iterations++ {
+		for i, y := range target {
+			d := g.link.derivative(mu[i])
+			working[i] = eta[i] + (y-mu[i])*d
+			irlsWeights[i] = weights[i] / (g.family.variance(mu[i]) * d * d)
+		}
+		next, err := weightedLinearRegression(features, working, irlsWeights)
+		if err != nil {
+			return err
+		}
+
+		// Halve the step until the mean is valid and the deviance is finite and not worse
+		var nextDeviance float64
+		accepted := false
+		for halvings := 0; halvings < 30; halvings++ {
+			nextDeviance = g.updateMean(features, next, eta, mu, target, weights)
+			if !math.IsNaN(nextDeviance) && !math.IsInf(nextDeviance, 0) && (coefficients == nil || nextDeviance <= deviance*(1+1e-10)) {
+				accepted = true
+				break
+			}
+			if coefficients == nil {
+				return fmt.Errorf("IRLS started outside the range of the family; try another link")
+			}
+			for j := range next {
+				next[j] = (next[j] + coefficients[j]) / 2
+			}
+		}
+		if !accepted {
+			// No step improves the fit: keep the previous coefficients and stop unconverged
+			g.updateMean(features, coefficients, eta, mu, target, weights)
+			break
+		}
+
+		change := math.Abs(nextDeviance - deviance)
+		coefficients, deviance = next, nextDeviance
+		if change < g.tolerance*(math.Abs(deviance)+0.1) {
+			g.converged = true
+			break
+		}
+	}
+	if g.iterations > g.maxIterations {
+		g.iterations = g.maxIterations
+	}
+
+	g.coefficients = coefficients
+	g.deviance = deviance
+	nullMean := make([]float64, len(target))
+	for i := range nullMean {
+		nullMean[i] = meanTarget
+	}
+	g.nullDeviance = g.totalDeviance(target, nullMean, weights)
+	g.degreesOfFreedom = numObserved - numCoefficients
+
+	// Pearson dispersion φ = Σ w (y - μ)² / V(μ) / (n - p)
+	g.dispersion = 1
+	if !g.family.fixedDispersion() {
+		var pearson float64
+		for i, y := range target {
+			pearson += weights[i] * (y - mu[i]) * (y - mu[i]) / g.family.variance(mu[i])
+		}
+		g.dispersion = pearson / float64(g.degreesOfFreedom)
+	}
+
+	g.standardErrors, err = g.coefficientStandardErrors(features, mu, weights)
+	if err != nil {
+		return err
+	}
+	g.aic = g.akaike(target, mu, weights, sumWeights)
+	return nil
+}
+
+// updateMean sets eta and mu from coefficients and returns the deviance, or NaN when a
+// mean leaves the valid range of the family.
+func (g *glm) updateMean(features [][]float64, coefficients, eta, mu, target, weights []float64) float64 {
+	for i, row := range features {
+		eta[i] = predictLin(row, coefficients)
+		mu[i] = g.link.inverse(eta[i])
+		if !g.family.validMean(mu[i]) || math.IsNaN(mu[i]) || math.IsInf(mu[i], 0) {
+			return math.NaN()
+		}
+	}
+	return g.totalDeviance(target, mu, weights)
+}
+
+func (g *glm) totalDeviance(target, mu, weights []float64) float64 {
+	var deviance float64
+	for i, y := range target {
+		if weights[i] > 0 {
+			deviance += weights[i] * g.family.unitDeviance(y, mu[i])
+		}
+	}
+	return deviance
+}
+
+// coefficientStandardErrors returns sqrt(diag(φ (XᵀWX)⁻¹)) with the IRLS weights at the
+// fitted means.
+func (g *glm) coefficientStandardErrors(features [][]float64, mu, weights []float64) ([]float64, error) {
+	numCoefficients := len(features[0]) + 1
+	information := mat.NewSymDense(numCoefficients, nil)
+	row := make([]float64, numCoefficients)
+	for i, featureRow := range features {
+		d := g.link.derivative(mu[i])
+		w := weights[i] / (g.family.variance(mu[i]) * d * d)
+		row[0] = 1
+		copy(row[1:], featureRow)
+		information.SymRankOne(information, w, mat.NewVecDense(numCoefficients, row))
+	}
+	var chol mat.Cholesky
+	if ok := chol.Factorize(information); !ok {
+		return nil, fmt.Errorf("the Fisher information matrix is singular")
+	}
+	var covariance mat.SymDense
+	if err := chol.InverseTo(&covariance); err != nil {
+		return nil, err
+	}
+	standardErrors := make([]float64, numCoefficients)
+	for j := range standardErrors {
+		standardErrors[j] = math.Sqrt(g.dispersion * covariance.At(j, j))
+	}
+	return standardErrors, nil
+}
+
+// akaike returns -2 log L + 2k. Like R, the likelihood uses the maximum likelihood
+// dispersion deviance / Σw, and k counts the dispersion as a parameter when it is
+// estimated.
+func (g *glm) akaike(target, mu, weights []float64, sumWeights float64) float64 {
+	dispersion := 1.0
+	numParameters := float64(len(g.coefficients))
+	if !g.family.fixedDispersion() {
+		dispersion = g.deviance / sumWeights
+		numParameters++
+	}
+
+	var logLikelihood float64
+	for i, y := range target {
+		if weights[i] > 0 {
+			logLikelihood += weights[i] * g.family.logLikelihood(y, mu[i], dispersion)
+		}
+	}
+	return -2*logLikelihood + 2*numParameters
+}
+
+// Predict returns the expected price, on the response scale.
+func (g *glm) Predict(featureRow []float64) float64 {
+	return g.link.inverse(predictLin(featureRow, g.coefficients))
+}
+
+// summary renders the coefficients with their standard errors and z-values followed by
+// the deviance and AIC, using the given feature column names.
+func (g *glm) summary(columnNames []string) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%-12s %12s %12s %8s\n", "term", "estimate", "std. error", "z")
+	for j, coefficient := range g.coefficients {
+		name := "(intercept)"
+		if j > 0 {
+			name = featureName(columnNames, j-1)
+		}
+		fmt.Fprintf(&sb, "%-12s %12.5g %12.5g %8.3f\n", name, coefficient, g.standardErrors[j], coefficient/g.standardErrors[j])
+	}
+	fmt.Fprintf(&sb, "dispersion: %.5g\n", g.dispersion)
+	fmt.Fprintf(&sb, "null deviance: %.5g, residual deviance: %.5g on %d degrees of freedom\n", g.nullDeviance, g.deviance, g.degreesOfFreedom)
+	fmt.Fprintf(&sb, "AIC: %.5g, IRLS iterations: %d\n", g.aic, g.iterations)
+	return sb.String()
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// skewedData returns positive targets with mean exp(0.5 + 0.3*x1 - 0.2*x2) and
+// multiplicative noise, as a log-link model assumes.
+func skewedData() ([][]float64, []float64) {
+	var features [][]float64
+	var target []float64
+	for i := 0; i < 80; i++ {
+		x1 := float64(i%10) / 2
+		x2 := float64((i * 3) % 7)
+		noise := 1 + 0.2*math.Sin(float64(i)*1.7)
+		features = append(features, []float64{x1, x2})
+		target = append(target, math.Exp(0.5+0.3*x1-0.2*x2)*noise)
+	}
+	return features, target
+}
+
+func TestGaussianIdentityGLMMatchesOLS(t *testing.T) {
+	features, target := skewedData()
+	model := newGLM(gaussianFamily{}, identityLink{})
+	if err := model.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := linearRegression(features, target)
+	for j := range expected {
+		if math.Abs(model.coefficients[j]-expected[j]) > 1e-8 {
+			t.Errorf("Unexpected coefficient %d. Expected %f, got %f", j, expected[j], model.coefficients[j])
+		}
+	}
+
+	// AIC of a Gaussian model is n (log(2π RSS / n) + 1) + 2 (p + 1)
+	n := float64(len(target))
+	rss := n * meanSquaredError(predictAll(model, features), target)
+	if aic := n*(math.Log(2*math.Pi*rss/n)+1) + 2*4; math.Abs(model.aic-aic) > 1e-6 {
+		t.Errorf("Unexpected AIC. Expected %f, got %f", aic, model.aic)
+	}
+}
+
+func TestLogLinkGLMsRecoverCoefficients(t *testing.T) {
+	features, target := skewedData()
+	expected := []float64{0.5, 0.3, -0.2}
+
+	families := map[string]glmFamily{
+		"gamma":            gammaFamily{},
+		"poisson":          poissonFamily{},
+		"inverse gaussian": inverseGaussianFamily{},
+		"tweedie 1.5":      tweedieFamily{power: 1.5},
+	}
+	for name, family := range families {
+		model := newGLM(family, logLink{})
+		if err := model.Fit(features, target); err != nil {
+			t.Fatalf("Unexpected %s error: %v", name, err)
+		}
+		if !model.converged {
+			t.Errorf("Expected %s IRLS to converge", name)
+		}
+		for j := range expected {
+			if math.Abs(model.coefficients[j]-expected[j]) > 0.05 {
+				t.Errorf("Unexpected %s coefficient %d. Expected about %f, got %f", name, j, expected[j], model.coefficients[j])
+			}
+		}
+		if model.deviance >= model.nullDeviance {
+			t.Errorf("Unexpected %s deviance %f, expected less than the null deviance %f", name, model.deviance, model.nullDeviance)
+		}
+
+		// Predictions are on the response scale
+		if prediction := model.Predict(features[0]); prediction <= 0 {
+			t.Errorf("Unexpected %s prediction %f, expected a positive price", name, prediction)
+		}
+	}
+}
+
+func TestTweedieSpecialPowersMatchNamedFamilies(t *testing.T) {
+	features, target := skewedData()
+	gamma := newGLM(gammaFamily{}, logLink{})
+	tweedie := newGLM(tweedieFamily{power: 2}, logLink{})
+	if err := gamma.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := tweedie.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if math.Abs(gamma.aic-tweedie.aic) > 1e-8 || math.Abs(gamma.deviance-tweedie.deviance) > 1e-8 {
+		t.Errorf("Unexpected Tweedie power 2 fit. Expected AIC %f and deviance %f, got %f and %f", gamma.aic, gamma.deviance, tweedie.aic, tweedie.deviance)
+	}
+
+	general := newGLM(tweedieFamily{power: 1.5}, logLink{})
+	if err := general.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !math.IsNaN(general.aic) {
+		t.Errorf("Unexpected AIC for Tweedie power 1.5. Expected NaN, got %f", general.aic)
+	}
+}
+
+func TestGLMInverseLinkAndSummary(t *testing.T) {
+	features, target := skewedData()
+	model := newGLM(gammaFamily{}, inverseLink{})
+	if err := model.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	summary := model.summary([]string{"rooms", "lstat"})
+	for _, expected := range []string{"(intercept)", "rooms", "lstat", "AIC"} {
+		if !strings.Contains(summary, expected) {
+			t.Errorf("Expected the summary to mention %q:\n%s", expected, summary)
+		}
+	}
+}
+
+func TestGLMRejectsInvalidTarget(t *testing.T) {
+	features := [][]float64{{1}, {2}, {3}, {4}}
+	target := []float64{1, 0, 2, 3}
+	if err := newGLM(gammaFamily{}, logLink{}).Fit(features, target); err == nil {
+		t.Errorf("Expected an error for a zero target with the Gamma family")
+	}
+	if err := newGLM(poissonFamily{}, logLink{}).Fit(features, target); err != nil {
+		t.Errorf("Unexpected Poisson error: %v", err)
+	}
+}
@@ -2,6 +2,8 @@ module example.com/mod
 
 go 1.18
 
+require gonum.org/v1/gonum v0.13.0
+
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/gonum/blas v0.0.0-20181208220705-f22b278b28ac // indirect
@@ -18,6 +20,5 @@ require (
 	github.com/stretchr/testify v1.8.4 // indirect
 	golang.org/x/exp v0.0.0-20230321023759-10a507213a29 // indirect
 	golang.org/x/sync v0.0.0-20200317015054-43a5402ce75a // indirect
-	gonum.org/v1/gonum v0.13.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
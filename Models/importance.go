@@ -0,0 +1,364 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// importanceMethod measures how much a fitted model relies on each feature.
+type importanceMethod string
+
+const (
+	importanceCoefficient importanceMethod = "coefficient" // |b_j| × sd(x_j), linear models only
+	importancePermutation importanceMethod = "permutation" // MSE increase when x_j is shuffled
+)
+
+// coefficientImportance returns |b_j| × sd(x_j) for every feature of a fitted linear
+// model, so features on different scales compare fairly. Nil weights weight every row
+// equally in the standard deviations.
+func coefficientImportance(model regressor, features [][]float64, weights []float64) ([]float64, error) {
+	linear, ok := model.(coefficientModel)
+	if !ok {
+		return nil, fmt.Errorf("coefficient importance needs a linear model, got %T", model)
+	}
+	coefficients := linear.linearCoefficients()
+	scaler := fitWeightedScaler(features, weights)
+	importances := make([]float64, len(features[0]))
+	for j := range importances {
+		importances[j] = math.Abs(coefficients[j+1]) * scaler.scale[j]
+	}
+	return importances, nil
+}
+
+// permutationImportance returns, for every feature, the mean increase in the MSE of a
+// fitted model when that feature's column is shuffled, over numRepeats shuffles. It works
+// for any model. Features are scored through runTasks, one task per feature. With
+// weights the MSE is weighted; the shuffle moves values between rows but not weights.
+func permutationImportance(model regressor, features [][]float64, target []float64, weights []float64, numRepeats int, seed int64) []float64 {
+	if len(features) != len(target) {
+		panic("Features and target length mismatch")
+	}
+	mse := func(predictions []float64) float64 {
+		if weights == nil {
+			return meanSquaredError(predictions, target)
+		}
+		return weightedMeanSquaredError(predictions, target, weights)
+	}
+	baseline := mse(predictAll(model, features))
+	importances := make([]float64, len(features[0]))
+	runTasks(len(importances), func(j int) {
+		rng := rand.New(rand.NewSource(seed + int64(j)))
+		shuffled := make([][]float64, len(features))
+		for i, row := range features {
+			shuffled[i] = append([]float64(nil), row...)
+		}
+		for repeat := 0; repeat < numRepeats; repeat++ {
+			for i, k := range rng.Perm(len(features)) {
+				shuffled[i][j] = features[k][j]
+			}
+			importances[j] += (mse(predictAll(model, shuffled)) - baseline) / float64(numRepeats)
+		}
+	})
+	return importances
+}
+
+// recursiveFeatureElimination fits a model from newModel, drops the step least important
+// columns, and repeats on the remaining columns. With numFeatures 0 it runs down to one
+// column and keeps the subset with the lowest cross-validated MSE.
+type recursiveFeatureElimination struct {
+	newModel    func() regressor
+	importance  importanceMethod
+	numFeatures int // columns to keep, 0 chooses the count by cross-validation
+	step        int // columns dropped per round
+	numFolds    int
+	numRepeats  int // shuffles per column for importancePermutation
+	seed        int64
+
+	ranking     []int // 1 for the selected columns, larger for columns dropped earlier
+	selected    []int
+	subsetSizes []int     // number of columns at every round
+	cvMSE       []float64 // cross-validated MSE at every round, when choosing by CV
+	model       regressor // refit on the selected columns
+}
+
+// newRFE returns a recursiveFeatureElimination that drops one column per round and
+// chooses how many to keep by 5-fold cross-validation.
+func newRFE(newModel func() regressor, importance importanceMethod) *recursiveFeatureElimination {
+	return &recursiveFeatureElimination{newModel: newModel, importance: importance, step: 1, numFolds: 5, numRepeats: 5, seed: 1}
+}
+
+func (r *recursiveFeatureElimination) Fit(features [][]float64, target []float64) error {
+	return r.FitWeighted(features, target, nil)
+}
+
+// FitWeighted passes the weights to every model from newModel, which must then be a
+// weightedRegressor, and weights the importances and the cross-validated MSE.
+func (r *recursiveFeatureElimination) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	if weights != nil {
+		if _, err := checkWeights(weights, len(target)); err != nil {
+			return err
+		}
+	}
+	numFeatures := len(features[0])
+	if r.numFeatures < 0 || r.numFeatures > numFeatures {
+		return fmt.Errorf("numFeatures must be between 0 and %d, got %d", numFeatures, r.numFeatures)
+	}
+	if r.step < 1 {
+		return fmt.Errorf("step must be at least 1, got %d", r.step)
+	}
+	keep := r.numFeatures
+	if keep == 0 {
+		keep = 1
+	}
+
+	remaining := make([]int, numFeatures)
+	for j := range remaining {
+		remaining[j] = j
+	}
+	// droppedAt[j] is the number of columns in the last round that still had column j
+	droppedAt := make([]int, numFeatures)
+	r.subsetSizes, r.cvMSE = nil, nil
+	for {
+		subset := selectColumns(features, remaining)
+		r.subsetSizes = append(r.subsetSizes, len(remaining))
+		if r.numFeatures == 0 {
+			mse, err := crossValidatedMSE(r.newModel, subset, target, weights, r.numFolds, r.seed)
+			if err != nil {
+				return err
+			}
+			r.cvMSE = append(r.cvMSE, mse)
+		}
+		if len(remaining) <= keep {
+			break
+		}
+
+		model := r.newModel()
+		if err := fitWithWeights(model, subset, target, weights); err != nil {
+			return err
+		}
+		var importances []float64
+		switch r.importance {
+		case importanceCoefficient:
+			var err error
+			if importances, err = coefficientImportance(model, subset, weights); err != nil {
+				return err
+			}
+		case importancePermutation:
+			importances = permutationImportance(model, subset, target, weights, r.numRepeats, r.seed)
+		default:
+			return fmt.Errorf("unknown importance method %q", r.importance)
+		}
+
+		order := make([]int, len(remaining))
+		for k := range order {
+			order[k] = k
+		}
+		sort.SliceStable(order, func(a, b int) bool { return importances[order[a]] < importances[order[b]] })
+		numDropped := r.step
+		if len(remaining)-numDropped < keep {
+			numDropped = len(remaining) - keep
+		}
+		dropped := make(map[int]bool, numDropped)
+		for _, k := range order[:numDropped] {
+			dropped[remaining[k]] = true
+			droppedAt[remaining[k]] = len(remaining)
+		}
+		var kept []int
+		for _, j := range remaining {
+			if !dropped[j] {
+				kept = append(kept, j)
+			}
+		}
+		remaining = kept
+	}
+
+	// Keep the round with the lowest cross-validated MSE, or the last round
+	chosenSize := r.subsetSizes[len(r.subsetSizes)-1]
+	if r.numFeatures == 0 {
+		best := 0
+		for k, mse := range r.cvMSE {
+			if mse < r.cvMSE[best] {
+				best = k
+			}
+		}
+		chosenSize = r.subsetSizes[best]
+	}
+	r.selected = nil
+	r.ranking = make([]int, numFeatures)
+	for j := range r.ranking {
+		if droppedAt[j] <= chosenSize {
+			r.selected = append(r.selected, j)
+			r.ranking[j] = 1
+			continue
+		}
+		// Columns dropped one round before the chosen one rank 2, and so on
+		for _, size := range r.subsetSizes {
+			if size > chosenSize && size <= droppedAt[j] {
+				r.ranking[j]++
+			}
+		}
+		r.ranking[j]++
+	}
+
+	r.model = r.newModel()
+	return fitWithWeights(r.model, selectColumns(features, r.selected), target, weights)
+}
+
+func (r *recursiveFeatureElimination) Predict(featureRow []float64) float64 {
+	subset := make([]float64, len(r.selected))
+	for k, j := range r.selected {
+		subset[k] = featureRow[j]
+	}
+	return r.model.Predict(subset)
+}
+
+// report lists every column with its rank, best first, and the cross-validation curve.
+func (r *recursiveFeatureElimination) report(columnNames []string) string {
+	order := make([]int, len(r.ranking))
+	for j := range order {
+		order[j] = j
+	}
+	sort.SliceStable(order, func(a, b int) bool { return r.ranking[order[a]] < r.ranking[order[b]] })
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "recursive feature elimination by %s importance\n", r.importance)
+	for _, j := range order {
+		fmt.Fprintf(&sb, "%-10s rank %d\n", featureName(columnNames, j), r.ranking[j])
+	}
+	if r.cvMSE != nil {
+		sb.WriteString("cross-validated RMSE by number of columns:")
+		for k, mse := range r.cvMSE {
+			fmt.Fprintf(&sb, " %d: %.3f", r.subsetSizes[k], math.Sqrt(mse))
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// stabilitySelection fits the lasso on many random half-size subsamples over a grid of
+// penalties and records how often each feature gets a nonzero coefficient. Features
+// selected in most subsamples at some penalty are reliably important; features picked by
+// one lasso fit through a chance correlation rarely reach a high frequency (Meinshausen
+// and Bühlmann, 2010).
+type stabilitySelection struct {
+	lambdas       []float64 // nil uses a geometric grid from the largest useful penalty
+	numSubsamples int
+	fraction      float64 // share of rows in every subsample
+	threshold     float64 // frequency needed to select a feature
+	seed          int64
+
+	frequencies    [][]float64 // by lambda, then by feature
+	maxFrequencies []float64   // by feature, over the lambdas
+	selected       []int
+}
+
+// newStabilitySelection returns a stabilitySelection with 100 half-size subsamples and a
+// selection threshold of 0.6.
+func newStabilitySelection() *stabilitySelection {
+	return &stabilitySelection{numSubsamples: 100, fraction: 0.5, threshold: 0.6, seed: 1}
+}
+
+// fit runs the subsamples through runTasks, one task per subsample, and selects the
+// features whose frequency reaches threshold at some penalty.
+func (s *stabilitySelection) fit(features [][]float64, target []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	if s.fraction <= 0 || s.fraction > 1 {
+		return fmt.Errorf("fraction must be in (0, 1], got %f", s.fraction)
+	}
+	subsampleSize := int(s.fraction * float64(len(target)))
+	if subsampleSize < 2 {
+		return fmt.Errorf("subsamples of %d rows are too small", subsampleSize)
+	}
+
+	lambdas := s.lambdas
+	if lambdas == nil {
+		columns, centered := lassoDesign(features, target, nil, fitScaler(features))
+		largest := lassoMaxLambda(columns, centered)
+		const gridSize, smallest = 20, 0.05
+		lambdas = make([]float64, gridSize)
+		for l := range lambdas {
+			lambdas[l] = largest * math.Pow(smallest, float64(l)/float64(gridSize-1))
+		}
+	}
+	sortedLambdas := append([]float64(nil), lambdas...)
+	sort.Sort(sort.Reverse(sort.Float64Slice(sortedLambdas)))
+
+	numFeatures := len(features[0])
+	chosen := make([][][]bool, s.numSubsamples)
+	runTasks(s.numSubsamples, func(k int) {
+		rng := rand.New(rand.NewSource(s.seed + int64(k)))
+		rows := rng.Perm(len(target))[:subsampleSize]
+		subFeatures := make([][]float64, subsampleSize)
+		subTarget := make([]float64, subsampleSize)
+		for i, row := range rows {
+			subFeatures[i] = features[row]
+			subTarget[i] = target[row]
+		}
+		columns, centered := lassoDesign(subFeatures, subTarget, nil, fitScaler(subFeatures))
+
+		// Walk the penalties from large to small, warm starting every fit from the last
+		slopes := make([]float64, numFeatures)
+		chosen[k] = make([][]bool, len(sortedLambdas))
+		for l, lambda := range sortedLambdas {
+			lassoCoordinateDescent(columns, centered, lambda, slopes, 1000, 1e-7)
+			chosen[k][l] = make([]bool, numFeatures)
+			for j, slope := range slopes {
+				chosen[k][l][j] = slope != 0
+			}
+		}
+	})
+
+	s.lambdas = sortedLambdas
+	s.frequencies = make([][]float64, len(sortedLambdas))
+	s.maxFrequencies = make([]float64, numFeatures)
+	for l := range sortedLambdas {
+		s.frequencies[l] = make([]float64, numFeatures)
+		for j := range s.frequencies[l] {
+			count := 0
+			for k := range chosen {
+				if chosen[k][l][j] {
+					count++
+				}
+			}
+			s.frequencies[l][j] = float64(count) / float64(s.numSubsamples)
+			s.maxFrequencies[j] = math.Max(s.maxFrequencies[j], s.frequencies[l][j])
+		}
+	}
+	s.selected = nil
+	for j, frequency := range s.maxFrequencies {
+		if frequency >= s.threshold {
+			s.selected = append(s.selected, j)
+		}
+	}
+	return nil
+}
+
+// report lists every feature's highest selection frequency, most stable first, and
+// marks the selected ones.
+func (s *stabilitySelection) report(columnNames []string) string {
+	order := make([]int, len(s.maxFrequencies))
+	for j := range order {
+		order[j] = j
+	}
+	sort.SliceStable(order, func(a, b int) bool { return s.maxFrequencies[order[a]] > s.maxFrequencies[order[b]] })
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "stability selection: %d subsamples of %.0f%% of the rows, %d penalties, threshold %.2f\n",
+		s.numSubsamples, 100*s.fraction, len(s.lambdas), s.threshold)
+	for _, j := range order {
+		mark := ""
+		if s.maxFrequencies[j] >= s.threshold {
+			mark = "selected"
+		}
+		fmt.Fprintf(&sb, "%-10s %6.2f %s\n", featureName(columnNames, j), s.maxFrequencies[j], mark)
+	}
+	return sb.String()
+}
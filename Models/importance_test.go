@@ -0,0 +1,104 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLasso(t *testing.T) {
+	features, target := noisyLinearData(200)
+
+	// Without a penalty the lasso is OLS
+	ols := newLasso(0)
+	if err := ols.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := linearRegression(features, target)
+	for j := range expected {
+		if math.Abs(ols.coefficients[j]-expected[j]) > 1e-5 {
+			t.Errorf("Unexpected coefficient %d. Expected %f, got %f", j, expected[j], ols.coefficients[j])
+		}
+	}
+
+	// A moderate penalty zeroes the feature that has no effect and keeps the others
+	lasso := newLasso(0.1)
+	if err := lasso.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lasso.coefficients[3] != 0 || lasso.coefficients[1] == 0 || lasso.coefficients[2] == 0 {
+		t.Errorf("Unexpected lasso coefficients: %v", lasso.coefficients)
+	}
+
+	// At the largest useful penalty every slope is zero and the intercept is the mean
+	columns, centered := lassoDesign(features, target, nil, fitScaler(features))
+	lasso = newLasso(lassoMaxLambda(columns, centered))
+	if err := lasso.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(lasso.coefficients[1:], []float64{0, 0, 0}) {
+		t.Errorf("Unexpected slopes at the largest penalty: %v", lasso.coefficients[1:])
+	}
+}
+
+func TestRecursiveFeatureElimination(t *testing.T) {
+	features, target := noisyLinearData(200)
+
+	rfe := newRFE(func() regressor { return &linearModel{} }, importanceCoefficient)
+	rfe.numFeatures = 2
+	if err := rfe.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(rfe.selected, []int{0, 1}) || !reflect.DeepEqual(rfe.ranking, []int{1, 1, 2}) {
+		t.Errorf("Unexpected selection %v with ranking %v", rfe.selected, rfe.ranking)
+	}
+	if got, want := rfe.Predict(features[0]), predictLin(features[0][:2], linearRegression(selectColumns(features, []int{0, 1}), target)); math.Abs(got-want) > 1e-9 {
+		t.Errorf("Unexpected prediction. Expected %f, got %f", want, got)
+	}
+
+	// Permutation importance works for models without coefficients
+	trees := newRFE(func() regressor { return newDecisionTree() }, importancePermutation)
+	if err := trees.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if trees.ranking[0] != 1 || trees.ranking[2] == 1 {
+		t.Errorf("Unexpected tree ranking %v", trees.ranking)
+	}
+	if len(trees.cvMSE) != 3 {
+		t.Errorf("Unexpected cross-validation curve length. Expected 3, got %d", len(trees.cvMSE))
+	}
+	if report := trees.report([]string{"rooms", "lstat", "age"}); !strings.Contains(report, "rooms      rank 1") {
+		t.Errorf("Expected rooms to rank first:\n%s", report)
+	}
+
+	wrong := newRFE(func() regressor { return newDecisionTree() }, importanceCoefficient)
+	if err := wrong.Fit(features, target); err == nil {
+		t.Errorf("Expected an error for coefficient importance on a tree")
+	}
+}
+
+func TestStabilitySelection(t *testing.T) {
+	features, target := noisyLinearData(200)
+
+	s := newStabilitySelection()
+	s.numSubsamples = 50
+	if err := s.fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(s.selected, []int{0, 1}) {
+		t.Errorf("Unexpected selection. Expected [0 1], got %v", s.selected)
+	}
+	if s.maxFrequencies[0] != 1 || s.maxFrequencies[2] > 0.5 {
+		t.Errorf("Unexpected selection frequencies: %v", s.maxFrequencies)
+	}
+	// The default grid runs from the largest penalty down
+	for l := 1; l < len(s.lambdas); l++ {
+		if s.lambdas[l] >= s.lambdas[l-1] {
+			t.Fatalf("Unexpected penalty order: %v", s.lambdas)
+		}
+	}
+	if !strings.Contains(s.report([]string{"rooms", "lstat", "age"}), "rooms") {
+		t.Errorf("Expected the report to name the features")
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// isotonicRegression fits the best monotone step function of one feature column in
+// weighted least squares, by the pool-adjacent-violators algorithm: points are sorted by
+// the feature, and neighbouring blocks whose means break the ordering are pooled into
+// their weighted mean until none do. Rows with equal feature values are pooled first, so
+// the fit is a function of the feature.
+//
+// The fitted function is a list of blocks, each covering [lower, upper] of the training
+// values with a constant value. Between two blocks the lower block's value holds, and
+// outside the training range the nearest block's value does.
+//
+// Besides regressing on a column such as rooms, fitCurve fits it to any one-dimensional
+// input, which is how calibratedRegressor uses it to recalibrate a model's predictions.
+type isotonicRegression struct {
+	column     int  // feature column used by Fit and Predict
+	increasing bool // false for a non-increasing fit
+
+	lowers []float64 // smallest training value in every block
+	uppers []float64 // largest training value in every block
+	values []float64 // fitted value of every block, monotone
+	counts []float64 // total weight of every block
+}
+
+// calibratedRegressor recalibrates a model's predictions with an isotonic regression
+// fit on its out-of-fold predictions, e.g. to turn logistic regression scores into
+// probabilities that match the observed frequencies. Like stackingEnsemble it uses
+// out-of-fold predictions so the calibration reflects rows the model was not trained on;
+// the model is then refit on all rows.
+type calibratedRegressor struct {
+	newModel func() regressor
+	numFolds int
+	seed     int64
+
+	model      regressor
+	calibrator *isotonicRegression
+}
+
+// newIsotonicRegression returns a non-decreasing isotonicRegression on feature column.
+func newIsotonicRegression(column int) *isotonicRegression {
+	return &isotonicRegression{column: column, increasing: true}
+}
+
+// newCalibratedRegressor returns a calibratedRegressor with a non-decreasing calibration
+// fit on 5-fold out-of-fold predictions.
+func newCalibratedRegressor(newModel func() regressor) *calibratedRegressor {
+	return &calibratedRegressor{newModel: newModel, numFolds: 5, seed: 1}
+}
+
+func (m *isotonicRegression) Fit(features [][]float64, target []float64) error {
+	return m.FitWeighted(features, target, nil)
+}
+
+func (m *isotonicRegression) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	values := make([]float64, len(features))
+	for i, row := range features {
+		if m.column < 0 || m.column >= len(row) {
+			return fmt.Errorf("column %d out of range for row %d with %d features", m.column, i, len(row))
+		}
+		values[i] = row[m.column]
+	}
+	return m.fitCurve(values, target, weights)
+}
+
+// fitCurve fits the monotone step function of target on values directly.
+func (m *isotonicRegression) fitCurve(values []float64, target []float64, weights []float64) error {
+	if len(values) != len(target) {
+		return fmt.Errorf("values and target length mismatch: %d vs %d", len(values), len(target))
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	for i, v := range values {
+		if math.IsNaN(v) || math.IsNaN(target[i]) {
+			return fmt.Errorf("missing value at row %d", i)
+		}
+	}
+
+	order := make([]int, 0, len(values))
+	for i, w := range weights {
+		if w > 0 {
+			order = append(order, i)
+		}
+	}
+	sort.Slice(order, func(a, b int) bool { return values[order[a]] < values[order[b]] })
+
+	// A decreasing fit is an increasing fit of the negated target
+	sign := 1.0
+	if !m.increasing {
+		sign = -1
+	}
+	var lowers, uppers, sums, counts []float64
+	for start := 0; start < len(order); {
+		// Pool the rows tied on the value into one point
+		end := start
+		var sum, count float64
+		for end < len(order) && values[order[end]] == values[order[start]] {
+			sum += weights[order[end]] * sign * target[order[end]]
+			count += weights[order[end]]
+			end++
+		}
+		value := values[order[start]]
+		lowers, uppers, sums, counts = append(lowers, value), append(uppers, value), append(sums, sum), append(counts, count)
+		start = end
+
+		// Pool backwards while the last block does not exceed the one before it
+		for last := len(sums) - 1; last > 0 && sums[last-1]/counts[last-1] >= sums[last]/counts[last]; last-- {
+			uppers[last-1] = uppers[last]
+			sums[last-1] += sums[last]
+			counts[last-1] += counts[last]
+			lowers, uppers, sums, counts = lowers[:last], uppers[:last], sums[:last], counts[:last]
+		}
+	}
+
+	m.lowers, m.uppers, m.counts = lowers, uppers, counts
+	m.values = make([]float64, len(sums))
+	for k, sum := range sums {
+		m.values[k] = sign * sum / counts[k]
+	}
+	return nil
+}
+
+func (m *isotonicRegression) Predict(featureRow []float64) float64 {
+	return m.transform(featureRow[m.column])
+}
+
+// transform evaluates the fitted step function at value.
+func (m *isotonicRegression) transform(value float64) float64 {
+	if len(m.values) == 0 {
+		panic("Isotonic regression has not been fit")
+	}
+	// The last block starting at or below value, or the first block below the range
+	k := sort.SearchFloat64s(m.lowers, value)
+	if k == len(m.lowers) || m.lowers[k] != value {
+		k--
+	}
+	if k < 0 {
+		k = 0
+	}
+	return m.values[k]
+}
+
+// stepFunction returns the fitted blocks: the range of training values every block
+// covers and its fitted value.
+func (m *isotonicRegression) stepFunction() ([]float64, []float64, []float64) {
+	return m.lowers, m.uppers, m.values
+}
+
+// exportStepFunction writes the fitted blocks to a CSV file with the columns lower,
+// upper, value and weight.
+func (m *isotonicRegression) exportStepFunction(filename string) error {
+	if len(m.values) == 0 {
+		return fmt.Errorf("isotonic regression has not been fit")
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	if err := writer.Write([]string{"lower", "upper", "value", "weight"}); err != nil {
+		return err
+	}
+	for k := range m.values {
+		record := []string{
+			strconv.FormatFloat(m.lowers[k], 'g', -1, 64),
+			strconv.FormatFloat(m.uppers[k], 'g', -1, 64),
+			strconv.FormatFloat(m.values[k], 'g', -1, 64),
+			strconv.FormatFloat(m.counts[k], 'g', -1, 64),
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func (m *calibratedRegressor) Fit(features [][]float64, target []float64) error {
+	return m.FitWeighted(features, target, nil)
+}
+
+// FitWeighted passes the sample weights to the model's folds, the calibration and the
+// final model, which must then be a weightedRegressor.
+func (m *calibratedRegressor) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	outOfFold, err := crossValidatedPredictions(m.newModel, features, target, weights, m.numFolds, m.seed)
+	if err != nil {
+		return err
+	}
+	calibrator := &isotonicRegression{increasing: true}
+	if err := calibrator.fitCurve(outOfFold, target, weights); err != nil {
+		return err
+	}
+	model := m.newModel()
+	if err := fitWithWeights(model, features, target, weights); err != nil {
+		return err
+	}
+	m.model, m.calibrator = model, calibrator
+	return nil
+}
+
+func (m *calibratedRegressor) Predict(featureRow []float64) float64 {
+	return m.calibrator.transform(m.model.Predict(featureRow))
+}
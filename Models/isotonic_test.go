@@ -0,0 +1,120 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestIsotonicRegressionPoolsViolators(t *testing.T) {
+	// The pairs (3, 2) and (4, 3.5) break the ordering, and x = 6 appears twice
+	features := [][]float64{{1}, {2}, {3}, {4}, {5}, {6}, {6}}
+	target := []float64{1, 3, 2, 4, 3.5, 4, 6}
+	model := newIsotonicRegression(0)
+	if err := model.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	lowers, uppers, values := model.stepFunction()
+	expectedLowers := []float64{1, 2, 4, 6}
+	expectedUppers := []float64{1, 3, 5, 6}
+	expectedValues := []float64{1, 2.5, 3.75, 5}
+	if len(values) != len(expectedValues) {
+		t.Fatalf("Unexpected number of blocks. Expected %d, got %d", len(expectedValues), len(values))
+	}
+	for k := range expectedValues {
+		if lowers[k] != expectedLowers[k] || uppers[k] != expectedUppers[k] || math.Abs(values[k]-expectedValues[k]) > 1e-12 {
+			t.Errorf("Unexpected block %d. Expected [%v, %v] -> %v, got [%v, %v] -> %v", k, expectedLowers[k], expectedUppers[k], expectedValues[k], lowers[k], uppers[k], values[k])
+		}
+	}
+
+	// Inside a gap the lower block holds, and outside the range the nearest block does
+	for _, c := range []struct{ x, expected float64 }{{0, 1}, {1.5, 1}, {3, 2.5}, {5.5, 3.75}, {6, 5}, {10, 5}} {
+		if prediction := model.Predict([]float64{c.x}); math.Abs(prediction-c.expected) > 1e-12 {
+			t.Errorf("Unexpected prediction at %v. Expected %f, got %f", c.x, c.expected, prediction)
+		}
+	}
+
+	// A decreasing fit of the negated target mirrors the increasing fit
+	negated := make([]float64, len(target))
+	for i, y := range target {
+		negated[i] = -y
+	}
+	decreasing := newIsotonicRegression(0)
+	decreasing.increasing = false
+	if err := decreasing.Fit(features, negated); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, x := range []float64{1, 2.5, 4, 6} {
+		if a, b := decreasing.Predict([]float64{x}), model.Predict([]float64{x}); math.Abs(a+b) > 1e-12 {
+			t.Errorf("Unexpected decreasing prediction at %v. Expected %f, got %f", x, -b, a)
+		}
+	}
+}
+
+func TestIsotonicRegressionOnRooms(t *testing.T) {
+	features, target, err := loadCSV("boston.csv")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	names, err := loadColumnNames("boston.csv")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	model := newIsotonicRegression(indexOf(names, "rooms"))
+	if err := model.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, _, values := model.stepFunction()
+	for k := 1; k < len(values); k++ {
+		if values[k] <= values[k-1] {
+			t.Errorf("Expected strictly increasing block values, got %f after %f", values[k], values[k-1])
+		}
+	}
+	if r2 := rSquared(predictAll(model, features), target); r2 < 0.5 {
+		t.Errorf("Unexpected R² of value on rooms. Expected above 0.5, got %f", r2)
+	}
+
+	filename := filepath.Join(t.TempDir(), "steps.csv")
+	if err := model.exportStepFunction(filename); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != len(values)+1 {
+		t.Errorf("Unexpected number of CSV lines. Expected %d, got %d", len(values)+1, lines)
+	}
+}
+
+func TestCalibratedRegressor(t *testing.T) {
+	// A linear probability model predicts outside [0, 1]; calibration maps its
+	// predictions to observed frequencies
+	features, labels := logisticData(500)
+	newLinear := func() regressor { return &linearModel{} }
+	linear := newLinear()
+	if err := linear.Fit(features, labels); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	calibrated := newCalibratedRegressor(newLinear)
+	if err := calibrated.Fit(features, labels); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	raw := predictAll(linear, features)
+	probabilities := predictAll(calibrated, features)
+	for i, p := range probabilities {
+		if p < 0 || p > 1 {
+			t.Fatalf("Unexpected calibrated probability at row %d. Expected within [0, 1], got %f", i, p)
+		}
+	}
+	if before, after := brierScore(raw, labels), brierScore(probabilities, labels); after >= before {
+		t.Errorf("Expected calibration to lower the Brier score, got %f before and %f after", before, after)
+	}
+	if auc := rocAUC(probabilities, labels); auc < 0.8 {
+		t.Errorf("Unexpected ROC AUC after calibration. Expected above 0.8, got %f", auc)
+	}
+}
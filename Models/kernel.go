@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"math"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+)
+
+// kernel is a positive definite covariance function between two feature rows.
+// Hyperparameters are exchanged on the log scale so optimizers can search them freely.
+type kernel interface {
+	eval(a, b []float64) float64
+	logHyperparameters() []float64
+	withLogHyperparameters(logParams []float64) kernel
+}
+
+// rbfKernel is variance * exp(-|a-b|² / (2 lengthScale²)).
+type rbfKernel struct {
+	lengthScale float64
+	variance    float64
+}
+
+// maternKernel is the Matérn covariance with smoothness nu of 0.5, 1.5 or 2.5. nu = 0.5 is
+// the exponential kernel and nu → ∞ approaches the RBF kernel.
+type maternKernel struct {
+	nu          float64
+	lengthScale float64
+	variance    float64
+}
+
+// linearKernel is variance * a·b + offset; with it kernel ridge reduces to ridge regression.
+// Its hyperparameters are optimized on the log scale, so a Gaussian process that
+// optimizes them needs a positive offset.
+type linearKernel struct {
+	variance float64
+	offset   float64
+}
+
+func (k rbfKernel) eval(a, b []float64) float64 {
+	distance := floats.Distance(a, b, 2)
+	return k.variance * math.Exp(-distance*distance/(2*k.lengthScale*k.lengthScale))
+}
+
+func (k rbfKernel) logHyperparameters() []float64 {
+	return []float64{math.Log(k.lengthScale), math.Log(k.variance)}
+}
+
+func (k rbfKernel) withLogHyperparameters(logParams []float64) kernel {
+	return rbfKernel{lengthScale: math.Exp(logParams[0]), variance: math.Exp(logParams[1])}
+}
+
+func (k maternKernel) eval(a, b []float64) float64 {
+	r := floats.Distance(a, b, 2) / k.lengthScale
+	switch k.nu {
+	case 0.5:
+		return k.variance * math.Exp(-r)
+	case 1.5:
+		s := math.Sqrt(3) * r
+		return k.variance * (1 + s) * math.Exp(-s)
+	case 2.5:
+		s := math.Sqrt(5) * r
+		return k.variance * (1 + s + s*s/3) * math.Exp(-s)
+	}
+	panic(fmt.Sprintf("Matérn kernel supports nu 0.5, 1.5 and 2.5, got %v", k.nu))
+}
+
+func (k maternKernel) logHyperparameters() []float64 {
+	return []float64{math.Log(k.lengthScale), math.Log(k.variance)}
+}
+
+func (k maternKernel) withLogHyperparameters(logParams []float64) kernel {
+	return maternKernel{nu: k.nu, lengthScale: math.Exp(logParams[0]), variance: math.Exp(logParams[1])}
+}
+
+func (k linearKernel) eval(a, b []float64) float64 {
+	return k.variance*floats.Dot(a, b) + k.offset
+}
+
+func (k linearKernel) logHyperparameters() []float64 {
+	return []float64{math.Log(k.variance), math.Log(k.offset)}
+}
+
+func (k linearKernel) withLogHyperparameters(logParams []float64) kernel {
+	return linearKernel{variance: math.Exp(logParams[0]), offset: math.Exp(logParams[1])}
+}
+
+// gramMatrix returns K with K[i][j] = k(features[i], features[j]) plus diagonal[i] on the
+// diagonal. A nil diagonal adds nothing.
+func gramMatrix(k kernel, features [][]float64, diagonal []float64) *mat.SymDense {
+	n := len(features)
+	gram := mat.NewSymDense(n, nil)
+	for i := 0; i < n; i++ {
+		for j := i; j < n; j++ {
+			value := k.eval(features[i], features[j])
+			if i == j && diagonal != nil {
+				value += diagonal[i]
+			}
+			gram.SetSym(i, j, value)
+		}
+	}
+	return gram
+}
+
+// kernelVector returns k(features[i], featureRow) for every training row.
+func kernelVector(k kernel, features [][]float64, featureRow []float64) *mat.VecDense {
+	values := make([]float64, len(features))
+	for i, row := range features {
+		values[i] = k.eval(row, featureRow)
+	}
+	return mat.NewVecDense(len(values), values)
+}
+
+// weightedDiagonal returns value / weights[i] for every row: the ridge penalty or noise
+// variance of a row that stands for weights[i] observations.
+func weightedDiagonal(value float64, weights []float64) []float64 {
+	diagonal := make([]float64, len(weights))
+	for i, w := range weights {
+		diagonal[i] = value / w
+	}
+	return diagonal
+}
+
+// kernelRidge is ridge regression in the feature space of a kernel: it solves
+// (K + λI) α = y - mean(y) with a Cholesky factorization and predicts
+// mean(y) + Σ α_i k(x_i, x). With sample weights the system is (K + λW⁻¹) α = y - mean(y)
+// with a weighted mean.
+type kernelRidge struct {
+	kernel      kernel
+	lambda      float64
+	standardize bool
+
+	scaler     standardScaler
+	features   [][]float64 // scaled training rows
+	alpha      *mat.VecDense
+	targetMean float64
+}
+
+func (m *kernelRidge) Fit(features [][]float64, target []float64) error {
+	return m.FitWeighted(features, target, nil)
+}
+
+func (m *kernelRidge) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	if m.lambda <= 0 {
+		return fmt.Errorf("kernel ridge needs a positive lambda, got %v", m.lambda)
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	features, target, weights = positiveWeightRows(features, target, weights)
+
+	m.scaler = identityScaler(len(features[0]))
+	if m.standardize {
+		m.scaler = fitScaler(features)
+	}
+	m.features = m.scaler.transformAll(features)
+	m.targetMean = stat.Mean(target, weights)
+
+	centered := make([]float64, len(target))
+	for i, y := range target {
+		centered[i] = y - m.targetMean
+	}
+
+	var chol mat.Cholesky
+	if ok := chol.Factorize(gramMatrix(m.kernel, m.features, weightedDiagonal(m.lambda, weights))); !ok {
+		return fmt.Errorf("kernel matrix is not positive definite")
+	}
+	m.alpha = mat.NewVecDense(len(centered), nil)
+	return chol.SolveVecTo(m.alpha, mat.NewVecDense(len(centered), centered))
+}
+
+func (m *kernelRidge) Predict(featureRow []float64) float64 {
+	k := kernelVector(m.kernel, m.features, m.scaler.transform(featureRow))
+	return m.targetMean + mat.Dot(k, m.alpha)
+}
+
+// gaussianProcess is Gaussian process regression with a zero-mean prior on the
+// standardized target, a kernel covariance and Gaussian observation noise. With optimize
+// set, Fit chooses the kernel hyperparameters and noise by maximizing the log marginal
+// likelihood with nelderMead, starting from the given values. A row with sample weight w
+// gets noise variance noise / w, as if it were the mean of w observations.
+type gaussianProcess struct {
+	kernel      kernel
+	noise       float64 // observation noise variance on the standardized target scale
+	optimize    bool
+	standardize bool
+
+	scaler                standardScaler
+	features              [][]float64
+	chol                  mat.Cholesky
+	alpha                 *mat.VecDense
+	targetMean, targetStd float64
+	logMarginalLikelihood float64
+}
+
+// newGaussianProcess returns a gaussianProcess that fits the hyperparameters of k on
+// standardized features.
+func newGaussianProcess(k kernel) *gaussianProcess {
+	return &gaussianProcess{kernel: k, noise: 0.1, optimize: true, standardize: true}
+}
+
+func (gp *gaussianProcess) Fit(features [][]float64, target []float64) error {
+	return gp.FitWeighted(features, target, nil)
+}
+
+func (gp *gaussianProcess) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	features, target, weights = positiveWeightRows(features, target, weights)
+
+	gp.scaler = identityScaler(len(features[0]))
+	if gp.standardize {
+		gp.scaler = fitScaler(features)
+	}
+	gp.features = gp.scaler.transformAll(features)
+	gp.targetMean, gp.targetStd = weightedMeanStdDev(target, weights)
+	if gp.targetStd == 0 {
+		gp.targetStd = 1
+	}
+	normalized := make([]float64, len(target))
+	for i, y := range target {
+		normalized[i] = (y - gp.targetMean) / gp.targetStd
+	}
+	y := mat.NewVecDense(len(normalized), normalized)
+
+	if gp.optimize {
+		start := append(gp.kernel.logHyperparameters(), math.Log(gp.noise))
+		numKernel := len(start) - 1
+		// Keep the search away from overflowing or degenerate hyperparameters
+		for _, p := range start {
+			if !(p >= -12 && p <= 12) {
+				return fmt.Errorf("starting hyperparameters must lie in [exp(-12), exp(12)] to be optimized, got %+v (a linear kernel needs a positive offset)", gp.kernel)
+			}
+		}
+		negativeLML := func(logParams []float64) float64 {
+			for _, p := range logParams {
+				if p < -12 || p > 12 {
+					return math.Inf(1)
+				}
+			}
+			k := gp.kernel.withLogHyperparameters(logParams[:numKernel])
+			lml, err := logMarginalLikelihood(k, gp.features, y, weightedDiagonal(math.Exp(logParams[numKernel]), weights))
+			if err != nil {
+				return math.Inf(1)
+			}
+			return -lml
+		}
+		if math.IsInf(negativeLML(start), 1) {
+			return fmt.Errorf("kernel matrix is not positive definite at the starting hyperparameters")
+		}
+		best := nelderMead(negativeLML, start, 400)
+		gp.kernel = gp.kernel.withLogHyperparameters(best[:numKernel])
+		gp.noise = math.Exp(best[numKernel])
+	}
+
+	if ok := gp.chol.Factorize(gramMatrix(gp.kernel, gp.features, weightedDiagonal(gp.noise, weights))); !ok {
+		return fmt.Errorf("kernel matrix is not positive definite")
+	}
+	gp.alpha = mat.NewVecDense(len(normalized), nil)
+	if err := gp.chol.SolveVecTo(gp.alpha, y); err != nil {
+		return err
+	}
+	gp.logMarginalLikelihood = -0.5*mat.Dot(y, gp.alpha) - 0.5*gp.chol.LogDet() - float64(len(normalized))/2*math.Log(2*math.Pi)
+	return nil
+}
+
+// logMarginalLikelihood is log p(y | X, θ) = -½ yᵀ(K+Σ)⁻¹y - ½ log|K+Σ| - n/2 log 2π, with
+// Σ the diagonal matrix of the per-row noise variances.
+func logMarginalLikelihood(k kernel, features [][]float64, y *mat.VecDense, noise []float64) (float64, error) {
+	var chol mat.Cholesky
+	if ok := chol.Factorize(gramMatrix(k, features, noise)); !ok {
+		return 0, fmt.Errorf("kernel matrix is not positive definite")
+	}
+	var alpha mat.VecDense
+	if err := chol.SolveVecTo(&alpha, y); err != nil {
+		return 0, err
+	}
+	n := float64(y.Len())
+	return -0.5*mat.Dot(y, &alpha) - 0.5*chol.LogDet() - n/2*math.Log(2*math.Pi), nil
+}
+
+func (gp *gaussianProcess) Predict(featureRow []float64) float64 {
+	mean, _ := gp.predictWithVariance(featureRow)
+	return mean
+}
+
+// predictWithVariance returns the posterior mean and variance of the latent function at
+// featureRow, on the original target scale. Add noise * targetStd² to get the variance of
+// a new observed price.
+func (gp *gaussianProcess) predictWithVariance(featureRow []float64) (float64, float64) {
+	scaled := gp.scaler.transform(featureRow)
+	k := kernelVector(gp.kernel, gp.features, scaled)
+	mean := mat.Dot(k, gp.alpha)
+
+	var v mat.VecDense
+	if err := gp.chol.SolveVecTo(&v, k); err != nil {
+		panic(err)
+	}
+	variance := math.Max(gp.kernel.eval(scaled, scaled)-mat.Dot(k, &v), 0)
+
+	return gp.targetMean + gp.targetStd*mean, variance * gp.targetStd * gp.targetStd
+}
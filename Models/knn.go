@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// distanceMetrics are the metrics a knnRegressor can use. All of them are p-norms, so the
+// distance along a single axis is a lower bound of the full distance, which is what the
+// KD-tree relies on to skip branches.
+var distanceMetrics = map[string]func(a, b []float64) float64{
+	"euclidean": func(a, b []float64) float64 {
+		var sum float64
+		for i := range a {
+			diff := a[i] - b[i]
+			sum += diff * diff
+		}
+		return math.Sqrt(sum)
+	},
+	"manhattan": func(a, b []float64) float64 {
+		var sum float64
+		for i := range a {
+			sum += math.Abs(a[i] - b[i])
+		}
+		return sum
+	},
+	"chebyshev": func(a, b []float64) float64 {
+		var largest float64
+		for i := range a {
+			largest = math.Max(largest, math.Abs(a[i]-b[i]))
+		}
+		return largest
+	},
+}
+
+// neighbor is a training row returned by a nearest neighbor search: its index in the
+// training data (counting only rows with positive weight), its distance from the query, its original feature row and its target.
+type neighbor struct {
+	index    int
+	distance float64
+	row      []float64
+	target   float64
+}
+
+// kdNode splits the points below it on axis at the value of points[index][axis].
+type kdNode struct {
+	index       int
+	axis        int
+	left, right *kdNode
+}
+
+// kdTree indexes points for k-nearest-neighbor queries under any distanceMetrics metric.
+type kdTree struct {
+	points   [][]float64
+	root     *kdNode
+	distance func(a, b []float64) float64
+}
+
+// knnRegressor predicts the (optionally distance weighted) mean target of the k training
+// rows closest to a query. Appraisers can read these rows as comparable properties, so
+// predictWithNeighbors returns them along with the prediction.
+type knnRegressor struct {
+	k           int
+	weighting   string // "uniform" or "distance" (inverse distance)
+	metric      string // a key of distanceMetrics
+	standardize bool   // scale every feature to zero mean and unit variance before searching
+
+	features [][]float64
+	target   []float64
+	weights  []float64 // sample weights, nil for equal weights
+	scaler   standardScaler
+	tree     *kdTree
+}
+
+// newKNN returns a uniformly weighted Euclidean knnRegressor on standardized features.
+func newKNN(k int) *knnRegressor {
+	return &knnRegressor{k: k, weighting: "uniform", metric: "euclidean", standardize: true}
+}
+
+// newKDTree builds a balanced KD-tree by splitting on the median along the axes in turn.
+func newKDTree(points [][]float64, distance func(a, b []float64) float64) *kdTree {
+	indices := make([]int, len(points))
+	for i := range indices {
+		indices[i] = i
+	}
+	tree := &kdTree{points: points, distance: distance}
+	tree.root = tree.build(indices, 0)
+	return tree
+}
+
+func (t *kdTree) build(indices []int, depth int) *kdNode {
+	if len(indices) == 0 {
+		return nil
+	}
+	axis := depth % len(t.points[indices[0]])
+	sort.Slice(indices, func(a, b int) bool { return t.points[indices[a]][axis] < t.points[indices[b]][axis] })
+	median := len(indices) / 2
+	return &kdNode{
+		index: indices[median],
+		axis:  axis,
+		left:  t.build(indices[:median], depth+1),
+		right: t.build(indices[median+1:], depth+1),
+	}
+}
+
+// nearest returns the k points closest to query, closest first, as (index, distance) pairs
+// in a neighbor slice without rows or targets.
+func (t *kdTree) nearest(query []float64, k int) []neighbor {
+	best := make([]neighbor, 0, k+1)
+	t.search(t.root, query, k, &best)
+	return best
+}
+
+func (t *kdTree) search(node *kdNode, query []float64, k int, best *[]neighbor) {
+	if node == nil {
+		return
+	}
+
+	// Insert this point into the sorted candidate list if it is close enough
+	distance := t.distance(query, t.points[node.index])
+	if len(*best) < k || distance < (*best)[len(*best)-1].distance {
+		position := sort.Search(len(*best), func(i int) bool { return (*best)[i].distance > distance })
+		*best = append(*best, neighbor{})
+		copy((*best)[position+1:], (*best)[position:])
+		(*best)[position] = neighbor{index: node.index, distance: distance}
+		if len(*best) > k {
+			*best = (*best)[:k]
+		}
+	}
+
+	// Visit the side of the split containing the query first, then the far side only if
+	// the splitting plane is closer than the current k-th neighbor
+	gap := query[node.axis] - t.points[node.index][node.axis]
+	near, far := node.left, node.right
+	if gap > 0 {
+		near, far = node.right, node.left
+	}
+	t.search(near, query, k, best)
+	if len(*best) < k || math.Abs(gap) < (*best)[len(*best)-1].distance {
+		t.search(far, query, k, best)
+	}
+}
+
+func (m *knnRegressor) Fit(features [][]float64, target []float64) error {
+	return m.FitWeighted(features, target, nil)
+}
+
+// FitWeighted stores the sample weights, which multiply the neighbor weights at prediction
+// time. Rows with zero weight are dropped, so they can never make up all the neighbors.
+func (m *knnRegressor) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	if weights != nil {
+		if _, err := checkWeights(weights, len(target)); err != nil {
+			return err
+		}
+		features, target, weights = positiveWeightRows(features, target, weights)
+	}
+	if m.k < 1 || m.k > len(features) {
+		return fmt.Errorf("k must be between 1 and %d, got %d", len(features), m.k)
+	}
+	if m.weighting != "uniform" && m.weighting != "distance" {
+		return fmt.Errorf("unknown weighting %q", m.weighting)
+	}
+	distance, ok := distanceMetrics[m.metric]
+	if !ok {
+		return fmt.Errorf("unknown metric %q", m.metric)
+	}
+
+	m.scaler = identityScaler(len(features[0]))
+	if m.standardize {
+		m.scaler = fitWeightedScaler(features, weights)
+	}
+	m.features = features
+	m.target = target
+	m.weights = weights
+	m.tree = newKDTree(m.scaler.transformAll(features), distance)
+	return nil
+}
+
+func (m *knnRegressor) Predict(featureRow []float64) float64 {
+	prediction, _ := m.predictWithNeighbors(featureRow)
+	return prediction
+}
+
+// predictWithNeighbors returns the prediction for featureRow together with the k training
+// rows it was averaged from, closest first.
+func (m *knnRegressor) predictWithNeighbors(featureRow []float64) (float64, []neighbor) {
+	neighbors := m.tree.nearest(m.scaler.transform(featureRow), m.k)
+	for n := range neighbors {
+		neighbors[n].row = m.features[neighbors[n].index]
+		neighbors[n].target = m.target[neighbors[n].index]
+	}
+
+	// Exact matches would get infinite weight; average them on their own instead
+	if m.weighting == "distance" && neighbors[0].distance > 0 {
+		var sum, sumWeights float64
+		for _, n := range neighbors {
+			weight := weightAt(m.weights, n.index) / n.distance
+			sum += weight * n.target
+			sumWeights += weight
+		}
+		return sum / sumWeights, neighbors
+	}
+
+	var sum, sumWeights float64
+	for _, n := range neighbors {
+		if m.weighting == "distance" && n.distance > 0 {
+			break
+		}
+		sum += weightAt(m.weights, n.index) * n.target
+		sumWeights += weightAt(m.weights, n.index)
+	}
+	return sum / sumWeights, neighbors
+}
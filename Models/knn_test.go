@@ -0,0 +1,95 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+func TestKDTreeMatchesBruteForce(t *testing.T) {
+	// Create random sample points and queries
+	rng := rand.New(rand.NewSource(1))
+	points := make([][]float64, 300)
+	for i := range points {
+		points[i] = []float64{rng.Float64(), rng.Float64(), rng.Float64()}
+	}
+
+	for name, distance := range distanceMetrics {
+		tree := newKDTree(points, distance)
+		for q := 0; q < 20; q++ {
+			query := []float64{rng.Float64(), rng.Float64(), rng.Float64()}
+			got := tree.nearest(query, 5)
+
+			expected := make([]float64, len(points))
+			for i, point := range points {
+				expected[i] = distance(query, point)
+			}
+			sort.Float64s(expected)
+
+			for n := range got {
+				if math.Abs(got[n].distance-expected[n]) > 1e-12 {
+					t.Errorf("Unexpected %s neighbor %d distance. Expected %f, got %f", name, n, expected[n], got[n].distance)
+				}
+			}
+		}
+	}
+}
+
+func TestKNNRegressor(t *testing.T) {
+	// Create sample data on a line
+	features := [][]float64{{1}, {2}, {3}, {4}, {10}}
+	target := []float64{10, 20, 30, 40, 100}
+
+	model := newKNN(2)
+	model.standardize = false
+	if err := model.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	prediction, neighbors := model.predictWithNeighbors([]float64{2.2})
+	if prediction != 25 {
+		t.Errorf("Unexpected uniform prediction. Expected %f, got %f", 25.0, prediction)
+	}
+	if len(neighbors) != 2 || neighbors[0].row[0] != 2 || neighbors[1].row[0] != 3 {
+		t.Errorf("Unexpected neighbors: %+v", neighbors)
+	}
+
+	// Inverse distance weighting favors the closer row
+	model.weighting = "distance"
+	expected := (20/0.2 + 30/0.8) / (1/0.2 + 1/0.8)
+	if prediction := model.Predict([]float64{2.2}); math.Abs(prediction-expected) > 1e-9 {
+		t.Errorf("Unexpected distance-weighted prediction. Expected %f, got %f", expected, prediction)
+	}
+	if prediction := model.Predict([]float64{4}); prediction != 40 {
+		t.Errorf("Unexpected prediction at an exact match. Expected %f, got %f", 40.0, prediction)
+	}
+
+	model.metric = "cosine"
+	if err := model.Fit(features, target); err == nil {
+		t.Errorf("Expected an error for an unknown metric")
+	}
+}
+
+func TestKNNIgnoresZeroWeightNeighbors(t *testing.T) {
+	// The two rows closest to the queries have no weight
+	features := [][]float64{{1}, {2}, {3}, {4}, {10}}
+	target := []float64{10, 20, 30, 40, 100}
+	weights := []float64{1, 0, 0, 1, 1}
+
+	for _, weighting := range []string{"uniform", "distance"} {
+		model := newKNN(1)
+		model.standardize = false
+		model.weighting = weighting
+		if err := model.FitWeighted(features, target, weights); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		// The nearest weighted row to 2.4 is 1, and to the exact match 3 it is 4
+		if prediction := model.Predict([]float64{2.4}); prediction != 10 {
+			t.Errorf("Unexpected %s prediction. Expected %f, got %f", weighting, 10.0, prediction)
+		}
+		if prediction := model.Predict([]float64{3}); prediction != 40 {
+			t.Errorf("Unexpected %s prediction at a zero weight row. Expected %f, got %f", weighting, 40.0, prediction)
+		}
+	}
+}
@@ -23,6 +23,14 @@ func main() {
 		panic(err)
 	}
 
+	// Check data
+	fmt.Println("Loaded features:")
+	for _, row := range features {
+		fmt.Println(row)
+	}
+	fmt.Println("Loaded target:")
+	fmt.Println(target)
+
 	// Print the loaded data
 	fmt.Println("Loaded Data:")
 	for i, row := range features {
@@ -53,6 +61,16 @@ func main() {
 	// Set the regularization parameter (lambda)
 	lambda := 0.1
 
+	// Ridge Regression is fit on log(mv) and its predictions transformed back to prices,
+	// with smearing so they estimate the mean rather than the median price
+	newRidgeLog := func() regressor {
+		return &transformedTargetRegressor{
+			regressor: &ridgeModel{lambda: lambda},
+			transform: logTransform{},
+			smearing:  true,
+		}
+	}
+
 	// Set up channels to communicate the results from each Goroutine
 	sumLinearCoefficients := make([]float64, len(trainFeatures[0])+1)
 	sumRidgeCoefficients := make([]float64, len(trainFeatures[0])+1)
@@ -106,24 +124,19 @@ func main() {
 			avgPredictedPricesLiner[j] += price / float64(numIterations)
 		}
 
-		// Perform Ridge Regression
-		coefficientsRidge := ridgeRegression(trainFeatures, trainTarget, lambda)
+		// Perform Ridge Regression on log(mv)
+		ridgeLog := newRidgeLog()
+		if err := ridgeLog.Fit(trainFeatures, trainTarget); err != nil {
+			panic(err)
+		}
 
 		// Make predictions Ridge
-		predictionsRidge := make([]float64, len(testFeatures))
-		for j, row := range testFeatures {
-			predictionsRidge[j] = predictRidge(row, coefficientsRidge)
-		}
+		predictionsRidge := predictAll(ridgeLog, testFeatures)
 
 		// Add the predicted home prices to the average for each feature
 		for j, price := range predictionsRidge {
 			avgPredictedPricesRidge[j] += price / float64(numIterations)
 		}
-
-		// Take the log of the predictions Ridge
-		for i := range avgPredictedPricesRidge {
-			avgPredictedPricesRidge[i] = math.Log(avgPredictedPricesRidge[i])
-		}
 	}
 
 	// Print the predicted home prices using linear regression
@@ -170,6 +183,30 @@ func main() {
 	rmspeRidge := rootMeanSquaredPercentageError(avgPredictedPricesRidge, testTarget)
 	fmt.Printf("Root Mean Squared Percentage Error (RMSPE): %.2f%%\n", rmspeRidge)
 
+	// Combine linear and ridge regression: average their predictions, and stack or blend
+	// them under a ridge meta-regressor, since their predictions are nearly collinear
+	newBaseModels := []func() regressor{
+		func() regressor { return &linearModel{} },
+		newRidgeLog,
+	}
+	newMeta := func() regressor { return &ridgeModel{lambda: 1} }
+	ensembles := []struct {
+		name  string
+		model regressor
+	}{
+		{"Averaged", newAveragingEnsemble(nil, newBaseModels...)},
+		{"Stacked", newStackingEnsemble(newMeta, newBaseModels...)},
+		{"Blended", newBlendingEnsemble(newMeta, newBaseModels...)},
+	}
+	for _, ensemble := range ensembles {
+		if err := ensemble.model.Fit(trainFeatures, trainTarget); err != nil {
+			panic(err)
+		}
+		predictions := predictAll(ensemble.model, testFeatures)
+		fmt.Printf("%s Linear + Ridge RMSE: %.2f, MAPE: %.2f%%\n", ensemble.name,
+			rootMeanSquaredError(predictions, testTarget), meanAbsolutePercentageError(predictions, testTarget))
+	}
+
 	// Calculate the time to run func main
 	duration := time.Since(startTime)
 	fmt.Printf("Time to execute code: %s\n", duration)
@@ -221,17 +258,146 @@ func loadCSV(filename string) ([][]float64, []float64, error) {
 		}
 	}
 
-	// Check data
-	fmt.Println("Loaded features:")
-	for _, row := range features {
-		fmt.Println(row)
-	}
-	fmt.Println("Loaded target:")
-	fmt.Println(target)
 	return features, target, nil
 
 }
 
+// loadColumnNames returns the names of the feature columns in the same order loadCSV
+// returns them, i.e. the header without the first (neighborhood) and last (target) columns.
+func loadColumnNames(filename string) ([]string, error) {
+	header, err := loadHeader(filename)
+	if err != nil {
+		return nil, err
+	}
+	return header[1 : len(header)-1], nil
+}
+
+// featureName returns the name of column j, or a generic name past the end of names.
+func featureName(columnNames []string, j int) string {
+	if j < len(columnNames) {
+		return columnNames[j]
+	}
+	return fmt.Sprintf("feature_%d", j)
+}
+
+// loadHeader returns every name in the header row of filename, including the first
+// (neighborhood) and last (target) columns.
+func loadHeader(filename string) ([]string, error) {
+	basePath, err := getBasePath()
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Open(filepath.Join(basePath, filename))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: missing header row", filename)
+	}
+	header := strings.Split(scanner.Text(), ",")
+	if len(header) < 3 {
+		return nil, fmt.Errorf("%s: missing header row", filename)
+	}
+	return header, nil
+}
+
+// loadWeightedCSV loads filename like loadCSV but takes the sample weight of every row from
+// weightColumn and drops that column from the features, so the feature names are those of
+// loadColumnNames without weightColumn.
+func loadWeightedCSV(filename string, weightColumn string) ([][]float64, []float64, []float64, error) {
+	features, target, err := loadCSV(filename)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	names, err := loadColumnNames(filename)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	column := indexOf(names, weightColumn)
+	if column < 0 {
+		return nil, nil, nil, fmt.Errorf("%s: no feature column named %q", filename, weightColumn)
+	}
+
+	weights := make([]float64, len(features))
+	for i, row := range features {
+		weights[i] = row[column]
+		features[i] = append(append([]float64(nil), row[:column]...), row[column+1:]...)
+	}
+	if _, err := checkWeights(weights, len(features)); err != nil {
+		return nil, nil, nil, err
+	}
+	return features, target, weights, nil
+}
+
+// loadMultiTargetCSV loads filename like loadCSV but returns a target matrix with one
+// column per name in targetColumns, in order. A target may be the usual last column or
+// any feature column, which is then dropped from the features; the names of the remaining
+// features are returned with them.
+func loadMultiTargetCSV(filename string, targetColumns ...string) ([][]float64, [][]float64, []string, error) {
+	if len(targetColumns) == 0 {
+		return nil, nil, nil, fmt.Errorf("no target columns given")
+	}
+	features, target, err := loadCSV(filename)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	header, err := loadHeader(filename)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	names := header[1 : len(header)-1]
+	targetName := header[len(header)-1]
+
+	// -1 stands for the target column loadCSV returns separately
+	columns := make([]int, len(targetColumns))
+	isTarget := make(map[int]bool, len(targetColumns))
+	for k, name := range targetColumns {
+		columns[k] = indexOf(names, name)
+		if name == targetName {
+			columns[k] = -1
+		} else if columns[k] < 0 {
+			return nil, nil, nil, fmt.Errorf("%s: no column named %q", filename, name)
+		}
+		if isTarget[columns[k]] {
+			return nil, nil, nil, fmt.Errorf("target column %q given twice", name)
+		}
+		isTarget[columns[k]] = true
+	}
+
+	var featureNames []string
+	for j, name := range names {
+		if !isTarget[j] {
+			featureNames = append(featureNames, name)
+		}
+	}
+	targets := make([][]float64, len(features))
+	for i, row := range features {
+		targets[i] = make([]float64, len(columns))
+		for k, j := range columns {
+			if j < 0 {
+				targets[i][k] = target[i]
+			} else {
+				targets[i][k] = row[j]
+			}
+		}
+		kept := make([]float64, 0, len(featureNames))
+		for j, value := range row {
+			if !isTarget[j] {
+				kept = append(kept, value)
+			}
+		}
+		features[i] = kept
+	}
+	return features, targets, featureNames, nil
+}
+
 func parseCSV(file *os.File) ([][]string, error) {
 	lines := make([][]string, 0)
 	scanner := bufio.NewScanner(file)
@@ -267,7 +433,6 @@ func linearRegression(features [][]float64, target []float64) []float64 {
 
 func ridgeRegression(features [][]float64, target []float64, lambda float64) []float64 {
 	numFeatures := len(features[0])
-	coefficients := make([]float64, len(features[0])+1)
 
 	// Add a constant term (intercept) to the feature matrix
 	featuresWithConstant := make([][]float64, len(features))
@@ -275,44 +440,86 @@ func ridgeRegression(features [][]float64, target []float64, lambda float64) []f
 		featuresWithConstant[i] = append([]float64{1}, row...)
 	}
 
-	// Create the design matrix
-	matFeatures := mat.NewDense(len(featuresWithConstant), len(featuresWithConstant[0]), nil)
-	matFeatures.Apply(func(i, j int, v float64) float64 { return featuresWithConstant[i][j] }, matFeatures)
+	// Penalize every coefficient except the intercept: λI with a zero in the first slot
+	penalty := mat.NewDense(numFeatures+1, numFeatures+1, nil)
+	for i := 1; i < numFeatures+1; i++ {
+		penalty.Set(i, i, lambda)
+	}
 
-	// Create the target vector
-	matTarget := mat.NewVecDense(len(target), target)
+	// Compute the coefficients using ridge regression formula: inv(X^T * X + λI) * X^T * y
+	coefficients, err := penalizedRegression(featuresWithConstant, target, penalty)
+	if err != nil {
+		panic(err)
+	}
 
-	// Compute X^T * X and X^T * y
-	var xtX mat.Dense
-	xtX.Mul(matFeatures.T(), matFeatures)
+	return coefficients
+}
 
-	var xtY mat.VecDense
-	xtY.MulVec(matFeatures.T(), matTarget)
+// weightedLinearRegression solves weighted least squares with an intercept: it minimizes
+// Σ w_i (y_i - b·[1, x_i])² and returns b with the intercept first, like linearRegression.
+func weightedLinearRegression(features [][]float64, target []float64, weights []float64) ([]float64, error) {
+	return weightedRidgeRegression(features, target, weights, 0)
+}
 
-	// Create the identity matrix
-	identity := mat.NewDense(numFeatures+1, numFeatures+1, nil)
-	for i := 0; i < len(featuresWithConstant[0]); i++ {
-		identity.Set(i, i, lambda)
+// weightedRidgeRegression is ridge regression minimizing Σ w_i (y_i - b·[1, x_i])² + λ|b|²,
+// with the intercept unpenalized. Scaling every row by sqrt(w_i) turns the weighted problem
+// into an ordinary penalized one.
+func weightedRidgeRegression(features [][]float64, target []float64, weights []float64, lambda float64) ([]float64, error) {
+	numColumns := len(features[0]) + 1
+	design := make([][]float64, len(features))
+	scaledTarget := make([]float64, len(target))
+	for i, row := range features {
+		s := math.Sqrt(weights[i])
+		design[i] = make([]float64, numColumns)
+		design[i][0] = s
+		for j, v := range row {
+			design[i][j+1] = s * v
+		}
+		scaledTarget[i] = s * target[i]
 	}
 
-	// Add 1 to the diagonal elements of the identity matrix
-	for i := 1; i < numFeatures+1; i++ {
-		identity.Set(i, i, 1.0+lambda)
+	penalty := mat.NewDense(numColumns, numColumns, nil)
+	for i := 1; i < numColumns; i++ {
+		penalty.Set(i, i, lambda)
 	}
+	return penalizedRegression(design, scaledTarget, penalty)
+}
 
-	// Compute the coefficients using ridge regression formula: inv(X^T * X + λI) * X^T * y
-	var inv mat.Dense
-	inv.Inverse(identity)
+// penalizedRegression solves (X^T * X + P) * b = X^T * y for b, where X is a design
+// matrix that already contains any intercept column and P is a square penalty matrix.
+// Ridge regression uses P = λI; smoothers such as the GAM use a roughness penalty.
+func penalizedRegression(design [][]float64, target []float64, penalty *mat.Dense) ([]float64, error) {
+	numColumns := len(design[0])
+	if r, c := penalty.Dims(); r != numColumns || c != numColumns {
+		return nil, fmt.Errorf("penalty is %dx%d, want %dx%d", r, c, numColumns, numColumns)
+	}
+
+	// Create the design matrix and the target vector
+	matFeatures := mat.NewDense(len(design), numColumns, nil)
+	matFeatures.Apply(func(i, j int, v float64) float64 { return design[i][j] }, matFeatures)
+	matTarget := mat.NewVecDense(len(target), target)
 
-	var ridgeCoefficients mat.VecDense
-	ridgeCoefficients.MulVec(&inv, &xtY)
+	// Compute X^T * X + P and X^T * y
+	var lhs mat.Dense
+	lhs.Mul(matFeatures.T(), matFeatures)
+	lhs.Add(&lhs, penalty)
+
+	var xtY mat.VecDense
+	xtY.MulVec(matFeatures.T(), matTarget)
 
-	// Convert the ridgeCoefficients to a flat slice
-	for i := 0; i < numFeatures+1; i++ {
-		coefficients[i] = ridgeCoefficients.At(i, 0)
+	var solution mat.VecDense
+	if err := solution.SolveVec(&lhs, &xtY); err != nil {
+		// A finite condition number only warns about ill-conditioning; the solution is still usable
+		if cond, ok := err.(mat.Condition); !ok || math.IsInf(float64(cond), 1) {
+			return nil, err
+		}
 	}
 
-	return coefficients
+	coefficients := make([]float64, numColumns)
+	for i := range coefficients {
+		coefficients[i] = solution.AtVec(i)
+	}
+	return coefficients, nil
 }
 
 func predictLin(featureRow []float64, coefficients []float64) float64 {
@@ -395,3 +602,102 @@ func rootMeanSquaredPercentageError(predictions []float64, targets []float64) fl
 	return math.Sqrt(meanSquaredPercentageError)
 
 }
+
+// pinballLoss is the mean quantile (pinball) loss of predictions for quantile tau: rows
+// under-predicted cost tau per unit of error and rows over-predicted cost 1 - tau.
+func pinballLoss(predictions []float64, targets []float64, tau float64) float64 {
+	if len(predictions) != len(targets) {
+		panic("Predictions and targets length mismatch")
+	}
+
+	var sumLoss float64
+	for i, pred := range predictions {
+		diff := targets[i] - pred
+		if diff >= 0 {
+			sumLoss += tau * diff
+		} else {
+			sumLoss -= (1 - tau) * diff
+		}
+	}
+
+	return sumLoss / float64(len(predictions))
+}
+
+// weightedMeanAbsolutePercentageError is meanAbsolutePercentageError with every row's error
+// counted weights[i] times.
+func weightedMeanAbsolutePercentageError(predictions []float64, targets []float64, weights []float64) float64 {
+	if len(predictions) != len(targets) || len(predictions) != len(weights) {
+		panic("Predictions, targets and weights length mismatch")
+	}
+
+	var sumPercentageError, sumWeights float64
+	for i, pred := range predictions {
+		percentageError := math.Abs((pred - targets[i]) / targets[i])
+		sumPercentageError += weights[i] * percentageError
+		sumWeights += weights[i]
+	}
+
+	return sumPercentageError / sumWeights
+}
+
+// weightedMeanSquaredError is meanSquaredError with every row's error counted weights[i]
+// times.
+func weightedMeanSquaredError(predictions []float64, targets []float64, weights []float64) float64 {
+	if len(predictions) != len(targets) || len(predictions) != len(weights) {
+		panic("Predictions, targets and weights length mismatch")
+	}
+
+	var sumSquaredError, sumWeights float64
+	for i, pred := range predictions {
+		diff := pred - targets[i]
+		sumSquaredError += weights[i] * diff * diff
+		sumWeights += weights[i]
+	}
+
+	return sumSquaredError / sumWeights
+}
+
+func weightedRootMeanSquaredError(predictions []float64, targets []float64, weights []float64) float64 {
+	return math.Sqrt(weightedMeanSquaredError(predictions, targets, weights))
+}
+
+func weightedRootMeanSquaredPercentageError(predictions []float64, targets []float64, weights []float64) float64 {
+	if len(predictions) != len(targets) || len(predictions) != len(weights) {
+		panic("Predictions, targets and weights length mismatch")
+	}
+
+	var sumSquaredPercentageError, sumWeights float64
+	for i, pred := range predictions {
+		percentageError := (pred - targets[i]) / targets[i]
+		sumSquaredPercentageError += weights[i] * percentageError * percentageError
+		sumWeights += weights[i]
+	}
+
+	return math.Sqrt(sumSquaredPercentageError / sumWeights)
+}
+
+// meanAbsoluteError is the mean of |prediction - target|.
+func meanAbsoluteError(predictions []float64, targets []float64) float64 {
+	if len(predictions) != len(targets) {
+		panic("Predictions and targets length mismatch")
+	}
+	var sum float64
+	for i, prediction := range predictions {
+		sum += math.Abs(prediction - targets[i])
+	}
+	return sum / float64(len(predictions))
+}
+
+// rSquared is the coefficient of determination, 1 - RSS / TSS.
+func rSquared(predictions []float64, targets []float64) float64 {
+	if len(predictions) != len(targets) {
+		panic("Predictions and targets length mismatch")
+	}
+	mean := floats.Sum(targets) / float64(len(targets))
+	var rss, tss float64
+	for i, prediction := range predictions {
+		rss += (targets[i] - prediction) * (targets[i] - prediction)
+		tss += (targets[i] - mean) * (targets[i] - mean)
+	}
+	return 1 - rss/tss
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// mixedModel is a linear mixed model y = Xb + Zu + e for rows grouped by a categorical
+// column such as neighborhood. Every group g gets a random intercept, and optionally
+// random slopes on some features, u_g ~ N(0, G), on top of the fixed effects b, with
+// independent noise e ~ N(0, σ²).
+//
+// Fit maximizes the restricted likelihood (REML) over the relative covariance factor L,
+// G = σ² L Lᵀ, with nelderMead; b and σ² are profiled out in closed form. Groups with few
+// rows get BLUPs shrunk toward zero, so their predictions borrow strength from the rest
+// of the data, and rows of unseen groups are predicted by the fixed effects alone.
+type mixedModel struct {
+	randomSlopes   []int // feature columns with a random slope, besides the intercept
+	maxEvaluations int
+
+	fixedEffects        []float64 // intercept first, like linearRegression
+	fixedStandardErrors []float64
+	groupCovariance     *mat.SymDense // G, random intercept first then the slopes
+	residualVariance    float64       // σ²
+	blups               map[string][]float64
+	groupSizes          map[string]int
+	remlLogLikelihood   float64
+}
+
+// mixedGroup holds the cross products of one group's random effects design Z with itself,
+// the fixed effects design X and the target.
+type mixedGroup struct {
+	name string
+	size int
+	ztz  *mat.SymDense
+	ztx  *mat.Dense
+	zty  *mat.VecDense
+}
+
+// mixedProfile is the REML fit for one value of the covariance factor.
+type mixedProfile struct {
+	criterion        float64 // -2 × REML log-likelihood
+	fixedEffects     *mat.VecDense
+	residualVariance float64
+	information      mat.Cholesky // of XᵀH⁻¹X
+	groupSystems     []mat.Cholesky
+}
+
+// newMixedModel returns a mixedModel with random intercepts and random slopes on the
+// given feature columns.
+func newMixedModel(randomSlopes ...int) *mixedModel {
+	return &mixedModel{randomSlopes: randomSlopes, maxEvaluations: 2000}
+}
+
+// fit estimates the model from features, target and the group of every row.
+func (m *mixedModel) fit(features [][]float64, target []float64, groups []string) error {
+	return m.fitWeighted(features, target, groups, nil)
+}
+
+// fitWeighted treats the weights as frequencies: they multiply every cross product, and
+// the total weight takes the place of the number of rows in the REML criterion. Rows with
+// zero weight do not count towards their group.
+func (m *mixedModel) fitWeighted(features [][]float64, target []float64, groups []string, weights []float64) error {
+	if len(features) != len(target) || len(groups) != len(target) {
+		return fmt.Errorf("features, target and groups length mismatch: %d, %d and %d", len(features), len(target), len(groups))
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	numFixed := len(features[0]) + 1
+	n := floats.Sum(weights)
+	if n <= float64(numFixed) {
+		return fmt.Errorf("need a total weight above %d, got %g", numFixed, n)
+	}
+	for _, j := range m.randomSlopes {
+		if j < 0 || j >= len(features[0]) {
+			return fmt.Errorf("random slope column %d out of range", j)
+		}
+	}
+	numRandom := 1 + len(m.randomSlopes)
+
+	rowsByGroup := make(map[string][]int)
+	for i, g := range groups {
+		if weights[i] > 0 {
+			rowsByGroup[g] = append(rowsByGroup[g], i)
+		}
+	}
+	names := make([]string, 0, len(rowsByGroup))
+	for name := range rowsByGroup {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// The likelihood only needs cross products, so accumulate them once
+	xtx := mat.NewSymDense(numFixed, nil)
+	xty := mat.NewVecDense(numFixed, nil)
+	var yty float64
+	groupStats := make([]mixedGroup, len(names))
+	m.groupSizes = make(map[string]int, len(names))
+	for k, name := range names {
+		group := mixedGroup{
+			name: name,
+			size: len(rowsByGroup[name]),
+			ztz:  mat.NewSymDense(numRandom, nil),
+			ztx:  mat.NewDense(numRandom, numFixed, nil),
+			zty:  mat.NewVecDense(numRandom, nil),
+		}
+		for _, i := range rowsByGroup[name] {
+			x := mat.NewVecDense(numFixed, append([]float64{1}, features[i]...))
+			z := mat.NewVecDense(numRandom, m.randomRow(features[i]))
+			w := weights[i]
+			xtx.SymRankOne(xtx, w, x)
+			xty.AddScaledVec(xty, w*target[i], x)
+			yty += w * target[i] * target[i]
+			group.ztz.SymRankOne(group.ztz, w, z)
+			group.ztx.RankOne(group.ztx, w, z, x)
+			group.zty.AddScaledVec(group.zty, w*target[i], z)
+		}
+		groupStats[k] = group
+		m.groupSizes[name] = group.size
+	}
+
+	// Search the lower triangle of L, starting from random effects as large as the noise
+	start := make([]float64, numRandom*(numRandom+1)/2)
+	for r := 0; r < numRandom; r++ {
+		start[r*(r+1)/2+r] = 1
+	}
+	best := nelderMead(func(theta []float64) float64 {
+		profile, err := remlProfile(lowerTriangular(theta, numRandom), groupStats, xtx, xty, yty, n)
+		if err != nil {
+			return math.Inf(1)
+		}
+		return profile.criterion
+	}, start, m.maxEvaluations)
+
+	factor := lowerTriangular(best, numRandom)
+	profile, err := remlProfile(factor, groupStats, xtx, xty, yty, n)
+	if err != nil {
+		return err
+	}
+
+	m.fixedEffects = profile.fixedEffects.RawVector().Data
+	m.residualVariance = profile.residualVariance
+	m.remlLogLikelihood = -profile.criterion / 2
+	var informationInverse mat.SymDense
+	if err := profile.information.InverseTo(&informationInverse); err != nil {
+		return err
+	}
+	m.fixedStandardErrors = make([]float64, numFixed)
+	for j := range m.fixedStandardErrors {
+		m.fixedStandardErrors[j] = math.Sqrt(m.residualVariance * informationInverse.At(j, j))
+	}
+
+	var covariance mat.Dense
+	covariance.Mul(factor, factor.T())
+	m.groupCovariance = mat.NewSymDense(numRandom, nil)
+	for r := 0; r < numRandom; r++ {
+		for c := r; c < numRandom; c++ {
+			m.groupCovariance.SetSym(r, c, m.residualVariance*covariance.At(r, c))
+		}
+	}
+
+	// û_g = L (I + LᵀZᵀZL)⁻¹ Lᵀ Zᵀ(y - Xb)
+	m.blups = make(map[string][]float64, len(names))
+	for k, group := range groupStats {
+		residual := mat.NewVecDense(numRandom, nil)
+		residual.MulVec(group.ztx, profile.fixedEffects)
+		residual.SubVec(group.zty, residual)
+		residual.MulVec(factor.T(), residual)
+		var solved mat.VecDense
+		if err := profile.groupSystems[k].SolveVecTo(&solved, residual); err != nil {
+			return err
+		}
+		blup := mat.NewVecDense(numRandom, nil)
+		blup.MulVec(factor, &solved)
+		m.blups[group.name] = blup.RawVector().Data
+	}
+	return nil
+}
+
+// remlProfile evaluates -2 × the REML log-likelihood at covariance factor L, with
+// H_g = I + Z_g L Lᵀ Z_gᵀ handled through the q×q matrices I + Lᵀ Z_gᵀZ_g L:
+//
+//	log|H_g| + log|XᵀH⁻¹X| + (n - p)(1 + log(2π σ̂²)), σ̂² = (yᵀH⁻¹y - b̂ᵀXᵀH⁻¹y) / (n - p)
+func remlProfile(factor *mat.Dense, groups []mixedGroup, xtx *mat.SymDense, xty *mat.VecDense, yty float64, n float64) (mixedProfile, error) {
+	numRandom, _ := factor.Dims()
+	numFixed := xtx.SymmetricDim()
+	profile := mixedProfile{groupSystems: make([]mat.Cholesky, len(groups))}
+
+	xhx := mat.NewSymDense(numFixed, nil)
+	xhx.CopySym(xtx)
+	xhy := mat.VecDenseCopyOf(xty)
+	yhy := yty
+	var logDetH float64
+	for k, group := range groups {
+		// A = I + Lᵀ ZᵀZ L
+		var product mat.Dense
+		product.Product(factor.T(), group.ztz, factor)
+		system := mat.NewSymDense(numRandom, nil)
+		for r := 0; r < numRandom; r++ {
+			for c := r; c < numRandom; c++ {
+				system.SetSym(r, c, (product.At(r, c)+product.At(c, r))/2)
+			}
+			system.SetSym(r, r, system.At(r, r)+1)
+		}
+		if ok := profile.groupSystems[k].Factorize(system); !ok {
+			return profile, fmt.Errorf("group %q: covariance system is not positive definite", group.name)
+		}
+		logDetH += profile.groupSystems[k].LogDet()
+
+		// Woodbury: H⁻¹ = I - Z L A⁻¹ Lᵀ Zᵀ
+		var b mat.Dense
+		b.Mul(factor.T(), group.ztx)
+		var c mat.VecDense
+		c.MulVec(factor.T(), group.zty)
+		var solvedB mat.Dense
+		if err := profile.groupSystems[k].SolveTo(&solvedB, &b); err != nil {
+			return profile, err
+		}
+		var solvedC mat.VecDense
+		if err := profile.groupSystems[k].SolveVecTo(&solvedC, &c); err != nil {
+			return profile, err
+		}
+		var correction mat.Dense
+		correction.Mul(b.T(), &solvedB)
+		for r := 0; r < numFixed; r++ {
+			for s := r; s < numFixed; s++ {
+				xhx.SetSym(r, s, xhx.At(r, s)-(correction.At(r, s)+correction.At(s, r))/2)
+			}
+		}
+		var cross mat.VecDense
+		cross.MulVec(b.T(), &solvedC)
+		xhy.SubVec(xhy, &cross)
+		yhy -= mat.Dot(&c, &solvedC)
+	}
+
+	if ok := profile.information.Factorize(xhx); !ok {
+		return profile, fmt.Errorf("fixed effects design is singular")
+	}
+	profile.fixedEffects = mat.NewVecDense(numFixed, nil)
+	if err := profile.information.SolveVecTo(profile.fixedEffects, xhy); err != nil {
+		return profile, err
+	}
+	degreesOfFreedom := n - float64(numFixed)
+	rss := yhy - mat.Dot(profile.fixedEffects, xhy)
+	if rss <= 0 {
+		return profile, fmt.Errorf("residual sum of squares is not positive")
+	}
+	profile.residualVariance = rss / degreesOfFreedom
+	profile.criterion = logDetH + profile.information.LogDet() + degreesOfFreedom*(1+math.Log(2*math.Pi*profile.residualVariance))
+	return profile, nil
+}
+
+// lowerTriangular fills a size×size lower triangular matrix row by row from theta.
+func lowerTriangular(theta []float64, size int) *mat.Dense {
+	factor := mat.NewDense(size, size, nil)
+	k := 0
+	for r := 0; r < size; r++ {
+		for c := 0; c <= r; c++ {
+			factor.Set(r, c, theta[k])
+			k++
+		}
+	}
+	return factor
+}
+
+// randomRow returns the random effects design row: 1 followed by the random slope columns.
+func (m *mixedModel) randomRow(featureRow []float64) []float64 {
+	z := []float64{1}
+	for _, j := range m.randomSlopes {
+		z = append(z, featureRow[j])
+	}
+	return z
+}
+
+// Predict returns the population-level prediction from the fixed effects, which is also
+// the prediction for a group not seen in training.
+func (m *mixedModel) Predict(featureRow []float64) float64 {
+	return predictLin(featureRow, m.fixedEffects)
+}
+
+// predictGroup adds the BLUP of group to the fixed effects prediction, or returns the
+// population-level prediction when the group was not seen in training.
+func (m *mixedModel) predictGroup(featureRow []float64, group string) float64 {
+	prediction := m.Predict(featureRow)
+	blup, ok := m.blups[group]
+	if !ok {
+		return prediction
+	}
+	for k, z := range m.randomRow(featureRow) {
+		prediction += blup[k] * z
+	}
+	return prediction
+}
+
+// predictGroups runs predictGroup on every row.
+func (m *mixedModel) predictGroups(features [][]float64, groups []string) []float64 {
+	if len(features) != len(groups) {
+		panic("Features and groups length mismatch")
+	}
+	predictions := make([]float64, len(features))
+	for i, row := range features {
+		predictions[i] = m.predictGroup(row, groups[i])
+	}
+	return predictions
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestMixedModelMatchesBalancedANOVA(t *testing.T) {
+	// Create a balanced one-way layout: 8 groups of 5 rows with a feature that has no effect
+	rng := rand.New(rand.NewSource(1))
+	var features [][]float64
+	var target []float64
+	var groups []string
+	const numGroups, groupSize = 8, 5
+	for g := 0; g < numGroups; g++ {
+		effect := 2 * rng.NormFloat64()
+		for k := 0; k < groupSize; k++ {
+			features = append(features, []float64{float64(k)})
+			target = append(target, 10+effect+rng.NormFloat64())
+			groups = append(groups, fmt.Sprintf("town%d", g))
+		}
+	}
+
+	model := newMixedModel()
+	if err := model.fit(features, target, groups); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// With a balanced design, REML gives σ² = MSW and the group variance (MSB - MSW) / r,
+	// computed here after removing the fixed effect of the feature with OLS
+	coefficients := linearRegression(features, target)
+	residuals := make([]float64, len(target))
+	linearResiduals(features, target, coefficients, residuals)
+	var within, between float64
+	for g := 0; g < numGroups; g++ {
+		var mean float64
+		for k := 0; k < groupSize; k++ {
+			mean += residuals[g*groupSize+k] / groupSize
+		}
+		for k := 0; k < groupSize; k++ {
+			d := residuals[g*groupSize+k] - mean
+			within += d * d
+		}
+		between += groupSize * mean * mean
+	}
+	msw := within / float64(numGroups*(groupSize-1)-1)
+	msb := between / float64(numGroups-1)
+	if math.Abs(model.residualVariance-msw) > 0.01*msw {
+		t.Errorf("Unexpected residual variance. Expected %f, got %f", msw, model.residualVariance)
+	}
+	if expected := (msb - msw) / groupSize; math.Abs(model.groupCovariance.At(0, 0)-expected) > 0.01*expected {
+		t.Errorf("Unexpected group variance. Expected %f, got %f", expected, model.groupCovariance.At(0, 0))
+	}
+	if math.Abs(model.fixedEffects[0]-10) > 2 {
+		t.Errorf("Unexpected fixed intercept. Expected about 10, got %f", model.fixedEffects[0])
+	}
+}
+
+func TestMixedModelRandomSlopesAndUnseenGroups(t *testing.T) {
+	// Create groups whose slopes on the feature differ
+	rng := rand.New(rand.NewSource(2))
+	var features [][]float64
+	var target []float64
+	var groups []string
+	for g := 0; g < 15; g++ {
+		intercept, slope := rng.NormFloat64(), 0.5*rng.NormFloat64()
+		for k := 0; k < 12; k++ {
+			x := rng.Float64() * 4
+			features = append(features, []float64{x})
+			target = append(target, 3+intercept+(2+slope)*x+0.2*rng.NormFloat64())
+			groups = append(groups, fmt.Sprintf("region%d", g))
+		}
+	}
+
+	model := newMixedModel(0)
+	if err := model.fit(features, target, groups); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if model.groupCovariance.At(1, 1) < 0.05 {
+		t.Errorf("Unexpected slope variance. Expected about 0.25, got %f", model.groupCovariance.At(1, 1))
+	}
+	if len(model.blups["region0"]) != 2 {
+		t.Fatalf("Unexpected BLUP length. Expected 2, got %d", len(model.blups["region0"]))
+	}
+
+	// Known groups use their BLUPs; an unseen group gets the fixed effects only
+	withGroups := rootMeanSquaredError(model.predictGroups(features, groups), target)
+	if withGroups > 0.3 {
+		t.Errorf("Unexpected RMSE with group effects. Expected at most 0.3, got %f", withGroups)
+	}
+	row := []float64{1.5}
+	if got, expected := model.predictGroup(row, "unseen"), model.Predict(row); got != expected {
+		t.Errorf("Unexpected prediction for an unseen group. Expected %f, got %f", expected, got)
+	}
+}
+
+func TestMixedModelWeightsMatchRepeatedRows(t *testing.T) {
+	// Create a one-way layout with integer weights, and the same rows repeated weight times
+	rng := rand.New(rand.NewSource(3))
+	var features, repeatedFeatures [][]float64
+	var target, weights, repeatedTarget []float64
+	var groups, repeatedGroups []string
+	for g := 0; g < 6; g++ {
+		effect := rng.NormFloat64()
+		for k := 0; k < 6; k++ {
+			row := []float64{float64(k)}
+			y := 5 + effect + 0.5*float64(k) + 0.3*rng.NormFloat64()
+			w := float64((g + k) % 3)
+			features, target, weights = append(features, row), append(target, y), append(weights, w)
+			groups = append(groups, fmt.Sprintf("town%d", g))
+			for r := 0; r < int(w); r++ {
+				repeatedFeatures, repeatedTarget = append(repeatedFeatures, row), append(repeatedTarget, y)
+				repeatedGroups = append(repeatedGroups, fmt.Sprintf("town%d", g))
+			}
+		}
+	}
+
+	weighted, repeated := newMixedModel(), newMixedModel()
+	if err := weighted.fitWeighted(features, target, groups, weights); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := repeated.fit(repeatedFeatures, repeatedTarget, repeatedGroups); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for j, expected := range repeated.fixedEffects {
+		if math.Abs(weighted.fixedEffects[j]-expected) > 1e-4 {
+			t.Errorf("Unexpected fixed effect %d. Expected %f, got %f", j, expected, weighted.fixedEffects[j])
+		}
+	}
+	if math.Abs(weighted.residualVariance-repeated.residualVariance) > 1e-4 {
+		t.Errorf("Unexpected residual variance. Expected %f, got %f", repeated.residualVariance, weighted.residualVariance)
+	}
+	for name, blup := range repeated.blups {
+		if math.Abs(weighted.blups[name][0]-blup[0]) > 1e-4 {
+			t.Errorf("Unexpected BLUP for %s. Expected %f, got %f", name, blup[0], weighted.blups[name][0])
+		}
+	}
+}
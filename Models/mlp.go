@@ -0,0 +1,322 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	"gonum.org/v1/gonum/floats"
+	"gonum.org/v1/gonum/mat"
+)
+
+// mlpRegressor is a fully connected feed-forward neural network with a linear output unit,
+// trained on squared loss with Adam, mini-batches, L2 weight decay and early stopping on
+// a validation split. Features and target are standardized internally.
+//
+// Every mini-batch is split into numShards shards whose gradients are computed through
+// runTasks and then summed.
+type mlpRegressor struct {
+	hiddenLayers       []int  // units per hidden layer
+	activation         string // "relu" or "tanh"
+	learningRate       float64
+	batchSize          int
+	epochs             int
+	l2                 float64 // weight decay on the weights, not the biases
+	validationFraction float64 // rows held out for early stopping, 0 disables it
+	patience           int     // epochs without validation improvement before stopping
+	numShards          int
+	seed               int64
+
+	scaler                standardScaler
+	targetMean, targetStd float64
+	weights               []*mat.Dense // weights[l] maps layer l to layer l+1
+	biases                [][]float64
+	// validationLoss is the validation MSE (standardized scale) after every epoch
+	validationLoss []float64
+}
+
+// mlpGradients holds one gradient (or Adam moment) per weight matrix and bias vector.
+type mlpGradients struct {
+	weights []*mat.Dense
+	biases  [][]float64
+}
+
+// newMLP returns an mlpRegressor with the given hidden layer sizes and commonly used
+// defaults.
+func newMLP(hiddenLayers ...int) *mlpRegressor {
+	return &mlpRegressor{
+		hiddenLayers:       hiddenLayers,
+		activation:         "relu",
+		learningRate:       1e-3,
+		batchSize:          32,
+		epochs:             500,
+		l2:                 1e-4,
+		validationFraction: 0.1,
+		patience:           20,
+		numShards:          4,
+		seed:               1,
+	}
+}
+
+func (m *mlpRegressor) Fit(features [][]float64, target []float64) error {
+	return m.FitWeighted(features, target, nil)
+}
+
+// FitWeighted trains on the weighted mean squared loss: each mini-batch gradient is
+// normalized by the batch's total weight, and the validation loss is weighted too.
+func (m *mlpRegressor) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	if m.activation != "relu" && m.activation != "tanh" {
+		return fmt.Errorf("unknown activation %q", m.activation)
+	}
+	if m.batchSize < 1 || m.numShards < 1 {
+		return fmt.Errorf("batchSize and numShards must be positive")
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	rng := rand.New(rand.NewSource(m.seed))
+
+	m.scaler = fitScaler(features)
+	scaled := m.scaler.transformAll(features)
+	m.targetMean, m.targetStd = weightedMeanStdDev(target, weights)
+	if m.targetStd == 0 {
+		m.targetStd = 1
+	}
+	normalized := make([]float64, len(target))
+	for i, y := range target {
+		normalized[i] = (y - m.targetMean) / m.targetStd
+	}
+
+	order := rng.Perm(len(scaled))
+	numValidation := 0
+	if m.validationFraction > 0 && m.patience > 0 {
+		numValidation = int(m.validationFraction * float64(len(scaled)))
+	}
+	validationRows := order[:numValidation]
+	trainRows := order[numValidation:]
+	if len(trainRows) == 0 {
+		return fmt.Errorf("no training rows left after the validation split")
+	}
+
+	// He initialization suits ReLU, Glorot initialization suits tanh
+	sizes := append(append([]int{len(scaled[0])}, m.hiddenLayers...), 1)
+	m.weights = make([]*mat.Dense, len(sizes)-1)
+	m.biases = make([][]float64, len(sizes)-1)
+	for l := range m.weights {
+		scale := math.Sqrt(2 / float64(sizes[l]))
+		if m.activation == "tanh" {
+			scale = math.Sqrt(1 / float64(sizes[l]))
+		}
+		data := make([]float64, sizes[l+1]*sizes[l])
+		for k := range data {
+			data[k] = rng.NormFloat64() * scale
+		}
+		m.weights[l] = mat.NewDense(sizes[l+1], sizes[l], data)
+		m.biases[l] = make([]float64, sizes[l+1])
+	}
+
+	firstMoment, secondMoment := m.zeroGradients(), m.zeroGradients()
+	const beta1, beta2, adamEpsilon = 0.9, 0.999, 1e-8
+	step := 0
+
+	m.validationLoss = nil
+	bestLoss := math.Inf(1)
+	var bestWeights []*mat.Dense
+	var bestBiases [][]float64
+	epochsWithoutImprovement := 0
+
+	for epoch := 0; epoch < m.epochs; epoch++ {
+		rng.Shuffle(len(trainRows), func(a, b int) { trainRows[a], trainRows[b] = trainRows[b], trainRows[a] })
+
+		for start := 0; start < len(trainRows); start += m.batchSize {
+			end := start + m.batchSize
+			if end > len(trainRows) {
+				end = len(trainRows)
+			}
+			batch := trainRows[start:end]
+			gradients := m.batchGradients(scaled, normalized, weights, batch)
+
+			// Adam update with bias-corrected moments
+			step++
+			correction1 := 1 - math.Pow(beta1, float64(step))
+			correction2 := 1 - math.Pow(beta2, float64(step))
+			update := func(param, grad, first, second []float64) {
+				for k := range param {
+					first[k] = beta1*first[k] + (1-beta1)*grad[k]
+					second[k] = beta2*second[k] + (1-beta2)*grad[k]*grad[k]
+					param[k] -= m.learningRate * (first[k] / correction1) / (math.Sqrt(second[k]/correction2) + adamEpsilon)
+				}
+			}
+			for l := range m.weights {
+				update(m.weights[l].RawMatrix().Data, gradients.weights[l].RawMatrix().Data,
+					firstMoment.weights[l].RawMatrix().Data, secondMoment.weights[l].RawMatrix().Data)
+				update(m.biases[l], gradients.biases[l], firstMoment.biases[l], secondMoment.biases[l])
+			}
+		}
+
+		if numValidation == 0 {
+			continue
+		}
+		var loss, validationWeight float64
+		for _, i := range validationRows {
+			diff := m.forward(scaled[i], nil, nil) - normalized[i]
+			loss += weights[i] * diff * diff
+			validationWeight += weights[i]
+		}
+		if validationWeight > 0 {
+			loss /= validationWeight
+		}
+		m.validationLoss = append(m.validationLoss, loss)
+		if loss < bestLoss {
+			bestLoss = loss
+			bestWeights, bestBiases = m.copyParameters()
+			epochsWithoutImprovement = 0
+		} else if epochsWithoutImprovement++; epochsWithoutImprovement >= m.patience {
+			break
+		}
+	}
+
+	if bestWeights != nil {
+		m.weights, m.biases = bestWeights, bestBiases
+	}
+	return nil
+}
+
+func (m *mlpRegressor) Predict(featureRow []float64) float64 {
+	return m.targetMean + m.targetStd*m.forward(m.scaler.transform(featureRow), nil, nil)
+}
+
+// forward runs the network on a scaled feature row. When activations and preActivations
+// are non-nil they receive the output of every layer and the input of every activation,
+// for use in backpropagation.
+func (m *mlpRegressor) forward(featureRow []float64, activations, preActivations [][]float64) float64 {
+	current := featureRow
+	if activations != nil {
+		activations[0] = current
+	}
+	for l, w := range m.weights {
+		rows, _ := w.Dims()
+		next := make([]float64, rows)
+		nextVec := mat.NewVecDense(rows, next)
+		nextVec.MulVec(w, mat.NewVecDense(len(current), current))
+		floats.Add(next, m.biases[l])
+		if l < len(m.weights)-1 {
+			if preActivations != nil {
+				preActivations[l+1] = append([]float64(nil), next...)
+			}
+			for k, z := range next {
+				next[k] = m.activate(z)
+			}
+		}
+		if activations != nil {
+			activations[l+1] = next
+		}
+		current = next
+	}
+	return current[0]
+}
+
+func (m *mlpRegressor) activate(z float64) float64 {
+	if m.activation == "tanh" {
+		return math.Tanh(z)
+	}
+	return math.Max(z, 0)
+}
+
+func (m *mlpRegressor) activationDerivative(z float64) float64 {
+	if m.activation == "tanh" {
+		t := math.Tanh(z)
+		return 1 - t*t
+	}
+	if z > 0 {
+		return 1
+	}
+	return 0
+}
+
+// batchGradients returns the gradient of the weighted mean squared loss over batch plus
+// the L2 penalty, computing the backpropagation for each shard of the batch in its own
+// task.
+func (m *mlpRegressor) batchGradients(features [][]float64, target []float64, weights []float64, batch []int) mlpGradients {
+	numShards := m.numShards
+	if numShards > len(batch) {
+		numShards = len(batch)
+	}
+	shards := make([]mlpGradients, numShards)
+	runTasks(numShards, func(s int) {
+		shards[s] = m.zeroGradients()
+		numLayers := len(m.weights) + 1
+		activations := make([][]float64, numLayers)
+		preActivations := make([][]float64, numLayers)
+		for _, i := range batch[s*len(batch)/numShards : (s+1)*len(batch)/numShards] {
+			output := m.forward(features[i], activations, preActivations)
+
+			// ½w(ŷ - y)² has derivative w(ŷ - y) at the linear output
+			delta := []float64{weights[i] * (output - target[i])}
+			for l := len(m.weights) - 1; l >= 0; l-- {
+				shards[s].weights[l].RankOne(shards[s].weights[l], 1, mat.NewVecDense(len(delta), delta), mat.NewVecDense(len(activations[l]), activations[l]))
+				floats.Add(shards[s].biases[l], delta)
+				if l == 0 {
+					break
+				}
+				var back mat.VecDense
+				back.MulVec(m.weights[l].T(), mat.NewVecDense(len(delta), delta))
+				delta = make([]float64, back.Len())
+				for k := range delta {
+					delta[k] = back.AtVec(k) * m.activationDerivative(preActivations[l][k])
+				}
+			}
+		}
+	})
+
+	total := shards[0]
+	for _, shard := range shards[1:] {
+		for l := range total.weights {
+			total.weights[l].Add(total.weights[l], shard.weights[l])
+			floats.Add(total.biases[l], shard.biases[l])
+		}
+	}
+	var batchWeight float64
+	for _, i := range batch {
+		batchWeight += weights[i]
+	}
+	if batchWeight == 0 {
+		batchWeight = 1
+	}
+	for l := range total.weights {
+		total.weights[l].Scale(1/batchWeight, total.weights[l])
+		total.weights[l].Add(total.weights[l], scaledDense(m.l2, m.weights[l]))
+		floats.Scale(1/batchWeight, total.biases[l])
+	}
+	return total
+}
+
+func (m *mlpRegressor) zeroGradients() mlpGradients {
+	g := mlpGradients{weights: make([]*mat.Dense, len(m.weights)), biases: make([][]float64, len(m.biases))}
+	for l, w := range m.weights {
+		rows, cols := w.Dims()
+		g.weights[l] = mat.NewDense(rows, cols, nil)
+		g.biases[l] = make([]float64, len(m.biases[l]))
+	}
+	return g
+}
+
+func (m *mlpRegressor) copyParameters() ([]*mat.Dense, [][]float64) {
+	weights := make([]*mat.Dense, len(m.weights))
+	biases := make([][]float64, len(m.biases))
+	for l := range m.weights {
+		weights[l] = mat.DenseCopyOf(m.weights[l])
+		biases[l] = append([]float64(nil), m.biases[l]...)
+	}
+	return weights, biases
+}
+
+func scaledDense(factor float64, a *mat.Dense) *mat.Dense {
+	var scaled mat.Dense
+	scaled.Scale(factor, a)
+	return &scaled
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+func TestMLPFitsNonlinearTarget(t *testing.T) {
+	// Create sample data with a quadratic effect and an interaction
+	var features [][]float64
+	var target []float64
+	for i := 0; i < 20; i++ {
+		for j := 0; j < 10; j++ {
+			x1, x2 := float64(i)/10-1, float64(j)/5-1
+			features = append(features, []float64{x1, x2})
+			target = append(target, x1*x1+x1*x2)
+		}
+	}
+
+	for _, activation := range []string{"relu", "tanh"} {
+		model := newMLP(16, 16)
+		model.activation = activation
+		model.learningRate = 0.01
+		model.epochs = 300
+		if err := model.Fit(features, target); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+
+		predictions := predictAll(model, features)
+		if rmse := rootMeanSquaredError(predictions, target); rmse > 0.1 {
+			t.Errorf("Unexpected %s training RMSE. Expected at most 0.1, got %f", activation, rmse)
+		}
+	}
+}
+
+func TestMLPGradientsMatchFiniteDifferences(t *testing.T) {
+	// Create a tiny network and data set to check backpropagation numerically
+	features := [][]float64{{0.5, -1}, {1.5, 0.2}, {-0.3, 0.8}}
+	target := []float64{1, -0.5, 0.25}
+
+	model := newMLP(3)
+	model.activation = "tanh"
+	model.epochs = 1
+	model.validationFraction = 0
+	model.l2 = 0.01
+	if err := model.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	batch := []int{0, 1, 2}
+	weights := []float64{1, 2, 0.5}
+	gradients := model.batchGradients(features, target, weights, batch)
+
+	loss := func() float64 {
+		var sum, penalty float64
+		for _, i := range batch {
+			diff := model.forward(features[i], nil, nil) - target[i]
+			sum += weights[i] * diff * diff / 2
+		}
+		for _, w := range model.weights {
+			for _, v := range w.RawMatrix().Data {
+				penalty += v * v / 2
+			}
+		}
+		return sum/floats.Sum(weights) + model.l2*penalty
+	}
+
+	const h = 1e-6
+	for l, w := range model.weights {
+		data := w.RawMatrix().Data
+		for k := range data {
+			original := data[k]
+			data[k] = original + h
+			plus := loss()
+			data[k] = original - h
+			minus := loss()
+			data[k] = original
+
+			numeric := (plus - minus) / (2 * h)
+			analytic := gradients.weights[l].RawMatrix().Data[k]
+			if math.Abs(numeric-analytic) > 1e-5 {
+				t.Errorf("Unexpected gradient for layer %d weight %d. Expected %f, got %f", l, k, numeric, analytic)
+			}
+		}
+	}
+}
@@ -0,0 +1,10 @@
+package main
+
+// runTasks calls task for every index from 0 to n-1, one after another. The concurrency
+// version of this program runs the same tasks in parallel goroutines instead, so tasks
+// must only write to state owned by their own index.
+func runTasks(n int, task func(i int)) {
+	for i := 0; i < n; i++ {
+		task(i)
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// collinearData returns rows whose first three features are noisy copies of one latent
+// variable that drives the target, plus a fourth feature of pure noise.
+func collinearData(n int) ([][]float64, []float64) {
+	rng := rand.New(rand.NewSource(3))
+	var features [][]float64
+	var target []float64
+	for i := 0; i < n; i++ {
+		latent := rng.NormFloat64()
+		features = append(features, []float64{
+			latent + 0.05*rng.NormFloat64(),
+			2*latent + 0.05*rng.NormFloat64(),
+			-latent + 0.05*rng.NormFloat64(),
+			rng.NormFloat64(),
+		})
+		target = append(target, 5+3*latent+0.3*rng.NormFloat64())
+	}
+	return features, target
+}
+
+func TestComponentRegressionWithAllComponentsMatchesOLS(t *testing.T) {
+	features, target := noisyLinearData(100)
+	expected := linearRegression(features, target)
+
+	pcr := &pcrRegressor{numComponents: 3}
+	if err := pcr.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	pls := &plsRegressor{numComponents: 3}
+	if err := pls.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for j := range expected {
+		if math.Abs(pcr.coefficients[j]-expected[j]) > 1e-8 {
+			t.Errorf("Unexpected PCR coefficient %d. Expected %f, got %f", j, expected[j], pcr.coefficients[j])
+		}
+		if math.Abs(pls.coefficients[j]-expected[j]) > 1e-8 {
+			t.Errorf("Unexpected PLS coefficient %d. Expected %f, got %f", j, expected[j], pls.coefficients[j])
+		}
+	}
+	if err := (&pcrRegressor{numComponents: 4}).Fit(features, target); err == nil {
+		t.Errorf("Expected an error for more components than features")
+	}
+	if err := (&pcrRegressor{numComponents: 3}).Fit(features[:2], target[:2]); err == nil {
+		t.Errorf("Expected an error for more components than rows")
+	}
+
+	// Cross-validation on 5 rows trains on 4, so it must not try all 5 components
+	wide := make([][]float64, 5)
+	for i := range wide {
+		wide[i] = []float64{float64(i), float64(i * i), float64(i % 2), float64(i % 3), float64(i % 4)}
+	}
+	if err := newPCR().Fit(wide, target[:5]); err != nil {
+		t.Errorf("Unexpected error choosing components on few rows: %v", err)
+	}
+}
+
+func TestComponentRegressionChoosesFewComponents(t *testing.T) {
+	features, target := collinearData(200)
+
+	pcr := newPCR()
+	if err := pcr.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(pcr.cvMSE) != 4 {
+		t.Fatalf("Unexpected number of cross-validated counts. Expected 4, got %d", len(pcr.cvMSE))
+	}
+	// One component carries the latent variable and about three quarters of the variance
+	if pcr.explainedVariance[0] < 0.7 {
+		t.Errorf("Unexpected variance explained by the first component: %f", pcr.explainedVariance[0])
+	}
+
+	pls := newPLS()
+	if err := pls.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Components past the first add almost nothing out of fold
+	if best := floats.Min(pls.cvMSE); pls.cvMSE[0] > 1.05*best {
+		t.Errorf("Unexpected cross-validated MSE with one PLS component. Expected about %f, got %f", best, pls.cvMSE[0])
+	}
+	if pls.explainedTargetVariance[0] < 0.95 {
+		t.Errorf("Unexpected target variance explained by the first PLS component: %f", pls.explainedTargetVariance[0])
+	}
+	if rmse := rootMeanSquaredError(predictAll(pls, features), target); rmse > 0.4 {
+		t.Errorf("Unexpected PLS RMSE. Expected about 0.3, got %f", rmse)
+	}
+
+	report := pls.report([]string{"nox", "indus", "dis", "noise"})
+	for _, want := range []string{"nox", "noise", "y var %", "cross-validated RMSE"} {
+		if !strings.Contains(report, want) {
+			t.Errorf("Expected the report to contain %q:\n%s", want, report)
+		}
+	}
+}
+
+func TestCrossValidatedPredictions(t *testing.T) {
+	folds := kFoldSplits(10, 3, 1)
+	seen := make(map[int]bool)
+	for _, fold := range folds {
+		if len(fold) < 3 || len(fold) > 4 {
+			t.Errorf("Unexpected fold size %d", len(fold))
+		}
+		for _, i := range fold {
+			seen[i] = true
+		}
+	}
+	if len(seen) != 10 {
+		t.Errorf("Unexpected number of rows across folds. Expected 10, got %d", len(seen))
+	}
+
+	// Out-of-fold errors of a correct linear model are close to the noise variance
+	features, target := noisyLinearData(200)
+	mse, err := crossValidatedMSE(func() regressor { return &linearModel{} }, features, target, nil, 5, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if mse < 0.2 || mse > 0.3 {
+		t.Errorf("Unexpected cross-validated MSE. Expected about 0.25, got %f", mse)
+	}
+	if _, err := crossValidatedMSE(func() regressor { return &linearModel{} }, features, target, nil, 1, 1); err == nil {
+		t.Errorf("Expected an error for a single fold")
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// quantileRegressor fits a linear model for each requested quantile of the target by
+// minimizing the pinball loss, e.g. quantiles 0.1, 0.5 and 0.9 for a price range around
+// the median. Each quantile is fit by iteratively reweighted least squares, with weight
+// τ/|r| for rows above the fit and (1-τ)/|r| for rows below it, one runTasks task per
+// quantile.
+//
+// Separately fit quantile lines can cross; predictQuantiles sorts the predictions of a
+// row (monotone rearrangement), which guarantees non-crossing quantiles and never
+// increases the pinball loss.
+type quantileRegressor struct {
+	quantiles     []float64 // each in (0, 1)
+	maxIterations int
+	tolerance     float64
+
+	coefficients [][]float64 // per quantile, intercept first like linearRegression
+}
+
+// newQuantileRegressor returns a quantileRegressor for the given quantiles.
+func newQuantileRegressor(quantiles ...float64) *quantileRegressor {
+	return &quantileRegressor{quantiles: quantiles, maxIterations: 200, tolerance: 1e-6}
+}
+
+func (q *quantileRegressor) Fit(features [][]float64, target []float64) error {
+	return q.FitWeighted(features, target, nil)
+}
+
+// FitWeighted minimizes the weighted pinball loss by multiplying the IRLS weights by the
+// sample weights.
+func (q *quantileRegressor) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	if len(q.quantiles) == 0 {
+		return fmt.Errorf("no quantiles to fit")
+	}
+	for _, tau := range q.quantiles {
+		if tau <= 0 || tau >= 1 {
+			return fmt.Errorf("quantiles must be in (0, 1), got %v", tau)
+		}
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+
+	q.coefficients = make([][]float64, len(q.quantiles))
+	errs := make([]error, len(q.quantiles))
+	runTasks(len(q.quantiles), func(k int) {
+		q.coefficients[k], errs[k] = q.fitQuantile(features, target, weights, q.quantiles[k])
+	})
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (q *quantileRegressor) fitQuantile(features [][]float64, target []float64, sampleWeights []float64, tau float64) ([]float64, error) {
+	// Residuals are floored so rows lying on the fit do not get infinite weight
+	floor := 1e-6 * (medianAbsoluteDeviation(target) + 1e-12)
+
+	weights := append([]float64(nil), sampleWeights...)
+	residuals := make([]float64, len(target))
+	var coefficients []float64
+	for iteration := 0; iteration < q.maxIterations; iteration++ {
+		next, err := weightedLinearRegression(features, target, weights)
+		if err != nil {
+			return nil, err
+		}
+		converged := coefficients != nil && floats.Distance(next, coefficients, math.Inf(1)) < q.tolerance*(1+floats.Norm(coefficients, math.Inf(1)))
+		coefficients = next
+		if converged {
+			break
+		}
+
+		linearResiduals(features, target, coefficients, residuals)
+		for i, r := range residuals {
+			side := tau
+			if r < 0 {
+				side = 1 - tau
+			}
+			weights[i] = sampleWeights[i] * side / math.Max(math.Abs(r), floor)
+		}
+	}
+	return coefficients, nil
+}
+
+// predictQuantiles returns the prediction for every quantile, in increasing quantile
+// order and guaranteed not to cross.
+func (q *quantileRegressor) predictQuantiles(featureRow []float64) []float64 {
+	order := make([]int, len(q.quantiles))
+	for k := range order {
+		order[k] = k
+	}
+	sort.SliceStable(order, func(a, b int) bool { return q.quantiles[order[a]] < q.quantiles[order[b]] })
+
+	predictions := make([]float64, len(q.quantiles))
+	for k, index := range order {
+		predictions[k] = predictLin(featureRow, q.coefficients[index])
+	}
+	sort.Float64s(predictions)
+	return predictions
+}
+
+// Predict returns the prediction for the quantile closest to the median.
+func (q *quantileRegressor) Predict(featureRow []float64) float64 {
+	sorted := append([]float64(nil), q.quantiles...)
+	sort.Float64s(sorted)
+	closest := 0
+	for k, tau := range sorted {
+		if math.Abs(tau-0.5) < math.Abs(sorted[closest]-0.5) {
+			closest = k
+		}
+	}
+	return q.predictQuantiles(featureRow)[closest]
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+// regressor is implemented by every model that can be trained on a feature matrix and
+// then used to predict a home price for a single feature row. Features never include the
+// constant term; models that need an intercept add it themselves.
+type regressor interface {
+	Fit(features [][]float64, target []float64) error
+	Predict(featureRow []float64) float64
+}
+
+// weightedRegressor is a regressor that can also be trained with a non-negative weight per
+// row, e.g. the number of homes a tract stands for or the inverse variance of its price.
+// Weights act like repeating a row: an integer weight w gives the same fit as w copies of
+// the row, up to the randomness of models that sample rows. FitWeighted with nil weights
+// is the same as Fit.
+type weightedRegressor interface {
+	regressor
+	FitWeighted(features [][]float64, target []float64, weights []float64) error
+}
+
+// coefficientModel is a regressor that is linear in the features, with coefficients
+// intercept first like linearRegression.
+type coefficientModel interface {
+	regressor
+	linearCoefficients() []float64
+}
+
+// linearModel exposes linearRegression and predictLin as a regressor.
+type linearModel struct {
+	coefficients []float64
+}
+
+// ridgeModel exposes ridgeRegression and predictRidge as a regressor.
+type ridgeModel struct {
+	lambda       float64
+	coefficients []float64
+}
+
+func (m *linearModel) Fit(features [][]float64, target []float64) error {
+	return m.FitWeighted(features, target, nil)
+}
+
+func (m *linearModel) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if weights == nil {
+		m.coefficients = linearRegression(features, target)
+		return nil
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	m.coefficients, err = weightedLinearRegression(features, target, weights)
+	return err
+}
+
+func (m *linearModel) Predict(featureRow []float64) float64 {
+	return predictLin(featureRow, m.coefficients)
+}
+
+func (m *linearModel) linearCoefficients() []float64 {
+	return m.coefficients
+}
+
+func (m *ridgeModel) Fit(features [][]float64, target []float64) error {
+	return m.FitWeighted(features, target, nil)
+}
+
+func (m *ridgeModel) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if weights == nil {
+		m.coefficients = ridgeRegression(features, target, m.lambda)
+		return nil
+	}
+	weights, err := checkWeights(weights, len(target))
+	if err != nil {
+		return err
+	}
+	m.coefficients, err = weightedRidgeRegression(features, target, weights, m.lambda)
+	return err
+}
+
+func (m *ridgeModel) Predict(featureRow []float64) float64 {
+	return predictRidge(featureRow, m.coefficients)
+}
+
+func (m *ridgeModel) linearCoefficients() []float64 {
+	return m.coefficients
+}
+
+// fitWithWeights fits model by FitWeighted, or by Fit when weights is nil. A model that is
+// not a weightedRegressor can only be fit without weights.
+func fitWithWeights(model regressor, features [][]float64, target []float64, weights []float64) error {
+	if weights == nil {
+		return model.Fit(features, target)
+	}
+	weighted, ok := model.(weightedRegressor)
+	if !ok {
+		return fmt.Errorf("%T does not support sample weights", model)
+	}
+	return weighted.FitWeighted(features, target, weights)
+}
+
+// predictAll runs model.Predict on every row of features.
+func predictAll(model regressor, features [][]float64) []float64 {
+	predictions := make([]float64, len(features))
+	for i, row := range features {
+		predictions[i] = model.Predict(row)
+	}
+	return predictions
+}
+
+// checkWeights validates sample weights for n rows and returns them, or n ones when
+// weights is nil. Weights must be finite and non-negative with a positive sum.
+func checkWeights(weights []float64, n int) ([]float64, error) {
+	if weights == nil {
+		ones := make([]float64, n)
+		for i := range ones {
+			ones[i] = 1
+		}
+		return ones, nil
+	}
+	if len(weights) != n {
+		return nil, fmt.Errorf("weights and target length mismatch: %d vs %d", len(weights), n)
+	}
+	var sum float64
+	for i, w := range weights {
+		if w < 0 || math.IsNaN(w) || math.IsInf(w, 0) {
+			return nil, fmt.Errorf("weights must be finite and non-negative, got %v at row %d", w, i)
+		}
+		sum += w
+	}
+	if sum == 0 {
+		return nil, fmt.Errorf("weights must not all be zero")
+	}
+	return weights, nil
+}
+
+// positiveWeightRows drops the rows with zero weight, for models in which a zero weight
+// would mean infinite noise or an unbounded penalty on that row.
+func positiveWeightRows(features [][]float64, target []float64, weights []float64) ([][]float64, []float64, []float64) {
+	var keptFeatures [][]float64
+	var keptTarget, keptWeights []float64
+	for i, w := range weights {
+		if w > 0 {
+			keptFeatures = append(keptFeatures, features[i])
+			keptTarget = append(keptTarget, target[i])
+			keptWeights = append(keptWeights, w)
+		}
+	}
+	return keptFeatures, keptTarget, keptWeights
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+// weightedData returns a small nonlinear data set with integer weights, and the same data
+// with every row repeated weight times.
+func weightedData() ([][]float64, []float64, []float64, [][]float64, []float64) {
+	var features, repeatedFeatures [][]float64
+	var target, weights, repeatedTarget []float64
+	for i := 0; i < 24; i++ {
+		x1 := float64(i) / 4
+		x2 := float64((i * 5) % 7)
+		row := []float64{x1, x2}
+		y := 3 + 2*x1 - 0.5*x2 + math.Sin(x1)
+		w := float64(i%3 + 1)
+
+		features = append(features, row)
+		target = append(target, y)
+		weights = append(weights, w)
+		for k := 0; k < int(w); k++ {
+			repeatedFeatures = append(repeatedFeatures, row)
+			repeatedTarget = append(repeatedTarget, y)
+		}
+	}
+	return features, target, weights, repeatedFeatures, repeatedTarget
+}
+
+func TestWeightsMatchRepeatedRows(t *testing.T) {
+	features, target, weights, repeatedFeatures, repeatedTarget := weightedData()
+
+	// Each constructor returns a fresh model, so the two fits cannot share state
+	models := map[string]func() weightedRegressor{
+		"linear": func() weightedRegressor { return &linearModel{} },
+		"ridge":  func() weightedRegressor { return &ridgeModel{lambda: 0.5} },
+		"tree": func() weightedRegressor {
+			tree := newDecisionTree()
+			tree.maxDepth = 3
+			return tree
+		},
+		"kernel ridge": func() weightedRegressor {
+			return &kernelRidge{kernel: rbfKernel{lengthScale: 2, variance: 1}, lambda: 0.1}
+		},
+		"gaussian process": func() weightedRegressor {
+			return &gaussianProcess{kernel: rbfKernel{lengthScale: 2, variance: 1}, noise: 0.1}
+		},
+		"huber":    func() weightedRegressor { return newHuber() },
+		"quantile": func() weightedRegressor { return newQuantileRegressor(0.5) },
+		"log target": func() weightedRegressor {
+			return &transformedTargetRegressor{regressor: &linearModel{}, transform: logTransform{}, smearing: true}
+		},
+		"pcr": func() weightedRegressor { return &pcrRegressor{numComponents: 1} },
+		"pls": func() weightedRegressor { return &plsRegressor{numComponents: 1} },
+		"forward selection": func() weightedRegressor {
+			return newFeatureSelection(selectForward, criterionAIC)
+		},
+		"lasso":    func() weightedRegressor { return newLasso(0.1) },
+		"isotonic": func() weightedRegressor { return newIsotonicRegression(0) },
+		"averaging": func() weightedRegressor {
+			return newAveragingEnsemble(nil, func() regressor { return &linearModel{} }, func() regressor { return newLasso(0.1) })
+		},
+		"rfe": func() weightedRegressor {
+			r := newRFE(func() regressor { return &linearModel{} }, importanceCoefficient)
+			r.numFeatures = 1
+			return r
+		},
+	}
+	for name, newModel := range models {
+		weighted, repeated := newModel(), newModel()
+		if err := weighted.FitWeighted(features, target, weights); err != nil {
+			t.Fatalf("Unexpected %s error: %v", name, err)
+		}
+		if err := repeated.Fit(repeatedFeatures, repeatedTarget); err != nil {
+			t.Fatalf("Unexpected %s error: %v", name, err)
+		}
+
+		for i, row := range features {
+			expected, got := repeated.Predict(row), weighted.Predict(row)
+			if math.Abs(expected-got) > 1e-3*(1+math.Abs(expected)) {
+				t.Errorf("Unexpected %s prediction for row %d. Expected %f, got %f", name, i, expected, got)
+			}
+		}
+	}
+}
+
+func TestWeightsAreScaleFree(t *testing.T) {
+	features, target, weights, _, _ := weightedData()
+
+	// Fractional weights that sum to less than 1
+	fractional := make([]float64, len(weights))
+	for i, w := range weights {
+		fractional[i] = w / 100
+	}
+
+	models := map[string]func() weightedRegressor{
+		"lasso": func() weightedRegressor { return newLasso(0.1) },
+		"pcr":   func() weightedRegressor { return &pcrRegressor{numComponents: 1} },
+		"pls":   func() weightedRegressor { return &plsRegressor{numComponents: 1} },
+		"mlp": func() weightedRegressor {
+			m := newMLP(8)
+			m.validationFraction = 0
+			m.epochs = 50
+			return m
+		},
+	}
+	for name, newModel := range models {
+		scaled, unscaled := newModel(), newModel()
+		if err := scaled.FitWeighted(features, target, fractional); err != nil {
+			t.Fatalf("Unexpected %s error: %v", name, err)
+		}
+		if err := unscaled.FitWeighted(features, target, weights); err != nil {
+			t.Fatalf("Unexpected %s error: %v", name, err)
+		}
+
+		for i, row := range features {
+			expected, got := unscaled.Predict(row), scaled.Predict(row)
+			if !(math.Abs(expected-got) <= 1e-3*(1+math.Abs(expected))) {
+				t.Errorf("Unexpected %s prediction for row %d. Expected %f, got %f", name, i, expected, got)
+			}
+		}
+	}
+
+	// A Gaussian process weight scales the noise of its row, so smaller weights give a
+	// smoother fit rather than the same one, but the target scaling must not break
+	gp := &gaussianProcess{kernel: rbfKernel{lengthScale: 2, variance: 1}, noise: 0.1, standardize: true}
+	if err := gp.FitWeighted(features, target, fractional); err != nil {
+		t.Fatalf("Unexpected gaussian process error: %v", err)
+	}
+	if rmse := rootMeanSquaredError(predictAll(gp, features), target); !(rmse < 2) {
+		t.Errorf("Unexpected gaussian process RMSE. Expected at most 2, got %f", rmse)
+	}
+}
+
+func TestZeroWeightsIgnoreRows(t *testing.T) {
+	features, target, _, _, _ := weightedData()
+
+	// Corrupt the last rows and give them no weight
+	corrupted := append([]float64(nil), target...)
+	weights := make([]float64, len(target))
+	for i := range weights {
+		weights[i] = 1
+		if i >= 20 {
+			corrupted[i] += 100
+			weights[i] = 0
+		}
+	}
+
+	models := map[string]weightedRegressor{
+		"linear": &linearModel{},
+		"knn":    newKNN(3),
+		"gam":    newGAM([]string{"x1", "x2"}, gamTerm{column: "x1", spline: "bs", knots: 4, lambda: 0.1}),
+		"svr":    newSVR(rbfKernel{lengthScale: 1, variance: 1}),
+		"forest": newRandomForest(),
+		"boosting": func() weightedRegressor {
+			g := newGradientBoosting()
+			g.validationFraction = 0
+			g.minSamplesLeaf = 2
+			return g
+		}(),
+		"mlp": func() weightedRegressor {
+			m := newMLP(8)
+			m.validationFraction = 0
+			m.epochs = 50
+			return m
+		}(),
+		"ransac":      newRANSAC(),
+		"theil-sen":   newTheilSen(),
+		"pcr":         newPCR(),
+		"pls":         newPLS(),
+		"best subset": newFeatureSelection(selectBestSubset, criterionCV),
+		"lasso":       newLasso(0.1),
+		"rfe":         newRFE(func() regressor { return newLasso(0.01) }, importancePermutation),
+		"stacking": newStackingEnsemble(func() regressor { return &ridgeModel{lambda: 1} },
+			func() regressor { return &linearModel{} }, func() regressor { return newLasso(0.1) }),
+		"calibrated": newCalibratedRegressor(func() regressor { return &linearModel{} }),
+		"blending": newBlendingEnsemble(func() regressor { return &ridgeModel{lambda: 1} },
+			func() regressor { return &linearModel{} }, func() regressor { return newLasso(0.1) }),
+	}
+	for name, model := range models {
+		if err := model.FitWeighted(features, corrupted, weights); err != nil {
+			t.Fatalf("Unexpected %s error: %v", name, err)
+		}
+		predictions := predictAll(model, features[:20])
+		if rmse := rootMeanSquaredError(predictions, target[:20]); rmse > 5 {
+			t.Errorf("Unexpected %s RMSE on the weighted rows. Expected at most 5, got %f", name, rmse)
+		}
+	}
+}
+
+func TestCheckWeights(t *testing.T) {
+	if weights, err := checkWeights(nil, 3); err != nil || len(weights) != 3 || weights[2] != 1 {
+		t.Errorf("Unexpected result for nil weights: %v, %v", weights, err)
+	}
+	invalid := [][]float64{{1, 2}, {1, -1, 2}, {1, math.NaN(), 2}, {0, 0, 0}}
+	for _, weights := range invalid {
+		if _, err := checkWeights(weights, 3); err == nil {
+			t.Errorf("Expected an error for weights %v", weights)
+		}
+	}
+}
+
+func TestWeightedMetrics(t *testing.T) {
+	// Create sample data where weight 2 is the same as repeating a row
+	predictions := []float64{1, 2, 4}
+	targets := []float64{2, 2, 2}
+	weights := []float64{2, 1, 1}
+	repeatedPredictions := []float64{1, 1, 2, 4}
+	repeatedTargets := []float64{2, 2, 2, 2}
+
+	if got, expected := weightedMeanSquaredError(predictions, targets, weights), meanSquaredError(repeatedPredictions, repeatedTargets); math.Abs(got-expected) > 1e-12 {
+		t.Errorf("Unexpected weighted MSE. Expected %f, got %f", expected, got)
+	}
+	if got, expected := weightedRootMeanSquaredError(predictions, targets, weights), rootMeanSquaredError(repeatedPredictions, repeatedTargets); math.Abs(got-expected) > 1e-12 {
+		t.Errorf("Unexpected weighted RMSE. Expected %f, got %f", expected, got)
+	}
+	if got, expected := weightedMeanAbsolutePercentageError(predictions, targets, weights), meanAbsolutePercentageError(repeatedPredictions, repeatedTargets); math.Abs(got-expected) > 1e-12 {
+		t.Errorf("Unexpected weighted MAPE. Expected %f, got %f", expected, got)
+	}
+	if got, expected := weightedRootMeanSquaredPercentageError(predictions, targets, weights), rootMeanSquaredPercentageError(repeatedPredictions, repeatedTargets); math.Abs(got-expected) > 1e-12 {
+		t.Errorf("Unexpected weighted RMSPE. Expected %f, got %f", expected, got)
+	}
+}
+
+func TestLoadWeightedCSV(t *testing.T) {
+	features, target, weights, err := loadWeightedCSV("boston.csv", "crim")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	names, err := loadColumnNames("boston.csv")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// The weight column is removed from the features
+	if len(features[0]) != len(names)-1 {
+		t.Errorf("Unexpected number of features. Expected %d, got %d", len(names)-1, len(features[0]))
+	}
+	if weights[0] != 0.00632 || features[0][0] != 18 || target[0] != 24 {
+		t.Errorf("Unexpected first row: weight %f, first feature %f, target %f", weights[0], features[0][0], target[0])
+	}
+	if _, _, _, err := loadWeightedCSV("boston.csv", "missing"); err == nil {
+		t.Errorf("Expected an error for a missing weight column")
+	}
+}
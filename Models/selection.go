@@ -0,0 +1,340 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"strings"
+
+	"gonum.org/v1/gonum/floats"
+)
+
+// selectionCriterion scores a candidate feature subset; lower is better for all of them.
+type selectionCriterion string
+
+const (
+	criterionAIC selectionCriterion = "AIC" // n log(RSS/n) + 2k
+	criterionBIC selectionCriterion = "BIC" // n log(RSS/n) + k log n
+	criterionCV  selectionCriterion = "CV"  // cross-validated MSE
+)
+
+// selectionMethod is the search over feature subsets.
+type selectionMethod string
+
+const (
+	selectForward       selectionMethod = "forward"       // start empty, add one column per step
+	selectBackward      selectionMethod = "backward"      // start full, remove one column per step
+	selectBidirectional selectionMethod = "bidirectional" // start empty, add or remove per step
+	selectBestSubset    selectionMethod = "best subset"   // score every subset
+)
+
+// maxBestSubsetFeatures bounds the exhaustive search, which fits 2^p models.
+const maxBestSubsetFeatures = 16
+
+// featureSelection chooses the columns of a linearRegression by a search over subsets.
+// Stepwise methods take the best single change at every step while it improves the score;
+// all candidate changes of a step, and all subsets of the exhaustive search, are scored
+// through runTasks, the subsets in one task per CPU.
+type featureSelection struct {
+	method    selectionMethod
+	criterion selectionCriterion
+	numFolds  int // folds for criterionCV
+	seed      int64
+
+	selected     []int     // chosen feature columns, ascending
+	coefficients []float64 // intercept first, one slope per selected column
+	score        float64
+	trajectory   []selectionStep
+}
+
+// selectionStep records one step of the search: the column added or removed (-1 for
+// none), the columns in the model afterwards and their score. The exhaustive search
+// records the best subset of every size instead.
+type selectionStep struct {
+	added   int
+	removed int
+	columns []int
+	score   float64
+}
+
+// newFeatureSelection returns a featureSelection using 5-fold cross-validation when the
+// criterion is criterionCV.
+func newFeatureSelection(method selectionMethod, criterion selectionCriterion) *featureSelection {
+	return &featureSelection{method: method, criterion: criterion, numFolds: 5, seed: 1}
+}
+
+// Fit runs the search and refits linearRegression on the selected columns.
+func (s *featureSelection) Fit(features [][]float64, target []float64) error {
+	return s.FitWeighted(features, target, nil)
+}
+
+// FitWeighted runs the search with every subset fit by weighted least squares. The
+// information criteria count row i weights[i] times, as if it were repeated.
+func (s *featureSelection) FitWeighted(features [][]float64, target []float64, weights []float64) error {
+	if len(features) != len(target) {
+		return fmt.Errorf("features and target length mismatch: %d vs %d", len(features), len(target))
+	}
+	if weights != nil {
+		if _, err := checkWeights(weights, len(target)); err != nil {
+			return err
+		}
+	}
+	switch s.criterion {
+	case criterionAIC, criterionBIC, criterionCV:
+	default:
+		return fmt.Errorf("unknown selection criterion %q", s.criterion)
+	}
+
+	s.trajectory = nil
+	var err error
+	switch s.method {
+	case selectForward, selectBidirectional:
+		err = s.stepwise(features, target, weights, nil)
+	case selectBackward:
+		all := make([]int, len(features[0]))
+		for j := range all {
+			all[j] = j
+		}
+		err = s.stepwise(features, target, weights, all)
+	case selectBestSubset:
+		err = s.bestSubset(features, target, weights)
+	default:
+		err = fmt.Errorf("unknown selection method %q", s.method)
+	}
+	if err != nil {
+		return err
+	}
+	s.coefficients, err = fitSubset(selectColumns(features, s.selected), target, weights)
+	return err
+}
+
+// stepwise starts from the columns in start and greedily applies the add or remove with
+// the lowest score until no change improves on the current model.
+func (s *featureSelection) stepwise(features [][]float64, target []float64, weights []float64, start []int) error {
+	numFeatures := len(features[0])
+	inModel := make([]bool, numFeatures)
+	for _, j := range start {
+		inModel[j] = true
+	}
+	current, err := s.scoreSubset(features, target, weights, start)
+	if err != nil {
+		return err
+	}
+	s.trajectory = append(s.trajectory, selectionStep{added: -1, removed: -1, columns: start, score: current})
+
+	canAdd := s.method != selectBackward
+	canRemove := s.method != selectForward
+	for {
+		// Toggling column j adds it when absent and removes it when present
+		var candidates []int
+		for j := 0; j < numFeatures; j++ {
+			if (!inModel[j] && canAdd) || (inModel[j] && canRemove) {
+				candidates = append(candidates, j)
+			}
+		}
+		if len(candidates) == 0 {
+			break
+		}
+		scores := make([]float64, len(candidates))
+		errs := make([]error, len(candidates))
+		runTasks(len(candidates), func(c int) {
+			toggled := make([]bool, numFeatures)
+			copy(toggled, inModel)
+			toggled[candidates[c]] = !toggled[candidates[c]]
+			scores[c], errs[c] = s.scoreSubset(features, target, weights, columnsOf(toggled))
+		})
+		best := -1
+		for c := range candidates {
+			if errs[c] != nil {
+				return errs[c]
+			}
+			if best < 0 || scores[c] < scores[best] {
+				best = c
+			}
+		}
+		if scores[best] >= current {
+			break
+		}
+
+		j := candidates[best]
+		step := selectionStep{added: -1, removed: -1, score: scores[best]}
+		if inModel[j] {
+			step.removed = j
+		} else {
+			step.added = j
+		}
+		inModel[j] = !inModel[j]
+		step.columns = columnsOf(inModel)
+		s.trajectory = append(s.trajectory, step)
+		current = scores[best]
+	}
+	s.selected = columnsOf(inModel)
+	s.score = current
+	return nil
+}
+
+// bestSubset scores all 2^p subsets and keeps the best one of every size for the
+// trajectory. The subsets are dealt to one task per CPU rather than one task each, so the
+// concurrent build does not start 2^p goroutines at once.
+func (s *featureSelection) bestSubset(features [][]float64, target []float64, weights []float64) error {
+	numFeatures := len(features[0])
+	if numFeatures > maxBestSubsetFeatures {
+		return fmt.Errorf("best subset search supports at most %d features, got %d", maxBestSubsetFeatures, numFeatures)
+	}
+
+	numSubsets := 1 << uint(numFeatures)
+	scores := make([]float64, numSubsets)
+	errs := make([]error, numSubsets)
+	numTasks := runtime.NumCPU()
+	if numTasks > numSubsets {
+		numTasks = numSubsets
+	}
+	runTasks(numTasks, func(task int) {
+		// Each task writes only the masks congruent to its index
+		for mask := task; mask < numSubsets; mask += numTasks {
+			scores[mask], errs[mask] = s.scoreSubset(features, target, weights, columnsOfMask(mask, numFeatures))
+		}
+	})
+
+	bestBySize := make([]int, numFeatures+1)
+	for k := range bestBySize {
+		bestBySize[k] = -1
+	}
+	best := 0
+	for mask := range scores {
+		if errs[mask] != nil {
+			return errs[mask]
+		}
+		size := len(columnsOfMask(mask, numFeatures))
+		if bestBySize[size] < 0 || scores[mask] < scores[bestBySize[size]] {
+			bestBySize[size] = mask
+		}
+		if scores[mask] < scores[best] {
+			best = mask
+		}
+	}
+	for _, mask := range bestBySize {
+		s.trajectory = append(s.trajectory, selectionStep{added: -1, removed: -1, columns: columnsOfMask(mask, numFeatures), score: scores[mask]})
+	}
+	s.selected = columnsOfMask(best, numFeatures)
+	s.score = scores[best]
+	return nil
+}
+
+// scoreSubset fits linearRegression on the given columns and returns its criterion value.
+// The empty subset is the intercept-only model. With weights, n is the total weight and
+// RSS the weighted residual sum of squares.
+func (s *featureSelection) scoreSubset(features [][]float64, target []float64, weights []float64, columns []int) (float64, error) {
+	subset := selectColumns(features, columns)
+	if s.criterion == criterionCV {
+		return crossValidatedMSE(func() regressor { return &linearModel{} }, subset, target, weights, s.numFolds, s.seed)
+	}
+
+	coefficients, err := fitSubset(subset, target, weights)
+	if err != nil {
+		return 0, err
+	}
+	n := float64(len(target))
+	if weights != nil {
+		n = floats.Sum(weights)
+	}
+	var rss float64
+	for i, row := range subset {
+		residual := target[i] - predictLin(row, coefficients)
+		if weights != nil {
+			rss += weights[i] * residual * residual
+		} else {
+			rss += residual * residual
+		}
+	}
+	k := float64(len(coefficients))
+	penalty := 2 * k
+	if s.criterion == criterionBIC {
+		penalty = k * math.Log(n)
+	}
+	return n*math.Log(rss/n) + penalty, nil
+}
+
+func (s *featureSelection) Predict(featureRow []float64) float64 {
+	subset := make([]float64, len(s.selected))
+	for k, j := range s.selected {
+		subset[k] = featureRow[j]
+	}
+	return predictLin(subset, s.coefficients)
+}
+
+// report lists the selected columns and every step of the search with its score.
+func (s *featureSelection) report(columnNames []string) string {
+	names := func(columns []int) string {
+		if len(columns) == 0 {
+			return "(intercept only)"
+		}
+		parts := make([]string, len(columns))
+		for k, j := range columns {
+			parts[k] = featureName(columnNames, j)
+		}
+		return strings.Join(parts, ", ")
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s selection by %s\n", s.method, s.criterion)
+	fmt.Fprintf(&sb, "%-4s %-10s %12s  %s\n", "step", "change", "score", "columns")
+	for k, step := range s.trajectory {
+		change := ""
+		switch {
+		case step.added >= 0:
+			change = "+" + featureName(columnNames, step.added)
+		case step.removed >= 0:
+			change = "-" + featureName(columnNames, step.removed)
+		case s.method == selectBestSubset:
+			change = fmt.Sprintf("size %d", len(step.columns))
+		default:
+			change = "start"
+		}
+		fmt.Fprintf(&sb, "%-4d %-10s %12.4f  %s\n", k, change, step.score, names(step.columns))
+	}
+	fmt.Fprintf(&sb, "selected: %s (%s %.4f)\n", names(s.selected), s.criterion, s.score)
+	return sb.String()
+}
+
+// fitSubset fits linearRegression, or weightedLinearRegression when weights is non-nil.
+func fitSubset(subset [][]float64, target []float64, weights []float64) ([]float64, error) {
+	if weights == nil {
+		return linearRegression(subset, target), nil
+	}
+	return weightedLinearRegression(subset, target, weights)
+}
+
+// selectColumns returns the given columns of every row, in order.
+func selectColumns(features [][]float64, columns []int) [][]float64 {
+	subset := make([][]float64, len(features))
+	for i, row := range features {
+		subset[i] = make([]float64, len(columns))
+		for k, j := range columns {
+			subset[i][k] = row[j]
+		}
+	}
+	return subset
+}
+
+// columnsOf returns the indexes of the true entries, ascending.
+func columnsOf(inModel []bool) []int {
+	var columns []int
+	for j, in := range inModel {
+		if in {
+			columns = append(columns, j)
+		}
+	}
+	return columns
+}
+
+// columnsOfMask returns the indexes of the set bits among the first numFeatures bits.
+func columnsOfMask(mask, numFeatures int) []int {
+	var columns []int
+	for j := 0; j < numFeatures; j++ {
+		if mask&(1<<uint(j)) != 0 {
+			columns = append(columns, j)
+		}
+	}
+	return columns
+}
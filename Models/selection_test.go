@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFeatureSelectionDropsIrrelevantFeature(t *testing.T) {
+	features, target := noisyLinearData(200)
+
+	for _, method := range []selectionMethod{selectForward, selectBackward, selectBidirectional, selectBestSubset} {
+		for _, criterion := range []selectionCriterion{criterionAIC, criterionBIC, criterionCV} {
+			s := newFeatureSelection(method, criterion)
+			if err := s.Fit(features, target); err != nil {
+				t.Fatalf("Unexpected %s/%s error: %v", method, criterion, err)
+			}
+			// x3 has no effect, and BIC's heavier penalty always drops it
+			if criterion == criterionBIC && !reflect.DeepEqual(s.selected, []int{0, 1}) {
+				t.Errorf("Unexpected %s/%s selection. Expected [0 1], got %v", method, criterion, s.selected)
+			}
+			if len(s.selected) < 2 || s.selected[0] != 0 || s.selected[1] != 1 {
+				t.Errorf("Unexpected %s/%s selection. Expected x1 and x2, got %v", method, criterion, s.selected)
+			}
+			last := s.trajectory[len(s.trajectory)-1]
+			if method != selectBestSubset && last.score != s.score {
+				t.Errorf("Unexpected %s/%s trajectory. Expected to end at %f, got %f", method, criterion, s.score, last.score)
+			}
+		}
+	}
+}
+
+func TestFeatureSelectionTrajectoryAndPredictions(t *testing.T) {
+	features, target := noisyLinearData(200)
+
+	forward := newFeatureSelection(selectForward, criterionBIC)
+	if err := forward.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// Start from the intercept, then add x1 (the strongest effect) and x2 with falling scores
+	if len(forward.trajectory) != 3 || forward.trajectory[1].added != 0 || forward.trajectory[2].added != 1 {
+		t.Fatalf("Unexpected forward trajectory: %+v", forward.trajectory)
+	}
+	for k := 1; k < len(forward.trajectory); k++ {
+		if forward.trajectory[k].score >= forward.trajectory[k-1].score {
+			t.Errorf("Unexpected score at step %d. Expected below %f, got %f", k, forward.trajectory[k-1].score, forward.trajectory[k].score)
+		}
+	}
+
+	// The selected model is OLS on the selected columns
+	expected := linearRegression(selectColumns(features, []int{0, 1}), target)
+	row := features[7]
+	if got, want := forward.Predict(row), predictLin(row[:2], expected); math.Abs(got-want) > 1e-9 {
+		t.Errorf("Unexpected prediction. Expected %f, got %f", want, got)
+	}
+
+	best := newFeatureSelection(selectBestSubset, criterionAIC)
+	if err := best.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(best.trajectory) != 4 {
+		t.Errorf("Unexpected best subset trajectory length. Expected one step per size, got %d", len(best.trajectory))
+	}
+	report := best.report([]string{"rooms", "lstat", "age"})
+	if !strings.Contains(report, "selected: rooms, lstat") {
+		t.Errorf("Expected the report to list the selected columns:\n%s", report)
+	}
+
+	wide := make([][]float64, 30)
+	for i := range wide {
+		wide[i] = make([]float64, maxBestSubsetFeatures+1)
+	}
+	if err := best.Fit(wide, target[:30]); err == nil {
+		t.Errorf("Expected an error for too many features in the best subset search")
+	}
+}
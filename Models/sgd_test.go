@@ -0,0 +1,126 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSGDMatchesOLS(t *testing.T) {
+	features, target := noisyLinearData(200)
+	expected := linearRegression(features, target)
+
+	model := newSGD()
+	model.penalty = "none"
+	model.schedule = "adaptive"
+	if err := model.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	coefficients := model.linearCoefficients()
+	for j := range expected {
+		if math.Abs(coefficients[j]-expected[j]) > 0.02 {
+			t.Errorf("Unexpected coefficient %d. Expected %f, got %f", j, expected[j], coefficients[j])
+		}
+	}
+
+	model.loss = "cubic"
+	if err := model.Fit(features, target); err == nil {
+		t.Errorf("Expected an error for an unknown loss")
+	}
+}
+
+func TestSGDLossesAndPenalties(t *testing.T) {
+	features, target := noisyLinearData(200)
+	// Corrupt a tenth of the prices
+	corrupted := append([]float64(nil), target...)
+	for i := 0; i < len(corrupted); i += 10 {
+		corrupted[i] += 30
+	}
+
+	for _, loss := range []string{"huber", "epsilon_insensitive"} {
+		model := newSGD()
+		model.loss = loss
+		if err := model.Fit(features, corrupted); err != nil {
+			t.Fatalf("Unexpected %s error: %v", loss, err)
+		}
+		// The robust losses keep the slopes close to the truth despite the outliers
+		coefficients := model.linearCoefficients()
+		if math.Abs(coefficients[1]-2) > 0.2 || math.Abs(coefficients[2]+1) > 0.2 {
+			t.Errorf("Unexpected %s slopes. Expected about 2 and -1, got %f and %f", loss, coefficients[1], coefficients[2])
+		}
+	}
+
+	// A strong L1 penalty zeroes the feature that has no effect
+	lasso := newSGD()
+	lasso.penalty = "l1"
+	lasso.alpha = 0.05
+	if err := lasso.Fit(features, target); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if lasso.slopes[2] != 0 || lasso.slopes[0] == 0 {
+		t.Errorf("Unexpected L1 slopes: %v", lasso.slopes)
+	}
+}
+
+func TestSGDPartialFit(t *testing.T) {
+	features, target := noisyLinearData(400)
+
+	// Stream the rows in batches, as new sales records would arrive
+	model := newSGD()
+	for pass := 0; pass < 5; pass++ {
+		for start := 0; start < len(target); start += 40 {
+			if err := model.PartialFit(features[start:start+40], target[start:start+40]); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		}
+	}
+	coefficients := model.linearCoefficients()
+	for j, truth := range []float64{1, 2, -1, 0} {
+		if math.Abs(coefficients[j]-truth) > 0.15 {
+			t.Errorf("Unexpected streamed coefficient %d. Expected about %f, got %f", j, truth, coefficients[j])
+		}
+	}
+
+	// New rows move the scaling but not the predictions
+	before := model.Predict(features[0])
+	model.updateScaling([][]float64{{10, -5, 3}}, []float64{40}, []float64{1})
+	if after := model.Predict(features[0]); math.Abs(after-before) > 1e-9 {
+		t.Errorf("Unexpected prediction change after rescaling. Expected %f, got %f", before, after)
+	}
+	if err := model.PartialFit([][]float64{{1, 2}}, []float64{3}); err == nil {
+		t.Errorf("Expected an error for a batch with the wrong number of features")
+	}
+}
+
+func TestSGDPartialFitWeighted(t *testing.T) {
+	features, target := noisyLinearData(40)
+
+	// Weight 2 counts a row twice in the running statistics, and weight 0 not at all
+	weights := make([]float64, len(target))
+	var repeatedFeatures [][]float64
+	var repeatedTarget []float64
+	for i := range weights {
+		weights[i] = float64(i % 3)
+		for k := 0; k < i%3; k++ {
+			repeatedFeatures = append(repeatedFeatures, features[i])
+			repeatedTarget = append(repeatedTarget, target[i])
+		}
+	}
+	weighted, repeated := newSGD(), newSGD()
+	if err := weighted.PartialFitWeighted(features, target, weights); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := repeated.PartialFit(repeatedFeatures, repeatedTarget); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for j := range weighted.scaler.means {
+		if math.Abs(weighted.scaler.means[j]-repeated.scaler.means[j]) > 1e-9 || math.Abs(weighted.scaler.scale[j]-repeated.scaler.scale[j]) > 1e-9 {
+			t.Errorf("Unexpected scaling of feature %d. Expected %f and %f, got %f and %f", j, repeated.scaler.means[j], repeated.scaler.scale[j], weighted.scaler.means[j], weighted.scaler.scale[j])
+		}
+	}
+	if math.Abs(weighted.targetStd-repeated.targetStd) > 1e-9 {
+		t.Errorf("Unexpected target scale. Expected %f, got %f", repeated.targetStd, weighted.targetStd)
+	}
+	if err := weighted.PartialFitWeighted(features, target, weights[1:]); err == nil {
+		t.Errorf("Expected an error for a weights length mismatch")
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"math"
+	"sort"
+
+	"gonum.org/v1/gonum/mat"
+	"gonum.org/v1/gonum/stat"
+)
+
+// splineBasis expands a single feature value into a row of spline basis columns.
+type splineBasis interface {
+	// expand evaluates every basis function at x
+	expand(x float64) []float64
+	// size is the number of basis columns expand returns
+	size() int
+	// penalty is the size x size roughness penalty applied to the basis coefficients
+	penalty() *mat.Dense
+}
+
+// naturalCubicSpline is a cubic spline that is linear beyond its boundary knots.
+// It uses the truncated power basis from The Elements of Statistical Learning (eq. 5.4),
+// without the constant column, so it has len(knots)-1 columns.
+type naturalCubicSpline struct {
+	knots []float64 // sorted, first and last are the boundary knots
+}
+
+// bSpline is a B-spline basis of the given degree over a clamped knot vector.
+type bSpline struct {
+	knots  []float64 // full knot vector with the boundary knots repeated degree+1 times
+	degree int
+}
+
+// newNaturalCubicSpline places numKnots knots (including both boundary knots) at evenly
+// spaced quantiles of x.
+func newNaturalCubicSpline(x []float64, numKnots int) naturalCubicSpline {
+	if numKnots < 3 {
+		panic("natural cubic spline needs at least 3 knots")
+	}
+	return naturalCubicSpline{knots: quantileKnots(x, numKnots-2, true)}
+}
+
+// newBSpline builds a clamped B-spline basis with numInteriorKnots knots placed at
+// evenly spaced quantiles of x. degree 3 gives the usual cubic B-spline.
+func newBSpline(x []float64, numInteriorKnots int, degree int) bSpline {
+	if degree < 1 {
+		panic("B-spline degree must be at least 1")
+	}
+	boundary := quantileKnots(x, 0, true)
+	interior := quantileKnots(x, numInteriorKnots, false)
+
+	knots := make([]float64, 0, len(interior)+2*(degree+1))
+	for i := 0; i <= degree; i++ {
+		knots = append(knots, boundary[0])
+	}
+	knots = append(knots, interior...)
+	for i := 0; i <= degree; i++ {
+		knots = append(knots, boundary[1])
+	}
+	return bSpline{knots: knots, degree: degree}
+}
+
+// quantileKnots returns numInterior distinct knots at the i/(numInterior+1) quantiles of x,
+// optionally surrounded by the minimum and maximum of x as boundary knots.
+func quantileKnots(x []float64, numInterior int, withBoundary bool) []float64 {
+	sorted := append([]float64(nil), x...)
+	sort.Float64s(sorted)
+	lo, hi := sorted[0], sorted[len(sorted)-1]
+	if lo == hi {
+		panic("cannot place spline knots on a constant column")
+	}
+
+	knots := make([]float64, 0, numInterior+2)
+	if withBoundary {
+		knots = append(knots, lo)
+	}
+	for i := 1; i <= numInterior; i++ {
+		q := stat.Quantile(float64(i)/float64(numInterior+1), stat.LinInterp, sorted, nil)
+		// Skip knots that collide with the boundary or with the previous knot (tied values)
+		if q <= lo || q >= hi || (len(knots) > 0 && q <= knots[len(knots)-1]) {
+			continue
+		}
+		knots = append(knots, q)
+	}
+	if withBoundary {
+		knots = append(knots, hi)
+	}
+	return knots
+}
+
+func (s naturalCubicSpline) size() int {
+	return len(s.knots) - 1
+}
+
+func (s naturalCubicSpline) expand(x float64) []float64 {
+	// Work on the unit interval so that the cubic terms stay on the same scale as x
+	lo, hi := s.knots[0], s.knots[len(s.knots)-1]
+	scale := func(v float64) float64 { return (v - lo) / (hi - lo) }
+
+	numKnots := len(s.knots)
+	u := scale(x)
+	last := scale(s.knots[numKnots-1])
+	secondLast := scale(s.knots[numKnots-2])
+
+	// d_k(x) = ((x - ξ_k)^3_+ - (x - ξ_K)^3_+) / (ξ_K - ξ_k)
+	d := func(knot float64) float64 {
+		return (cubePlus(u-knot) - cubePlus(u-last)) / (last - knot)
+	}
+
+	basis := make([]float64, s.size())
+	basis[0] = u
+	dSecondLast := d(secondLast)
+	for k := 0; k < numKnots-2; k++ {
+		basis[k+1] = d(scale(s.knots[k])) - dSecondLast
+	}
+	return basis
+}
+
+// penalty shrinks the nonlinear columns towards zero and leaves the linear column free,
+// so a large lambda pulls the smooth towards a straight line.
+func (s naturalCubicSpline) penalty() *mat.Dense {
+	n := s.size()
+	p := mat.NewDense(n, n, nil)
+	for i := 1; i < n; i++ {
+		p.Set(i, i, 1)
+	}
+	return p
+}
+
+func cubePlus(v float64) float64 {
+	if v <= 0 {
+		return 0
+	}
+	return v * v * v
+}
+
+func (b bSpline) size() int {
+	return len(b.knots) - b.degree - 1
+}
+
+// expand evaluates the B-spline basis with the Cox-de Boor recursion. Values outside
+// the boundary knots are clamped to the boundary.
+func (b bSpline) expand(x float64) []float64 {
+	t := b.knots
+	p := b.degree
+	n := b.size()
+	x = math.Max(t[p], math.Min(t[n], x))
+
+	// Find the knot span [t[span], t[span+1]) that contains x
+	span := p
+	for span < n-1 && x >= t[span+1] {
+		span++
+	}
+
+	// Only degree+1 basis functions are non-zero on a span
+	nonZero := make([]float64, p+1)
+	left := make([]float64, p+1)
+	right := make([]float64, p+1)
+	nonZero[0] = 1
+	for j := 1; j <= p; j++ {
+		left[j] = x - t[span+1-j]
+		right[j] = t[span+j] - x
+		saved := 0.0
+		for r := 0; r < j; r++ {
+			temp := nonZero[r] / (right[r+1] + left[j-r])
+			nonZero[r] = saved + right[r+1]*temp
+			saved = left[j-r] * temp
+		}
+		nonZero[j] = saved
+	}
+
+	basis := make([]float64, n)
+	copy(basis[span-p:], nonZero)
+	return basis
+}
+
+// penalty is the second-order difference penalty D^T * D of P-splines, which penalizes
+// changes in slope between neighbouring coefficients.
+func (b bSpline) penalty() *mat.Dense {
+	n := b.size()
+	p := mat.NewDense(n, n, nil)
+	if n < 3 {
+		return p
+	}
+	d := mat.NewDense(n-2, n, nil)
+	for i := 0; i < n-2; i++ {
+		d.Set(i, i, 1)
+		d.Set(i, i+1, -2)
+		d.Set(i, i+2, 1)
+	}
+	p.Mul(d.T(), d)
+	return p
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNormalEquationsMatchBatchRegression(t *testing.T) {
+	features, target := noisyLinearData(200)
+
+	// Accumulate half the rows in each of two workers, then merge
+	first, second := newNormalEquations(3), newNormalEquations(3)
+	for i, row := range features {
+		if i%2 == 0 {
+			first.add(row, target[i], 1)
+		} else {
+			second.add(row, target[i], 1)
+		}
+	}
+	first.merge(second)
+
+	coefficients, err := first.ols()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := linearRegression(features, target)
+	for j := range expected {
+		if math.Abs(coefficients[j]-expected[j]) > 1e-9 {
+			t.Errorf("Unexpected OLS coefficient %d. Expected %f, got %f", j, expected[j], coefficients[j])
+		}
+	}
+
+	ridge, err := first.ridge(5)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected = ridgeRegression(features, target, 5)
+	for j := range expected {
+		if math.Abs(ridge[j]-expected[j]) > 1e-9 {
+			t.Errorf("Unexpected ridge coefficient %d. Expected %f, got %f", j, expected[j], ridge[j])
+		}
+	}
+
+	// A weight of 2 counts a row twice
+	weighted, repeated := newNormalEquations(3), newNormalEquations(3)
+	for i, row := range features {
+		weighted.add(row, target[i], float64(i%2+1))
+		for k := 0; k <= i%2; k++ {
+			repeated.add(row, target[i], 1)
+		}
+	}
+	weightedCoefficients, err := weighted.ridge(5)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	repeatedCoefficients, err := repeated.ridge(5)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for j := range repeatedCoefficients {
+		if math.Abs(weightedCoefficients[j]-repeatedCoefficients[j]) > 1e-9 {
+			t.Errorf("Unexpected weighted coefficient %d. Expected %f, got %f", j, repeatedCoefficients[j], weightedCoefficients[j])
+		}
+	}
+}
+
+func TestNormalEquationsLargeOffsets(t *testing.T) {
+	// Sale dates as Unix seconds: a mean of 1.7e9 and a spread of a few days, where raw
+	// sums of squares cancel catastrophically
+	features, target := noisyLinearData(200)
+	shifted := make([][]float64, len(features))
+	acc := newNormalEquations(3)
+	for i, row := range features {
+		shifted[i] = []float64{row[0] + 1.7e9, row[1], row[2]}
+		acc.add(shifted[i], target[i], 1)
+	}
+	coefficients, err := acc.ols()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := linearRegression(features, target)
+	for j := 1; j < len(expected); j++ {
+		if math.Abs(coefficients[j]-expected[j]) > 1e-6 {
+			t.Errorf("Unexpected slope %d. Expected %f, got %f", j, expected[j], coefficients[j])
+		}
+	}
+}
+
+func TestAccumulateCSV(t *testing.T) {
+	acc, err := accumulateCSV("boston.csv", 4)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	features, target, err := loadCSV("boston.csv")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if acc.count != float64(len(target)) {
+		t.Errorf("Unexpected row count. Expected %d, got %v", len(target), acc.count)
+	}
+	coefficients, err := acc.ols()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := linearRegression(features, target)
+	for j := range expected {
+		if math.Abs(coefficients[j]-expected[j]) > 1e-8*math.Max(1, math.Abs(expected[j])) {
+			t.Errorf("Unexpected coefficient %d. Expected %f, got %f", j, expected[j], coefficients[j])
+		}
+	}
+
+	if _, err := accumulateCSV("missing.csv", 2); err == nil {
+		t.Errorf("Expected an error for a missing file")
+	}
+}